@@ -0,0 +1,149 @@
+package catwalk
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/cockroachdb/datadriven"
+)
+
+const (
+	receivedSuffix = ".received"
+	approvedSuffix = ".approved"
+)
+
+// RunApprovals is a version of RunModel for teams which prefer an
+// approval-testing workflow over rewriting the test files with
+// -rewrite.
+//
+// The test directives in the file pointed to by 'path' are run as
+// usual, but the expected output in the file is ignored. Instead,
+// the directives and their output are collected into a file named
+// path + ".received", which is compared to the file named path +
+// ".approved". The test fails if they differ. The received file is
+// removed when the test succeeds.
+//
+// After reviewing the received output, use Approve or ApproveAll
+// (or the catwalk-approve command) to promote it to approved.
+func RunApprovals(t *testing.T, path string, m tea.Model, opts ...Option) {
+	t.Helper()
+	d := NewDriver(m, opts...)
+	defer d.Close(t)
+
+	var received strings.Builder
+	datadriven.RunTest(t, path, func(t *testing.T, td *datadriven.TestData) string {
+		t.Helper()
+		out := d.RunOneTest(t, td)
+		received.WriteString(td.Cmd)
+		for _, arg := range td.CmdArgs {
+			received.WriteString(" " + arg.String())
+		}
+		received.WriteByte('\n')
+		if td.Input != "" {
+			received.WriteString(td.Input + "\n")
+		}
+		received.WriteString("----\n" + out + "\n")
+		// The expected output in the file is not used.
+		return td.Expected
+	})
+
+	approved, err := ioutil.ReadFile(path + approvedSuffix)
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	if string(approved) == received.String() {
+		if err := os.Remove(path + receivedSuffix); err != nil && !os.IsNotExist(err) {
+			t.Fatal(err)
+		}
+		return
+	}
+	if err := ioutil.WriteFile(path+receivedSuffix, []byte(received.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var diff strings.Builder
+	for _, l := range lineDiff(string(approved), received.String()) {
+		diff.WriteString(l.Op + " " + l.Text + "\n")
+	}
+	t.Errorf("%s: received output differs from approved output; "+
+		"review %s and use catwalk.Approve to approve it:\n%s",
+		path, path+receivedSuffix, diff.String())
+}
+
+// Approve promotes the received output of the test file pointed to
+// by 'path' to approved. See RunApprovals().
+func Approve(path string) error {
+	return os.Rename(path+receivedSuffix, path+approvedSuffix)
+}
+
+// ApproveAll promotes the received output of all the test files
+// under the directory pointed to by 'dir' to approved. It returns
+// the paths of the test files which were approved. See
+// RunApprovals().
+func ApproveAll(dir string) (approved []string, err error) {
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !strings.HasSuffix(path, receivedSuffix) {
+			return nil
+		}
+		testPath := strings.TrimSuffix(path, receivedSuffix)
+		if err := Approve(testPath); err != nil {
+			return fmt.Errorf("approving %s: %v", testPath, err)
+		}
+		approved = append(approved, testPath)
+		return nil
+	})
+	return approved, err
+}
+
+// ListOrphans returns the approved and received files under the
+// directory pointed to by 'dir' whose test file does not exist any
+// more, for example because it was renamed or removed. See
+// RunApprovals().
+func ListOrphans(dir string) (orphans []string, err error) {
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		testPath := path
+		for _, suffix := range []string{approvedSuffix, receivedSuffix} {
+			testPath = strings.TrimSuffix(testPath, suffix)
+		}
+		if testPath == path {
+			return nil
+		}
+		if _, err := os.Stat(testPath); os.IsNotExist(err) {
+			orphans = append(orphans, path)
+		} else if err != nil {
+			return err
+		}
+		return nil
+	})
+	return orphans, err
+}
+
+// PruneOrphans removes the files reported by ListOrphans, to keep
+// test directories from accumulating stale files. It returns the
+// paths of the files which were removed.
+func PruneOrphans(dir string) (pruned []string, err error) {
+	orphans, err := ListOrphans(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range orphans {
+		if err := os.Remove(path); err != nil {
+			return pruned, err
+		}
+		pruned = append(pruned, path)
+	}
+	return pruned, nil
+}
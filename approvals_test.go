@@ -0,0 +1,110 @@
+package catwalk
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestApprovals(t *testing.T) {
+	dir, err := ioutil.TempDir("", "catwalk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	path := filepath.Join(dir, "script")
+	const script = `
+run
+type a
+----
+
+run observe=gostruct
+----
+`
+	const received = `run
+type a
+----
+-- view:
+VALUE: 1🛇
+
+run observe=gostruct
+----
+-- gostruct:
+catwalk.intModel(1)
+
+`
+	if err := ioutil.WriteFile(path, []byte(script), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// Simulate a previous run which produced the received output.
+	if err := ioutil.WriteFile(path+receivedSuffix, []byte(received), 0644); err != nil {
+		t.Fatal(err)
+	}
+	approved, err := ApproveAll(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(approved) != 1 || approved[0] != path {
+		t.Errorf("expected %s approved, got %v", path, approved)
+	}
+
+	RunApprovals(t, path, intModel(0))
+
+	if _, err := os.Stat(path + receivedSuffix); !os.IsNotExist(err) {
+		t.Errorf("expected received file to be removed, got %v", err)
+	}
+	// The test file itself is not modified.
+	if res, err := ioutil.ReadFile(path); err != nil || string(res) != script {
+		t.Errorf("expected test file unchanged, got %q (%v)", res, err)
+	}
+}
+
+func TestPruneOrphans(t *testing.T) {
+	dir, err := ioutil.TempDir("", "catwalk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	for _, name := range []string{
+		"kept", "kept" + approvedSuffix, "kept" + receivedSuffix,
+		"gone" + approvedSuffix, "sub/gone" + receivedSuffix, "sub/other",
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expected := []string{
+		filepath.Join(dir, "gone"+approvedSuffix),
+		filepath.Join(dir, "sub/gone"+receivedSuffix),
+	}
+
+	orphans, err := ListOrphans(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(orphans, expected) {
+		t.Errorf("expected orphans %v, got %v", expected, orphans)
+	}
+
+	pruned, err := PruneOrphans(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(pruned, expected) {
+		t.Errorf("expected pruned %v, got %v", expected, pruned)
+	}
+	if orphans, err := ListOrphans(dir); err != nil || len(orphans) != 0 {
+		t.Errorf("expected no orphans left, got %v (%v)", orphans, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "kept"+approvedSuffix)); err != nil {
+		t.Errorf("expected approved file to be kept: %v", err)
+	}
+}
@@ -0,0 +1,85 @@
+package catwalk
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cockroachdb/datadriven"
+)
+
+// artifactsEnvVars are the environment variables which configure
+// the directory for failure artifacts, in order of preference.
+// TEST_UNDECLARED_OUTPUTS_DIR is set by Bazel.
+var artifactsEnvVars = []string{"CATWALK_ARTIFACTS_DIR", "TEST_UNDECLARED_OUTPUTS_DIR"}
+
+// WithFailureArtifacts tells the test driver to write, for every
+// directive whose output does not match the expected output, the
+// actual output, the expected output and a diff to files under the
+// given directory. This makes it possible to inspect failures in
+// headless CI without re-running the tests locally.
+//
+// The files are named after the position of the directive, for
+// example testdata_foo_12.actual, testdata_foo_12.expected and
+// testdata_foo_12.diff.
+//
+// If dir is empty, the directory is taken from the environment
+// variable CATWALK_ARTIFACTS_DIR, or TEST_UNDECLARED_OUTPUTS_DIR as
+// set by Bazel. The artifacts are also written when this option is
+// not used and CATWALK_ARTIFACTS_DIR is set. No artifacts are written
+// when the tests run with -rewrite.
+func WithFailureArtifacts(dir string) Option {
+	return func(d *driver) {
+		if dir == "" {
+			dir = artifactsDirFromEnv(artifactsEnvVars...)
+		}
+		d.artifactsDir = dir
+	}
+}
+
+// artifactsDirFromEnv returns the value of the first environment
+// variable that is set.
+func artifactsDirFromEnv(vars ...string) string {
+	for _, v := range vars {
+		if dir := os.Getenv(v); dir != "" {
+			return dir
+		}
+	}
+	return ""
+}
+
+// writeFailureArtifacts writes the failure artifacts for the
+// directive if its output does not match the expected output.
+func (d *driver) writeFailureArtifacts(t TB, td *datadriven.TestData, output string) {
+	if d.artifactsDir == "" || outputMatches(output, td.Expected) || rewriting() {
+		return
+	}
+	if err := os.MkdirAll(d.artifactsDir, 0755); err != nil {
+		t.Logf("%s: cannot write failure artifacts: %v", d.pos, err)
+		return
+	}
+	var diff strings.Builder
+	for _, l := range lineDiff(td.Expected, output) {
+		diff.WriteString(l.Op + " " + l.Text + "\n")
+	}
+	base := filepath.Join(d.artifactsDir, sanitizeFileName(td.Pos))
+	for _, f := range []struct{ ext, content string }{
+		{".actual", output},
+		{".expected", td.Expected},
+		{".diff", diff.String()},
+	} {
+		if err := ioutil.WriteFile(base+f.ext, []byte(f.content), 0644); err != nil {
+			t.Logf("%s: cannot write failure artifacts: %v", d.pos, err)
+			return
+		}
+	}
+	t.Logf("%s: failure artifacts written to %s.{actual,expected,diff}", d.pos, base)
+}
+
+// rewriting returns true if the tests run with -rewrite.
+func rewriting() bool {
+	f := flag.Lookup("rewrite")
+	return f != nil && f.Value.String() == "true"
+}
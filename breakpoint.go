@@ -0,0 +1,38 @@
+package catwalk
+
+import (
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// addBreakpoints implements the break_on input command: for the
+// rest of the run directive, the observations are performed every
+// time a message of one of the given types is about to be delivered.
+// The types are named as printed by %T, e.g. tea.WindowSizeMsg.
+func (d *driver) addBreakpoints(t TB, trace bool, args ...string) {
+	if len(args) == 0 {
+		t.Fatalf("%s: syntax: break_on <msgtype...>", d.pos)
+	}
+	if d.breakpoints == nil {
+		d.breakpoints = make(map[string]bool)
+	}
+	for _, typ := range args {
+		d.trace(trace, "break on %s", typ)
+		d.breakpoints[typ] = true
+	}
+}
+
+// checkBreakpoint performs the observations if the message is
+// of one of the types registered with break_on.
+func (d *driver) checkBreakpoint(msg tea.Msg) {
+	if len(d.breakpoints) == 0 || d.breakObserve == nil {
+		return
+	}
+	typ := fmt.Sprintf("%T", msg)
+	if !d.breakpoints[typ] {
+		return
+	}
+	fmt.Fprintf(&d.result, "-- break before %s: %v\n", typ, msg)
+	d.breakObserve()
+}
@@ -0,0 +1,129 @@
+package catwalk
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// bubblesPkgPrefix is the import path prefix of the packages of the
+// bubbles library.
+const bubblesPkgPrefix = "github.com/charmbracelet/bubbles/"
+
+// bubblesComponents are the components of the bubbles library
+// recognized by the bubbles observer, by package name. The
+// components are recognized by their type name and their state is
+// extracted via reflection, so that the observer works with all the
+// versions of the library, and does not pull in the dependencies of
+// the components that the model does not use.
+var bubblesComponents = map[string]func(v reflect.Value) string{
+	"viewport":  func(v reflect.Value) string { return summarizeViewport(v.Interface()) },
+	"list":      summarizeList,
+	"table":     summarizeTable,
+	"textinput": summarizeTextInput,
+	"spinner":   summarizeSpinner,
+}
+
+// bubblesKind returns the name of the bubbles component of the given
+// type, or an empty string if the type is not a recognized component.
+func bubblesKind(typ reflect.Type) string {
+	if typ.Name() != "Model" || !strings.HasPrefix(typ.PkgPath(), bubblesPkgPrefix) {
+		return ""
+	}
+	kind := strings.TrimPrefix(typ.PkgPath(), bubblesPkgPrefix)
+	if _, ok := bubblesComponents[kind]; !ok {
+		return ""
+	}
+	return kind
+}
+
+// observeBubbles implements the bubbles observer: it summarizes
+// the key state of the well-known bubbles components inside the
+// model, found via reflection.
+func observeBubbles(buf io.Writer, m tea.Model) error {
+	comps := findComponents(m, func(typ reflect.Type) bool { return bubblesKind(typ) != "" })
+	if len(comps) == 0 {
+		_, err := io.WriteString(buf, "no bubbles components\n")
+		return err
+	}
+	for _, c := range comps {
+		v := reflect.ValueOf(c.val)
+		kind := bubblesKind(v.Type())
+		if _, err := fmt.Fprintf(buf, "%s: %s %s\n", c.path, kind, summarizeBubble(kind, v)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// summarizeBubble describes the key state of the component. A
+// version of the library with a different API is reported as such
+// instead of failing the test.
+func summarizeBubble(kind string, v reflect.Value) (res string) {
+	defer func() {
+		if r := recover(); r != nil {
+			res = fmt.Sprintf("(unsupported version: %v)", r)
+		}
+	}()
+	return bubblesComponents[kind](v)
+}
+
+var viewportType = reflect.TypeOf(viewport.Model{})
+
+func summarizeViewport(v interface{}) string {
+	vp := v.(viewport.Model)
+	// The content lines are not exported.
+	lines := reflect.ValueOf(vp).FieldByName("lines").Len()
+	return fmt.Sprintf("y_offset=%d scroll=%.0f%% height=%d lines=%d",
+		vp.YOffset, vp.ScrollPercent()*100, vp.Height, lines)
+}
+
+func summarizeList(v reflect.Value) string {
+	s := fmt.Sprintf("index=%d/%d visible=%d filter=%q",
+		callMethod(v, "Index").Int(),
+		callMethod(v, "Items").Len(),
+		callMethod(v, "VisibleItems").Len(),
+		fmt.Sprint(callMethod(v, "FilterState").Interface()))
+	if q := callMethod(v, "FilterValue").String(); q != "" {
+		s += fmt.Sprintf(" query=%q", q)
+	}
+	if it := callMethod(v, "SelectedItem"); !it.IsNil() {
+		s += fmt.Sprintf(" selected=%q", callMethod(it, "FilterValue").String())
+	}
+	return s
+}
+
+func summarizeTable(v reflect.Value) string {
+	s := fmt.Sprintf("cursor=%d/%d focused=%v",
+		callMethod(v, "Cursor").Int(),
+		callMethod(v, "Rows").Len(),
+		callMethod(v, "Focused").Bool())
+	if row := callMethod(v, "SelectedRow"); row.Len() > 0 {
+		s += fmt.Sprintf(" selected=%q", row.Interface())
+	}
+	return s
+}
+
+func summarizeTextInput(v reflect.Value) string {
+	return fmt.Sprintf("value=%q cursor=%d focused=%v",
+		callMethod(v, "Value").String(),
+		callMethod(v, "Cursor").Int(),
+		callMethod(v, "Focused").Bool())
+}
+
+func summarizeSpinner(v reflect.Value) string {
+	// The current frame is not exported.
+	return fmt.Sprintf("frame=%d/%d",
+		v.FieldByName("frame").Int(),
+		v.FieldByName("Spinner").FieldByName("Frames").Len())
+}
+
+// callMethod calls the method with the given name and no arguments
+// on v, and returns its first result.
+func callMethod(v reflect.Value, name string) reflect.Value {
+	return v.MethodByName(name).Call(nil)[0]
+}
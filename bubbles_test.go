@@ -0,0 +1,47 @@
+package catwalk
+
+import (
+	"testing"
+
+	"github.com/charmbracelet/bubbles/spinner"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// bubblesModel contains several bubbles components.
+type bubblesModel struct {
+	scrollModel
+	spin spinner.Model
+}
+
+func (m bubblesModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if _, ok := msg.(tea.KeyMsg); ok {
+		var cmd tea.Cmd
+		m.spin, cmd = m.spin.Update(m.spin.Tick())
+		return m, cmd
+	}
+	return m, nil
+}
+
+func TestBubblesObserver(t *testing.T) {
+	RunModelFromString(t, `
+run observe=bubbles
+----
+-- bubbles:
+scrollModel.vp: viewport y_offset=0 scroll=0% height=3 lines=11
+spin: spinner frame=0/4
+
+run observe=bubbles
+type ab
+----
+-- bubbles:
+scrollModel.vp: viewport y_offset=0 scroll=0% height=3 lines=11
+spin: spinner frame=2/4
+`, bubblesModel{scrollModel: newScrollModel(), spin: spinner.New()})
+
+	RunModelFromString(t, `
+run observe=bubbles
+----
+-- bubbles:
+no bubbles components
+`, intModel(0))
+}
@@ -0,0 +1,245 @@
+package catwalk
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// BubblesUpdater defines an updater which supports commands to
+// change the state of the bubbles components inside a struct of the
+// model:
+//
+//   - "settext <name> <text...>" sets the value of a textinput.
+//   - "setcursor <name> <pos>" moves the cursor of a textinput.
+//   - "select <name> <index>" selects an item of a list.
+//   - "filter <name> <text...>" filters the items of a list.
+//   - "goto-row <name> <row>" moves the cursor of a table.
+//   - "tick <name>" advances a spinner by one frame.
+//
+// You can add this to a test using WithUpdater(), and chain it with
+// other updaters like KeyMapUpdater and StylesUpdater. It is possible
+// to add multiple bubbles updaters to the same test.
+//
+// For example, using:
+//
+//    BubblesUpdater("mymodel",
+//                   func(m tea.Model, changeComponents func(interface{}) error) (tea.Model, error) {
+//                      myModel := m.(mymodel)
+//                      if err := changeComponents(&myModel); err != nil {
+//                            return m, err
+//                      }
+//                      return myModel, nil
+//                   })
+//
+// and mymodel containing a textinput.Model field named Input, it
+// becomes possible to use "settext mymodel.Input hello" to change
+// the text in the input during a test.
+//
+// The components are accessed via reflection, so the fields must be
+// exported. The commands call the methods of the components directly
+// and do not send messages to the model. The exception is "filter"
+// with versions of the list component which do not provide
+// SetFilterText: the filter is then typed into the list, and the
+// filtered items are updated when the model processes the resulting
+// commands, as if the user had typed the filter.
+//
+// If your model implements tea.Model by reference (i.e. its address
+// does not change through Update calls), you can simplify
+// the call as follows:
+//
+//      BubblesUpdater("...", SimpleBubblesApplier(&yourmodel)).
+func BubblesUpdater(prefix string, apply BubblesApplier) Updater {
+	return func(m tea.Model, inputCmd string, args ...string) (bool, tea.Model, tea.Cmd, error) {
+		return handleBubblesUpdate(prefix+".", apply, m, inputCmd, args...)
+	}
+}
+
+// BubblesApplier is the type of a function which applies the
+// changeComponents callback on a struct inside the model, then
+// returns the resulting model.
+//
+// Example implementation:
+//     func(m tea.Model, changeComponents func(interface{}) error) (tea.Model, error) {
+//        myModel := m.(mymodel)
+//        if err := changeComponents(&myModel); err != nil {
+//              return m, err
+//        }
+//        return myModel, nil
+//     }
+type BubblesApplier func(m tea.Model, changeComponents func(interface{}) error) (tea.Model, error)
+
+// SimpleBubblesApplier is a helper to simplify the definition of the
+// function argument to BubblesUpdater, in the case the model is
+// implemented by reference -- i.e. the address of the components
+// does not change from one call to Update to the next.
+func SimpleBubblesApplier(componentStruct interface{}) BubblesApplier {
+	return func(m tea.Model, changeComponents func(interface{}) error) (tea.Model, error) {
+		return m, changeComponents(componentStruct)
+	}
+}
+
+// bubblesUpdateCmd describes one of the commands of BubblesUpdater.
+type bubblesUpdateCmd struct {
+	// syntax describes the arguments after the component name.
+	syntax string
+	// hasText is true if the arguments after the component name
+	// form a single text, possibly empty.
+	hasText bool
+	// method is the method of the component required by the
+	// command.
+	method string
+	// run applies the command to the component, given by a pointer.
+	run func(comp reflect.Value, arg string) (tea.Cmd, error)
+}
+
+var bubblesUpdateCmds = map[string]bubblesUpdateCmd{
+	"settext":   {syntax: "<text...>", hasText: true, method: "SetValue", run: setComponentText},
+	"setcursor": {syntax: "<pos>", method: "SetCursor", run: callComponentIntMethod("SetCursor")},
+	"select":    {syntax: "<index>", method: "Select", run: callComponentIntMethod("Select")},
+	"filter":    {syntax: "<text...>", hasText: true, method: "FilterState", run: filterComponent},
+	"goto-row":  {syntax: "<row>", method: "SetCursor", run: callComponentIntMethod("SetCursor")},
+	"tick":      {method: "Tick", run: tickComponent},
+}
+
+func handleBubblesUpdate(
+	prefix string, apply BubblesApplier, m tea.Model, inputCmd string, args ...string,
+) (bool, tea.Model, tea.Cmd, error) {
+	c, ok := bubblesUpdateCmds[inputCmd]
+	if !ok {
+		// Command not supported.
+		return false, m, nil, nil
+	}
+	syntax := fmt.Errorf("syntax: %s", strings.TrimSpace(inputCmd+" <name> "+c.syntax))
+	if len(args) < 1 {
+		return false, m, nil, syntax
+	}
+	if !strings.HasPrefix(args[0], prefix) {
+		// This command is meant for another updater. Not us.
+		return false, m, nil, nil
+	}
+	var arg string
+	switch {
+	case c.hasText:
+		arg = strings.Join(args[1:], " ")
+	case c.syntax == "":
+		if len(args) != 1 {
+			return true, m, nil, syntax
+		}
+	default:
+		if len(args) != 2 {
+			return true, m, nil, syntax
+		}
+		arg = args[1]
+	}
+	fieldName := strings.TrimPrefix(args[0], prefix)
+	var cmd tea.Cmd
+	newM, err := apply(m, func(s interface{}) error {
+		comp, err := getComponent(s, fieldName, inputCmd, c.method)
+		if err != nil {
+			return err
+		}
+		cmd, err = runBubblesUpdate(c, comp, arg)
+		return err
+	})
+	return true, newM, cmd, err
+}
+
+// getComponent returns a pointer to the field with the given name in
+// the struct, which must support the given method.
+func getComponent(s interface{}, fieldName, inputCmd, method string) (reflect.Value, error) {
+	v := reflect.ValueOf(s)
+	if v.Type().Kind() != reflect.Ptr || v.Elem().Type().Kind() != reflect.Struct {
+		return reflect.Value{}, fmt.Errorf("type %T is not a pointer to struct", s)
+	}
+	f, ok := v.Elem().Type().FieldByName(fieldName)
+	if !ok || f.PkgPath != "" {
+		return reflect.Value{}, fmt.Errorf("struct %T does not contain an exported field named %q", s, fieldName)
+	}
+	comp := v.Elem().FieldByIndex(f.Index).Addr()
+	if !comp.MethodByName(method).IsValid() {
+		return reflect.Value{}, fmt.Errorf("field %q of struct %T (type %s) does not support %q",
+			fieldName, s, f.Type, inputCmd)
+	}
+	return comp, nil
+}
+
+// runBubblesUpdate runs the command on the component. A version of
+// the library with a different API is reported as an error instead
+// of crashing the test.
+func runBubblesUpdate(c bubblesUpdateCmd, comp reflect.Value, arg string) (cmd tea.Cmd, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%s: unsupported version: %v", comp.Type().Elem(), r)
+		}
+	}()
+	return c.run(comp, arg)
+}
+
+func setComponentText(comp reflect.Value, text string) (tea.Cmd, error) {
+	comp.MethodByName("SetValue").Call([]reflect.Value{reflect.ValueOf(text)})
+	return nil, nil
+}
+
+// callComponentIntMethod returns a command implementation which
+// calls the given method with an integer argument.
+func callComponentIntMethod(method string) func(reflect.Value, string) (tea.Cmd, error) {
+	return func(comp reflect.Value, arg string) (tea.Cmd, error) {
+		n, err := strconv.Atoi(arg)
+		if err != nil {
+			return nil, err
+		}
+		comp.MethodByName(method).Call([]reflect.Value{reflect.ValueOf(n)})
+		return nil, nil
+	}
+}
+
+// filterComponent sets the filter of a list. Versions of the list
+// component without SetFilterText are sent the keys to start
+// filtering and the filter text instead.
+func filterComponent(comp reflect.Value, text string) (tea.Cmd, error) {
+	if m := comp.MethodByName("SetFilterText"); m.IsValid() {
+		m.Call([]reflect.Value{reflect.ValueOf(text)})
+		return nil, nil
+	}
+	if fmt.Sprint(callMethod(comp, "FilterState").Interface()) != "unfiltered" {
+		comp.MethodByName("ResetFilter").Call(nil)
+	}
+	keys := callMethod(comp.Elem().FieldByName("KeyMap").FieldByName("Filter"), "Keys").Interface().([]string)
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("%s: the filter key binding is not defined", comp.Type().Elem())
+	}
+	k, err := ParseKey(keys[0])
+	if err != nil {
+		return nil, err
+	}
+	msgs := []tea.Msg{k}
+	if text != "" {
+		msgs = append(msgs, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(text)})
+	}
+	var cmds []tea.Cmd
+	for _, msg := range msgs {
+		cmds = append(cmds, updateComponent(comp, msg))
+	}
+	return tea.Batch(cmds...), nil
+}
+
+// tickComponent advances a spinner by one frame. The command which
+// schedules the next tick is discarded, so that the spinner only
+// moves when the test says so.
+func tickComponent(comp reflect.Value, _ string) (tea.Cmd, error) {
+	_ = updateComponent(comp, callMethod(comp, "Tick").Interface())
+	return nil, nil
+}
+
+// updateComponent calls the Update method of the component, given by
+// a pointer, and stores the resulting component in place.
+func updateComponent(comp reflect.Value, msg tea.Msg) tea.Cmd {
+	res := comp.MethodByName("Update").Call([]reflect.Value{reflect.ValueOf(&msg).Elem()})
+	comp.Elem().Set(res[0])
+	cmd, _ := res[1].Interface().(tea.Cmd)
+	return cmd
+}
@@ -0,0 +1,214 @@
+package catwalk
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/bubbles/spinner"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// fakeInput has the API of textinput.Model used by BubblesUpdater.
+type fakeInput struct {
+	value  string
+	cursor int
+}
+
+func (f *fakeInput) SetValue(s string) { f.value = s; f.cursor = len(s) }
+func (f *fakeInput) SetCursor(pos int) { f.cursor = pos }
+
+// fakeList has the API of a recent list.Model used by
+// BubblesUpdater.
+type fakeList struct {
+	index  int
+	filter string
+}
+
+func (f *fakeList) Select(index int)       { f.index = index }
+func (f *fakeList) SetFilterText(s string) { f.filter = s }
+func (f fakeList) FilterState() string     { return "unfiltered" }
+
+// fakeOldList has the API of the list.Model of older versions of
+// bubbles, which do not provide SetFilterText.
+type fakeOldList struct {
+	KeyMap struct{ Filter key.Binding }
+	state  string
+	filter string
+}
+
+func (f fakeOldList) FilterState() string { return f.state }
+func (f *fakeOldList) ResetFilter()       { f.state, f.filter = "unfiltered", "" }
+
+func (f fakeOldList) Update(msg tea.Msg) (fakeOldList, tea.Cmd) {
+	k, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return f, nil
+	}
+	if f.state != "filtering" {
+		if key.Matches(k, f.KeyMap.Filter) {
+			f.state = "filtering"
+		}
+		return f, nil
+	}
+	f.filter += string(k.Runes)
+	filter := f.filter
+	return f, func() tea.Msg { return "filtered: " + filter }
+}
+
+// fakeTable has the API of table.Model used by BubblesUpdater.
+// The version of bubbles in use does not provide a table component.
+type fakeTable struct {
+	cursor int
+}
+
+func (f *fakeTable) SetCursor(n int) { f.cursor = n }
+
+type componentsModel struct {
+	Input   fakeInput
+	List    fakeList
+	OldList fakeOldList
+	Table   fakeTable
+	Spin    spinner.Model
+	other   fakeInput
+	lastMsg string
+}
+
+func (m *componentsModel) Init() tea.Cmd { return nil }
+func (m *componentsModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if s, ok := msg.(string); ok {
+		m.lastMsg = s
+	}
+	return m, nil
+}
+func (m *componentsModel) View() string {
+	return strings.Join([]string{
+		fmt.Sprintf("input: value=%q cursor=%d", m.Input.value, m.Input.cursor),
+		fmt.Sprintf("list: index=%d filter=%q", m.List.index, m.List.filter),
+		fmt.Sprintf("oldlist: state=%s filter=%q", m.OldList.state, m.OldList.filter),
+		fmt.Sprintf("table: cursor=%d", m.Table.cursor),
+		"spin: " + summarizeSpinner(reflect.ValueOf(m.Spin)),
+		"last msg: " + m.lastMsg,
+	}, "\n")
+}
+
+func TestBubblesUpdater(t *testing.T) {
+	m := &componentsModel{Spin: spinner.New()}
+	m.OldList.state = "unfiltered"
+	m.OldList.KeyMap.Filter = key.NewBinding(key.WithKeys("/"))
+	upd := ChainUpdaters(
+		StylesUpdater("styles", SimpleStylesApplier(&mystyles{})),
+		BubblesUpdater("comps", SimpleBubblesApplier(m)),
+	)
+
+	RunModelFromString(t, `
+run
+settext comps.Input hello world
+setcursor comps.Input 3
+select comps.List 2
+filter comps.List abc
+goto-row comps.Table 4
+tick comps.Spin
+tick comps.Spin
+----
+-- view:
+input: value="hello world" cursor=3␤
+list: index=2 filter="abc"␤
+oldlist: state=unfiltered filter=""␤
+table: cursor=4␤
+spin: frame=2/4␤
+last msg: 🛇
+
+run
+filter comps.OldList xy
+----
+-- view:
+input: value="hello world" cursor=3␤
+list: index=2 filter="abc"␤
+oldlist: state=filtering filter="xy"␤
+table: cursor=4␤
+spin: frame=2/4␤
+last msg: filtered: xy🛇
+`, m, WithUpdater(upd))
+
+	errs := []struct {
+		input  string
+		expErr string
+	}{
+		{"settext", `syntax: settext <name> <text...>`},
+		{"setcursor comps.Input", `syntax: setcursor <name> <pos>`},
+		{"setcursor comps.Input x", `strconv.Atoi: parsing "x": invalid syntax`},
+		{"tick comps.Spin 1", `syntax: tick <name>`},
+		{"select comps.Input 1", `field "Input" of struct *catwalk.componentsModel (type catwalk.fakeInput) does not support "select"`},
+		{"goto-row comps.Table", `syntax: goto-row <name> <row>`},
+		{"goto-row comps.List 1", `field "List" of struct *catwalk.componentsModel (type catwalk.fakeList) does not support "goto-row"`},
+		{"settext comps.other x", `struct *catwalk.componentsModel does not contain an exported field named "other"`},
+		{"settext comps.Missing x", `struct *catwalk.componentsModel does not contain an exported field named "Missing"`},
+	}
+	for _, tc := range errs {
+		t.Run(tc.input, func(t *testing.T) {
+			args := strings.Fields(tc.input)
+			_, _, _, err := upd(m, args[0], args[1:]...)
+			if err == nil || err.Error() != tc.expErr {
+				t.Fatalf("expected error %q, got: %v", tc.expErr, err)
+			}
+		})
+	}
+}
+
+// listItem is an item of a list.Model.
+type listItem string
+
+func (i listItem) FilterValue() string { return string(i) }
+
+// realComponentsModel contains the bubbles components of the
+// version of the library in use.
+type realComponentsModel struct {
+	Input textinput.Model
+	List  list.Model
+}
+
+func (m *realComponentsModel) Init() tea.Cmd { return nil }
+func (m *realComponentsModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+	m.List, cmd = m.List.Update(msg)
+	return m, cmd
+}
+func (m *realComponentsModel) View() string {
+	return strings.Join([]string{
+		fmt.Sprintf("input: value=%q cursor=%d", m.Input.Value(), m.Input.Cursor()),
+		fmt.Sprintf("list: index=%d state=%s filter=%q visible=%d",
+			m.List.Index(), m.List.FilterState(), m.List.FilterValue(), len(m.List.VisibleItems())),
+	}, "\n")
+}
+
+// TestBubblesUpdaterComponents checks BubblesUpdater with the
+// components of the version of bubbles in use.
+func TestBubblesUpdaterComponents(t *testing.T) {
+	items := []list.Item{listItem("apple"), listItem("banana"), listItem("cherry"), listItem("apricot")}
+	m := &realComponentsModel{
+		Input: textinput.New(),
+		List:  list.New(items, list.NewDefaultDelegate(), 40, 20),
+	}
+	RunModelFromString(t, `
+run
+settext comps.Input hello world
+setcursor comps.Input 3
+select comps.List 2
+----
+-- view:
+input: value="hello world" cursor=3␤
+list: index=2 state=unfiltered filter="" visible=4🛇
+
+run
+filter comps.List ap
+----
+-- view:
+input: value="hello world" cursor=3␤
+list: index=0 state=filtering filter="ap" visible=2🛇
+`, m, WithUpdater(BubblesUpdater("comps", SimpleBubblesApplier(m))))
+}
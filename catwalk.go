@@ -1,7 +1,14 @@
 package catwalk
 
 import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/cockroachdb/datadriven"
@@ -12,7 +19,7 @@ import (
 // the specified options.
 //
 // To apply RunModel on all the test files in a directory,
-// use datadriven.Walk.
+// use Walk.
 func RunModel(t *testing.T, path string, m tea.Model, opts ...Option) {
 	t.Helper()
 	d := NewDriver(m, opts...)
@@ -20,8 +27,129 @@ func RunModel(t *testing.T, path string, m tea.Model, opts ...Option) {
 
 	datadriven.RunTest(t, path, func(t *testing.T, td *datadriven.TestData) string {
 		t.Helper()
-		return d.RunOneTest(t, td)
+		return runInScenario(t, d, td)
 	})
+
+	if dd := d.(*driver); dd.rewriteCheck && rewriting() && !t.Failed() {
+		checkRewrite(t, path, dd.initialModel.restore(), opts)
+	}
+}
+
+// ModelFactory is the type of a function which creates a model to
+// test, together with the options to use for its test driver.
+//
+// The factory receives the testing handle for the test that will use
+// the model. This can be used to create resources tied to the
+// lifetime of the test, e.g. temporary directories or fake servers
+// cleaned up via t.Cleanup.
+type ModelFactory func(t testing.TB) (tea.Model, []Option)
+
+// RunModelFunc is a version of RunModel which creates the model
+// to test using the given factory function.
+func RunModelFunc(t *testing.T, path string, f ModelFactory) {
+	t.Helper()
+	m, opts := f(t)
+	RunModel(t, path, m, opts...)
+}
+
+// Walk runs the tests contained in all the files under the
+// directory pointed to by 'path', using one sub-test per file.
+// A fresh model and driver is created for each file using
+// the given factory function.
+//
+// Walk supports sharding the test files across multiple processes,
+// e.g. on multiple CI machines, when the environment variables
+// CATWALK_SHARD_INDEX and CATWALK_SHARD_TOTAL are set (or
+// TEST_SHARD_INDEX and TEST_TOTAL_SHARDS, as set by Bazel).
+// See WalkShard.
+//
+// When the environment variable CATWALK_SUMMARY_FILE is set, a JSON
+// summary of the run is written to the file it names. See
+// WalkSummary.
+func Walk(t *testing.T, path string, f ModelFactory) {
+	t.Helper()
+	index, total, err := shardFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	WalkShard(t, path, index, total, f)
+}
+
+// WalkShard is a version of Walk which only runs the test files in
+// the given shard: the files are assigned to the shards in
+// round-robin order, and the files outside of the shard are
+// skipped. The index ranges from 0 to total-1.
+func WalkShard(t *testing.T, path string, index, total int, f ModelFactory) {
+	t.Helper()
+	if total < 1 || index < 0 || index >= total {
+		t.Fatalf("invalid shard %d of %d", index, total)
+	}
+	var summary *WalkSummary
+	if os.Getenv(summaryEnvVar) != "" {
+		summary = &WalkSummary{Path: path, ShardIndex: index, ShardTotal: total}
+		defer func(start time.Time) {
+			summary.Duration = time.Since(start)
+			writeWalkSummary(t, summary)
+		}(time.Now())
+	}
+	i := 0
+	datadriven.Walk(t, path, func(t *testing.T, path string) {
+		shard := i % total
+		i++
+		if summary == nil {
+			if shard != index {
+				t.Skipf("in shard %d, not %d", shard, index)
+			}
+			RunModelFunc(t, path, f)
+			return
+		}
+		summary.Files = append(summary.Files, FileSummary{Path: path})
+		fs := &summary.Files[len(summary.Files)-1]
+		if shard != index {
+			fs.Skipped = true
+			t.Skipf("in shard %d, not %d", shard, index)
+		}
+		defer func(start time.Time) {
+			fs.Duration = time.Since(start)
+			fs.Failed = t.Failed()
+		}(time.Now())
+		m, opts := f(t)
+		RunModel(t, path, m, append(opts, withFileSummary(fs))...)
+	})
+}
+
+// shardEnvVars are the pairs of environment variables which
+// configure sharding in Walk, in order of preference.
+var shardEnvVars = [][2]string{
+	{"CATWALK_SHARD_INDEX", "CATWALK_SHARD_TOTAL"},
+	{"TEST_SHARD_INDEX", "TEST_TOTAL_SHARDS"},
+}
+
+// shardFromEnv returns the shard configured in the environment,
+// or shard 0 of 1 if none is configured.
+func shardFromEnv() (index, total int, err error) {
+	for _, vars := range shardEnvVars {
+		idxS, totalS := os.Getenv(vars[0]), os.Getenv(vars[1])
+		if idxS == "" && totalS == "" {
+			continue
+		}
+		index, err = strconv.Atoi(idxS)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid %s: %v", vars[0], err)
+		}
+		total, err = strconv.Atoi(totalS)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid %s: %v", vars[1], err)
+		}
+		// Bazel checks that the test supports sharding.
+		if p := os.Getenv("TEST_SHARD_STATUS_FILE"); p != "" {
+			if err := ioutil.WriteFile(p, nil, 0644); err != nil {
+				return 0, 0, err
+			}
+		}
+		return index, total, nil
+	}
+	return 0, 1, nil
 }
 
 // RunModelFromString is a version of RunModel which takes the input
@@ -33,6 +161,101 @@ func RunModelFromString(t *testing.T, input string, m tea.Model, opts ...Option)
 
 	datadriven.RunTestFromString(t, input, func(t *testing.T, td *datadriven.TestData) string {
 		t.Helper()
-		return d.RunOneTest(t, td)
+		return runInScenario(t, d, td)
 	})
 }
+
+// RunModelFromStringRewrite is a version of RunModelFromString which
+// also returns the test script after it has run. When the -rewrite
+// flag is set, the returned script contains the updated expected
+// output; otherwise, it is identical to the input.
+//
+// This makes it possible for callers that embed their test scripts
+// in Go source to persist updated expectations programmatically.
+func RunModelFromStringRewrite(
+	t *testing.T, input string, m tea.Model, opts ...Option,
+) (output string) {
+	t.Helper()
+	// datadriven only knows how to rewrite files, so we run the
+	// test through a temporary file.
+	dir, err := ioutil.TempDir("", "catwalk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	path := filepath.Join(dir, "input")
+	if err := ioutil.WriteFile(path, []byte(input), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	RunModel(t, path, m, opts...)
+
+	res, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(res)
+}
+
+// RunCompare runs the tests contained in the file pointed to by
+// 'path' on two models side by side, using one driver per model
+// configured with the specified options. The test fails at every
+// directive where the output of the two models diverges, or where
+// the commands run on their behalf produce different messages.
+//
+// The expected output in the file is checked against modelA.
+// This is useful when refactoring a model or swapping the
+// implementation of a component.
+func RunCompare(t *testing.T, path string, modelA, modelB tea.Model, opts ...Option) {
+	t.Helper()
+	da := NewDriver(modelA, opts...)
+	defer da.Close(t)
+	db := NewDriver(modelB, opts...)
+	defer db.Close(t)
+
+	datadriven.RunTest(t, path, func(t *testing.T, td *datadriven.TestData) string {
+		t.Helper()
+		outA, divergence := compareOne(t, da, db, td)
+		if divergence != "" {
+			t.Errorf("%s: %s", td.Pos, divergence)
+		}
+		return outA
+	})
+}
+
+// compareOne runs one test directive on the two drivers. It returns
+// the output of the first driver, and a description of the
+// divergence between the two, if any.
+func compareOne(t TB, da, db Driver, td *datadriven.TestData) (outA, divergence string) {
+	ha := len(da.History())
+	hb := len(db.History())
+	outA = da.RunOneTest(t, td)
+	outB := db.RunOneTest(t, td)
+	if outA != outB {
+		return outA, fmt.Sprintf("models diverge:\n-- A:\n%s-- B:\n%s", outA, outB)
+	}
+	cmdsA := cmdResults(da.History()[ha:])
+	cmdsB := cmdResults(db.History()[hb:])
+	if cmdsA != cmdsB {
+		return outA, fmt.Sprintf("commands diverge:\n-- A:\n%s-- B:\n%s", cmdsA, cmdsB)
+	}
+	return outA, ""
+}
+
+// cmdResults summarizes the messages produced by the commands
+// in the given history. The command names are not included, since
+// they typically differ between implementations.
+func cmdResults(h []HistoryEntry) string {
+	var buf strings.Builder
+	for _, e := range h {
+		if e.Kind != HistoryCmd {
+			continue
+		}
+		if e.TimedOut {
+			buf.WriteString("timeout\n")
+			continue
+		}
+		fmt.Fprintf(&buf, "%T: %v\n", e.Msg, e.Msg)
+	}
+	return buf.String()
+}
@@ -1,13 +1,17 @@
 package catwalk
 
 import (
+	"flag"
 	"fmt"
 	"io"
+	"os"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/cockroachdb/datadriven"
 )
 
 // TestModel checks basic features.
@@ -60,6 +64,156 @@ func TestModelThreading(t *testing.T) {
 	RunModel(t, "testdata/model_threading", intModel(0), WithUpdater(updater))
 }
 
+// TestTrace checks the trace option.
+func TestTrace(t *testing.T) {
+	RunModel(t, "testdata/trace", emptyModel{})
+}
+
+// TestTraceLog checks that trace=log sends the trace
+// to the test log.
+func TestTraceLog(t *testing.T) {
+	lt := &logTB{TB: t}
+	d := NewDriver(emptyModel{})
+	defer d.Close(lt)
+	out := d.RunOneTest(lt, &datadriven.TestData{
+		Pos:     "test:1",
+		Cmd:     "run",
+		CmdArgs: []datadriven.CmdArg{{Key: "trace", Vals: []string{"log"}}},
+		Input:   "type a",
+	})
+	const expectedOut = "TEA PRINT: {MODEL INIT}\nTEA ENTER ALT\n-- view:\nMODEL VIEW🛇\n"
+	if out != expectedOut {
+		t.Errorf("expected:\n%s\ngot:\n%s", expectedOut, out)
+	}
+	logs := strings.Join(lt.logs, "\n")
+	for _, expected := range []string{
+		"test:1: -- trace: calling Init",
+		"test:1: -- trace: msg tea.KeyMsg: a",
+		"test:1:\n-- view:\nMODEL VIEW🛇\n",
+	} {
+		if !strings.Contains(logs, expected) {
+			t.Errorf("expected %q in logs, got:\n%s", expected, logs)
+		}
+	}
+}
+
+// TestHistory checks that the driver records the messages
+// delivered and the commands executed.
+func TestHistory(t *testing.T) {
+	d := NewDriver(emptyModel{})
+	defer d.Close(t)
+	d.RunOneTest(t, &datadriven.TestData{Pos: "test:1", Cmd: "run", Input: "type q"})
+
+	var buf strings.Builder
+	for _, h := range d.History() {
+		switch h.Kind {
+		case HistoryMsg:
+			fmt.Fprintf(&buf, "%s: msg %T\n", h.Pos, h.Msg)
+		case HistoryCmd:
+			fmt.Fprintf(&buf, "%s: cmd %s -> %T\n", h.Pos, h.Cmd, h.Msg)
+		}
+	}
+	const expected = `test:1: cmd github.com/charmbracelet/bubbletea.Println.func1 -> tea.printLineMessage
+test:1: msg tea.printLineMessage
+test:1: msg tea.KeyMsg
+test:1: cmd github.com/charmbracelet/bubbletea.Quit -> tea.quitMsg
+test:1: msg tea.quitMsg
+`
+	if actual := buf.String(); actual != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, actual)
+	}
+}
+
+// TestStep checks that a model can be driven programmatically,
+// and mixed with run directives.
+func TestStep(t *testing.T) {
+	d := NewDriver(stepModel{limit: 3})
+	defer d.Close(t)
+
+	if d.Step(t) {
+		t.Fatal("expected nothing to process")
+	}
+	d.PostMsg(stepMsg{})
+	steps := 0
+	for d.Step(t) {
+		steps++
+	}
+	if steps != 3 {
+		t.Errorf("expected 3 steps, got %d", steps)
+	}
+	if m := d.Model().(stepModel); m.n != 3 {
+		t.Errorf("expected n=3, got %d", m.n)
+	}
+
+	// The key resets the count; the run directive leaves the
+	// following messages in the queue.
+	out := d.RunOneTest(t, &datadriven.TestData{Pos: "test:1", Cmd: "run", Input: "type a"})
+	if expected := "-- view:\nstep 1🛇\n"; out != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, out)
+	}
+	for d.Step(t) {
+	}
+
+	d.PostCmd(func() tea.Msg { return tea.KeyMsg(tea.Key{Type: tea.KeyEnter}) })
+	if !d.Step(t) {
+		t.Fatal("expected the command to run")
+	}
+	if m := d.Model().(stepModel); m.n != 3 {
+		t.Errorf("expected n=3 before the key is delivered, got %d", m.n)
+	}
+	d.Step(t)
+	if m := d.Model().(stepModel); m.n != 0 {
+		t.Errorf("expected n=0 after the key, got %d", m.n)
+	}
+}
+
+// pingModel answers every message with a command producing
+// another message, forever.
+type pingModel struct{ n int }
+
+func (pingModel) Init() tea.Cmd { return nil }
+func (m pingModel) Update(tea.Msg) (tea.Model, tea.Cmd) {
+	m.n++
+	return m, func() tea.Msg { return stepMsg{} }
+}
+func (pingModel) View() string { return "" }
+
+// TestStepIterations checks that each step has its own budget of
+// iterations, and that a loop within a step is reported with the
+// position of the step.
+func TestStepIterations(t *testing.T) {
+	d := NewDriver(pingModel{}, WithMaxIterations(20))
+	defer d.Close(t)
+	d.PostMsg(stepMsg{})
+	for i := 0; i < 100; i++ {
+		d.Step(t)
+	}
+	if m := d.Model().(pingModel); m.n != 100 {
+		t.Errorf("expected 100 updates, got %d", m.n)
+	}
+
+	d = NewDriver(loopModel{}, WithMaxIterations(20))
+	defer d.Close(t)
+	d.PostMsg(tea.KeyMsg(tea.Key{Type: tea.KeyRunes, Runes: []rune("l")}))
+	fatal := catchFatal(t, func(t TB) {
+		for d.Step(t) {
+		}
+	})
+	if expected := "Step() #1: more than 20 messages and commands processed"; !strings.HasPrefix(fatal, expected) {
+		t.Errorf("expected %q, got %q", expected, fatal)
+	}
+}
+
+// logTB is a TB which records the log messages.
+type logTB struct {
+	TB
+	logs []string
+}
+
+func (l *logTB) Logf(format string, args ...interface{}) {
+	l.logs = append(l.logs, fmt.Sprintf(format, args...))
+}
+
 // TestFromString checks that a test can run from a string input directly.
 func TestFromString(t *testing.T) {
 	const test = `
@@ -72,6 +226,103 @@ MODEL VIEW🛇
 	RunModelFromString(t, test, emptyModel{})
 }
 
+// TestFromStringRewrite checks that the rewritten test input
+// is returned to the caller.
+func TestFromStringRewrite(t *testing.T) {
+	const test = `
+run
+----
+`
+	const expected = `
+run
+----
+TEA PRINT: {MODEL INIT}
+-- view:
+MODEL VIEW🛇
+`
+	// Without -rewrite, the input is returned as-is.
+	if out := RunModelFromStringRewrite(t, expected, emptyModel{}); out != expected {
+		t.Errorf("expected input unchanged, got:\n%s", out)
+	}
+
+	rw := flag.Lookup("rewrite").Value
+	prev := rw.String()
+	if err := rw.Set("true"); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = rw.Set(prev) }()
+
+	if out := RunModelFromStringRewrite(t, test, emptyModel{}); out != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, out)
+	}
+}
+
+// initCountModel counts the calls to Init. The counter is
+// incremented via a func, which is shared by the copies of the model.
+type initCountModel struct{ onInit func() }
+
+func (m initCountModel) Init() tea.Cmd                       { m.onInit(); return nil }
+func (m initCountModel) Update(tea.Msg) (tea.Model, tea.Cmd) { return m, nil }
+func (m initCountModel) View() string                        { return "hello" }
+
+// TestRewriteCheck checks that the rewritten file is run again
+// with WithRewriteCheck.
+func TestRewriteCheck(t *testing.T) {
+	const test = `
+run
+----
+`
+	const expected = `
+run
+----
+-- view:
+hello🛇
+`
+	rw := flag.Lookup("rewrite").Value
+	prev := rw.String()
+	if err := rw.Set("true"); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = rw.Set(prev) }()
+
+	for _, check := range []bool{false, true} {
+		var opts []Option
+		expectedInits := 1
+		if check {
+			opts = append(opts, WithRewriteCheck())
+			expectedInits = 2
+		}
+		inits := 0
+		if out := RunModelFromStringRewrite(t, test, initCountModel{func() { inits++ }}, opts...); out != expected {
+			t.Errorf("expected:\n%s\ngot:\n%s", expected, out)
+		}
+		if inits != expectedInits {
+			t.Errorf("check=%v: expected %d calls to Init, got %d", check, expectedInits, inits)
+		}
+		if rw.String() != "true" {
+			t.Errorf("check=%v: the -rewrite flag was not restored", check)
+		}
+	}
+}
+
+// TestCompare checks that RunCompare accepts equivalent models, and
+// that divergences are detected.
+func TestCompare(t *testing.T) {
+	RunCompare(t, "testdata/model_threading", intModel(0), intModel(0), WithUpdater(updater))
+
+	da := NewDriver(intModel(0))
+	defer da.Close(t)
+	db := NewDriver(intModel(1))
+	defer db.Close(t)
+	td := &datadriven.TestData{Pos: "test:1", Cmd: "run", Input: "type a"}
+	out, divergence := compareOne(t, da, db, td)
+	const expectedOut = "-- view:\nVALUE: 1🛇\n"
+	const expectedDivergence = "models diverge:\n-- A:\n-- view:\nVALUE: 1🛇\n-- B:\n-- view:\nVALUE: 2🛇\n"
+	if out != expectedOut || divergence != expectedDivergence {
+		t.Errorf("expected:\n%s\n%s\ngot:\n%s\n%s", expectedOut, expectedDivergence, out, divergence)
+	}
+}
+
 // TestObserver checks that a test can use a custom observer.
 func TestObserver(t *testing.T) {
 	const test = `
@@ -85,6 +336,48 @@ world!
 	RunModelFromString(t, test, emptyModel{}, WithObserver("hello", o))
 }
 
+// TestWalk checks that Walk creates a fresh model for each test file
+// using the model factory.
+func TestWalk(t *testing.T) {
+	var created, cleaned int
+	t.Run("walk", func(t *testing.T) {
+		Walk(t, "testdata/walk", func(t testing.TB) (tea.Model, []Option) {
+			created++
+			t.Cleanup(func() { cleaned++ })
+			return intModel(0), []Option{WithUpdater(updater)}
+		})
+	})
+	if created != 2 || cleaned != 2 {
+		t.Errorf("expected 2 models created and cleaned up, got %d/%d", created, cleaned)
+	}
+}
+
+// TestWalkShard checks that the test files are distributed
+// across shards.
+func TestWalkShard(t *testing.T) {
+	var created []string
+	factory := func(t testing.TB) (tea.Model, []Option) {
+		created = append(created, t.Name())
+		return intModel(0), []Option{WithUpdater(updater)}
+	}
+	t.Run("shard0", func(t *testing.T) { WalkShard(t, "testdata/walk", 0, 2, factory) })
+	t.Run("shard1", func(t *testing.T) {
+		defer func() { _ = os.Unsetenv("CATWALK_SHARD_INDEX") }()
+		defer func() { _ = os.Unsetenv("CATWALK_SHARD_TOTAL") }()
+		if err := os.Setenv("CATWALK_SHARD_INDEX", "1"); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Setenv("CATWALK_SHARD_TOTAL", "2"); err != nil {
+			t.Fatal(err)
+		}
+		Walk(t, "testdata/walk", factory)
+	})
+	const expected = "TestWalkShard/shard0/first TestWalkShard/shard1/second"
+	if actual := strings.Join(created, " "); actual != expected {
+		t.Errorf("expected %s, got %s", expected, actual)
+	}
+}
+
 type intModel int
 
 var _ tea.Model = intModel(0)
@@ -133,6 +426,11 @@ func (cmdModel) Update(tea.Msg) (tea.Model, tea.Cmd) {
 func (cmdModel) View() string { return "" }
 
 func cmdUpdater(m tea.Model, cmd string, args ...string) (bool, tea.Model, tea.Cmd, error) {
+	if cmd == "nested" {
+		return true, m, tea.Sequence(
+			tea.Batch(tea.Println("nest1"), tea.Sequence(tea.Println("nest2"), tea.Println("nest3"))),
+			tea.Println("nest4")), nil
+	}
 	return true, m, tea.Batch(
 		tea.Println("tupd1"),
 		tea.Sequence(tea.Println("tupd2"), tea.Println("tupd3"))), nil
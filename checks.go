@@ -0,0 +1,373 @@
+package catwalk
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+	"unsafe"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/kr/pretty"
+)
+
+// WithViewPurityCheck tells the test driver to check that the
+// model's View() method is pure: after each observation, View() is
+// called twice and the test fails if the results differ; the test
+// also fails if View() returns a different result from the previous
+// observation while the model was not updated in-between.
+//
+// This catches views that mutate the model or depend on time or
+// randomness. This can also be changed with `set check_view`.
+func WithViewPurityCheck() Option {
+	return func(d *driver) {
+		d.checkView = true
+	}
+}
+
+// modelUpdated is called every time the model may have changed.
+func (d *driver) modelUpdated() {
+	d.viewChecked = false
+	d.obsCache = nil
+}
+
+// checkViewPurity implements the check configured by
+// WithViewPurityCheck.
+func (d *driver) checkViewPurity(t TB) {
+	if !d.checkView {
+		return
+	}
+	v1 := d.m.View()
+	v2 := d.m.View()
+	if v1 != v2 {
+		t.Fatalf("%s: View() is not pure: consecutive calls returned different results:\n%s\n--- vs ---\n%s", d.pos, v1, v2)
+	}
+	if d.viewChecked && v1 != d.lastView {
+		t.Fatalf("%s: View() changed without an update to the model:\n%s\n--- vs ---\n%s", d.pos, d.lastView, v1)
+	}
+	d.lastView = v1
+	d.viewChecked = true
+}
+
+// WithUpdateImmutabilityCheck tells the test driver to check that
+// the model's Update() method does not mutate the original model,
+// when the model is passed by value. The model is deep-copied before
+// each call to Update() and compared to the original afterwards.
+//
+// This catches accidental mutations through embedded pointers,
+// slices or maps, which are only visible under real bubbletea
+// concurrency. This can also be changed with `set check_update`.
+func WithUpdateImmutabilityCheck() Option {
+	return func(d *driver) {
+		d.checkUpdate = true
+	}
+}
+
+// WithConcurrentView tells the test driver to call the model's
+// View() method concurrently with every call to Update(), as
+// bubbletea's renderer can. This makes `go test -race` report data
+// races between the two paths, for example when a model passed by
+// reference is mutated by Update() while being rendered.
+//
+// This can also be changed with `set concurrent_view`.
+func WithConcurrentView() Option {
+	return func(d *driver) {
+		d.concurrentView = true
+	}
+}
+
+// callUpdate calls update, which should call Update() on m. If
+// configured with WithConcurrentView, m.View() is called
+// concurrently.
+func (d *driver) callUpdate(m tea.Model, update func() (tea.Model, tea.Cmd)) (tea.Model, tea.Cmd) {
+	if !d.concurrentView {
+		return update()
+	}
+	var wg sync.WaitGroup
+	wg.Add(1)
+	start := make(chan struct{})
+	go func() {
+		defer wg.Done()
+		<-start
+		_ = m.View()
+	}()
+	close(start)
+	newM, newCmd := update()
+	wg.Wait()
+	return newM, newCmd
+}
+
+// snapshotModel returns a deep copy of the model, or an invalid
+// value if the model is not passed by value.
+func (d *driver) snapshotModel() reflect.Value {
+	v := reflect.ValueOf(d.m)
+	if !v.IsValid() || v.Kind() == reflect.Ptr {
+		return reflect.Value{}
+	}
+	return deepCopy(v, make(map[visit]copied))
+}
+
+// checkModelUnchanged compares the model passed to Update() to its
+// snapshot. The first difference found is reported by
+// checkUpdateViolation.
+func (d *driver) checkModelUnchanged(m tea.Model, snapshot reflect.Value, msg tea.Msg) {
+	if !snapshot.IsValid() || d.updateViolation != "" {
+		return
+	}
+	if diff := pretty.Diff(snapshot.Interface(), m); len(diff) > 0 {
+		d.updateViolation = fmt.Sprintf("Update(%T) mutated the original model:\n%s", msg, strings.Join(diff, "\n"))
+	}
+}
+
+func (d *driver) checkUpdateViolation(t TB) {
+	if d.updateViolation != "" {
+		t.Fatalf("%s: %s", d.pos, d.updateViolation)
+	}
+}
+
+type visit struct {
+	ptr uintptr
+	typ reflect.Type
+}
+
+// copied is a pointer copied by deepCopy, together with the original
+// pointer.
+type copied struct {
+	orig, copy reflect.Value
+}
+
+// deepCopy returns a deep copy of v, including the unexported fields
+// of structs. Funcs and channels are shared.
+func deepCopy(v reflect.Value, seen map[visit]copied) reflect.Value {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v
+		}
+		k := visit{v.Pointer(), v.Type()}
+		if c, ok := seen[k]; ok {
+			return c.copy
+		}
+		c := reflect.New(v.Type().Elem())
+		seen[k] = copied{orig: v, copy: c}
+		c.Elem().Set(deepCopy(v.Elem(), seen))
+		return c
+
+	case reflect.Struct:
+		if !v.CanAddr() {
+			tmp := reflect.New(v.Type()).Elem()
+			tmp.Set(v)
+			v = tmp
+		}
+		c := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.NumField(); i++ {
+			accessible(c.Field(i)).Set(deepCopy(accessible(v.Field(i)), seen))
+		}
+		return c
+
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeSlice(v.Type(), v.Len(), v.Cap())
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(deepCopy(v.Index(i), seen))
+		}
+		return c
+
+	case reflect.Array:
+		c := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(deepCopy(v.Index(i), seen))
+		}
+		return c
+
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			c.SetMapIndex(iter.Key(), deepCopy(iter.Value(), seen))
+		}
+		return c
+
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.New(v.Type()).Elem()
+		c.Set(deepCopy(v.Elem(), seen))
+		return c
+
+	default:
+		return v
+	}
+}
+
+// accessible makes an addressable value settable and readable even
+// if it was obtained via an unexported struct field.
+func accessible(v reflect.Value) reflect.Value {
+	return reflect.NewAt(v.Type(), unsafe.Pointer(v.UnsafeAddr())).Elem()
+}
+
+// restoreInPlace restores dst, an addressable value, to the state
+// of snap, a deep copy of dst taken with deepCopy and the given seen
+// map. Unlike a plain assignment of the copy, the values pointed to
+// by dst are restored in-place, so that the pointers into the
+// original values, e.g. captured by an updater, remain valid.
+func restoreInPlace(dst, snap reflect.Value, seen map[visit]copied) {
+	// Map the pointers of the copy to those of the original.
+	orig := make(map[uintptr]reflect.Value, len(seen))
+	for _, c := range seen {
+		orig[c.copy.Pointer()] = c.orig
+	}
+	restoreValue(dst, snap, orig, make(map[uintptr]bool))
+}
+
+func restoreValue(dst, snap reflect.Value, orig map[uintptr]reflect.Value, done map[uintptr]bool) {
+	switch snap.Kind() {
+	case reflect.Ptr:
+		if snap.IsNil() {
+			dst.Set(snap)
+			return
+		}
+		o, ok := orig[snap.Pointer()]
+		if !ok {
+			dst.Set(snap)
+			return
+		}
+		dst.Set(o)
+		if !done[snap.Pointer()] {
+			done[snap.Pointer()] = true
+			restoreValue(o.Elem(), snap.Elem(), orig, done)
+		}
+
+	case reflect.Struct:
+		if !snap.CanAddr() {
+			tmp := reflect.New(snap.Type()).Elem()
+			tmp.Set(snap)
+			snap = tmp
+		}
+		for i := 0; i < snap.NumField(); i++ {
+			restoreValue(accessible(dst.Field(i)), accessible(snap.Field(i)), orig, done)
+		}
+
+	case reflect.Array:
+		for i := 0; i < snap.Len(); i++ {
+			restoreValue(dst.Index(i), snap.Index(i), orig, done)
+		}
+
+	case reflect.Slice:
+		if snap.IsNil() {
+			dst.Set(snap)
+			return
+		}
+		c := reflect.MakeSlice(snap.Type(), snap.Len(), snap.Cap())
+		for i := 0; i < snap.Len(); i++ {
+			restoreValue(c.Index(i), snap.Index(i), orig, done)
+		}
+		dst.Set(c)
+
+	case reflect.Map:
+		if snap.IsNil() {
+			dst.Set(snap)
+			return
+		}
+		c := reflect.MakeMapWithSize(snap.Type(), snap.Len())
+		iter := snap.MapRange()
+		for iter.Next() {
+			val := reflect.New(snap.Type().Elem()).Elem()
+			restoreValue(val, iter.Value(), orig, done)
+			c.SetMapIndex(iter.Key(), val)
+		}
+		dst.Set(c)
+
+	case reflect.Interface:
+		if snap.IsNil() {
+			dst.Set(snap)
+			return
+		}
+		val := reflect.New(snap.Elem().Type()).Elem()
+		restoreValue(val, snap.Elem(), orig, done)
+		dst.Set(val)
+
+	default:
+		dst.Set(snap)
+	}
+}
+
+// WithViewBudget tells the test driver to time every call to the
+// model's View() method by the view observer, and to fail the test
+// if a call takes longer than the given budget. This is a cheap
+// guard against accidentally quadratic rendering code, e.g. in long
+// lists. This can also be changed with `set view_budget`.
+func WithViewBudget(budget time.Duration) Option {
+	return func(d *driver) {
+		d.viewBudget = budget
+		d.viewBudgetWarn = false
+	}
+}
+
+// WithViewBudgetWarning is like WithViewBudget, but the View() calls
+// exceeding the budget are only reported in the test log and do not
+// fail the test.
+func WithViewBudgetWarning(budget time.Duration) Option {
+	return func(d *driver) {
+		d.viewBudget = budget
+		d.viewBudgetWarn = true
+	}
+}
+
+// recordViewTime checks the duration of a call to View() against
+// the budget configured with WithViewBudget. The violation is
+// reported by checkViewBudget.
+func (d *driver) recordViewTime(elapsed time.Duration) {
+	if d.viewBudget > 0 && elapsed > d.viewBudget {
+		d.viewBudgetViolation = fmt.Sprintf("View() took %s, over the budget of %s", elapsed, d.viewBudget)
+	}
+}
+
+func (d *driver) checkViewBudget(t TB) {
+	if d.viewBudgetViolation == "" {
+		return
+	}
+	msg := d.viewBudgetViolation
+	d.viewBudgetViolation = ""
+	if d.viewBudgetWarn {
+		t.Logf("%s: warning: %s", d.pos, msg)
+		return
+	}
+	t.Fatalf("%s: %s", d.pos, msg)
+}
+
+// WithMaxOutputSize tells the test driver to fail the test when the
+// output of a single run directive exceeds the given number of
+// bytes. This protects CI logs from runaway observers, for example
+// when a model accidentally renders megabytes of content. This can
+// also be changed with `set max_output_size`.
+func WithMaxOutputSize(bytes int) Option {
+	return func(d *driver) {
+		d.maxOutputSize = bytes
+	}
+}
+
+// outputPreviewSize is the number of bytes of the output reported
+// when it exceeds the maximum size.
+const outputPreviewSize = 512
+
+// checkOutputSize implements the check configured by
+// WithMaxOutputSize.
+func (d *driver) checkOutputSize(t TB, out string) {
+	if d.maxOutputSize <= 0 || len(out) <= d.maxOutputSize {
+		return
+	}
+	preview := out
+	if len(preview) > outputPreviewSize {
+		preview = preview[:outputPreviewSize] + "..."
+	}
+	t.Fatalf("%s: the output (%d bytes) exceeds the limit of %d bytes; it starts with:\n%s",
+		d.pos, len(out), d.maxOutputSize, preview)
+}
@@ -0,0 +1,221 @@
+package catwalk
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unsafe"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// scheduledInput is an input command scheduled with the
+// after input command.
+type scheduledInput struct {
+	// at is the virtual time at which the input is applied.
+	at   time.Duration
+	cmd  string
+	args []string
+}
+
+func (in scheduledInput) String() string {
+	return strings.Join(append([]string{in.cmd}, in.args...), " ")
+}
+
+// scheduleInput implements the after input command: the input
+// command is applied when the virtual time reaches the given delay
+// from now, as advanced by the advance input command.
+func (d *driver) scheduleInput(t TB, trace bool, args ...string) {
+	if len(args) < 2 {
+		t.Fatalf("%s: syntax: after <duration> <cmd> <args...>", d.pos)
+	}
+	delay, err := time.ParseDuration(args[0])
+	if err != nil || delay < 0 {
+		t.Fatalf("%s: invalid delay: %q", d.pos, args[0])
+	}
+	in := scheduledInput{at: d.now + delay, cmd: args[1], args: args[2:]}
+	d.trace(trace, "scheduled %q at %s", in.String(), in.at)
+	// Keep the inputs sorted by time; inputs scheduled at the same
+	// time are applied in the order they were scheduled.
+	i := sort.Search(len(d.scheduled), func(i int) bool { return d.scheduled[i].at > in.at })
+	d.scheduled = append(d.scheduled, scheduledInput{})
+	copy(d.scheduled[i+1:], d.scheduled[i:])
+	d.scheduled[i] = in
+}
+
+// advanceClock implements the advance input command: the virtual
+// time moves forward by the given duration, and the scheduled inputs
+// and the deferred tick commands are applied in order as their time
+// is reached. The messages and commands they produce are processed
+// before the next input or tick. An input scheduled at the same time
+// as a tick is applied first.
+func (d *driver) advanceClock(t TB, trace bool, args ...string) {
+	if len(args) != 1 {
+		t.Fatalf("%s: syntax: advance <duration>", d.pos)
+	}
+	delta, err := time.ParseDuration(args[0])
+	if err != nil || delta < 0 {
+		t.Fatalf("%s: invalid duration: %q", d.pos, args[0])
+	}
+	end := d.now + delta
+	for d.fireNextEvent(t, trace, end) {
+	}
+	d.now = end
+	d.trace(trace, "virtual time is now %s", d.now)
+}
+
+// endOfTime is the largest virtual time.
+const endOfTime = time.Duration(math.MaxInt64)
+
+// fireNextEvent moves the virtual time to the earliest scheduled
+// input or deferred tick due at or before end, applies or fires it,
+// and processes the resulting messages and commands. It returns
+// false if there is no such event.
+func (d *driver) fireNextEvent(t TB, trace bool, end time.Duration) bool {
+	inputDue := len(d.scheduled) > 0 && d.scheduled[0].at <= end
+	tickDue := len(d.ticks) > 0 && d.ticks[0].at <= end
+	if !inputDue && !tickDue {
+		return false
+	}
+	if inputDue && (!tickDue || d.scheduled[0].at <= d.ticks[0].at) {
+		in := d.scheduled[0]
+		d.scheduled = d.scheduled[1:]
+		d.now = in.at
+		d.trace(trace, "at %s: applying %q", d.now, in.String())
+		d.applyInput(t, trace, in.cmd, in.args...)
+	} else {
+		tk := d.ticks[0]
+		d.ticks = d.ticks[1:]
+		d.now = tk.at
+		d.trace(trace, "at %s: firing %s", d.now, tk.kind)
+		d.origin = tk.origin
+		d.handleCmdResult(tk.fn(virtualEpoch.Add(d.now)), trace)
+	}
+	d.processTeaMsgs(trace)
+	d.processTeaCmds(trace)
+	return true
+}
+
+// pendingTick is a tea.Tick or tea.Every command deferred by the
+// virtual clock. See WithVirtualClock().
+type pendingTick struct {
+	// at is the virtual time at which the tick fires.
+	at time.Duration
+	// kind is either "tick" or "every".
+	kind   string
+	fn     func(time.Time) tea.Msg
+	origin string
+}
+
+// tickClosure is the layout of the closures returned by tea.Tick and
+// tea.Every, which capture the duration and the callback. This
+// depends on the internals of bubbletea at the version pinned in
+// go.mod; the layout is verified by checkTickLayout before the
+// virtual clock is enabled.
+type tickClosure struct {
+	pc uintptr
+	d  time.Duration
+	fn func(time.Time) tea.Msg
+}
+
+var (
+	tickCmdName  = cmdName(tea.Tick(0, nil))
+	everyCmdName = cmdName(tea.Every(0, nil))
+)
+
+var (
+	tickLayoutOnce sync.Once
+	tickLayoutErr  error
+)
+
+// errTickLayout returns an error if the closures returned by tea.Tick
+// and tea.Every do not have the layout of tickClosure, e.g. with a
+// version of bubbletea which implements them differently. The
+// virtual clock cannot be used in that case. The layout is only
+// checked the first time the virtual clock is enabled, so that the
+// tests which do not use it are not affected.
+func errTickLayout() error {
+	tickLayoutOnce.Do(func() { tickLayoutErr = checkTickLayout() })
+	return tickLayoutErr
+}
+
+// tickProbeMsg is the message produced by the callback used by
+// checkTickLayout.
+type tickProbeMsg struct{}
+
+// checkTickLayout verifies that the closures returned by tea.Tick
+// and tea.Every capture their duration and callback like
+// tickClosure, using a distinctive duration.
+func checkTickLayout() error {
+	const probe = 1234567 * time.Nanosecond
+	fn := func(time.Time) tea.Msg { return tickProbeMsg{} }
+	for _, cmd := range []tea.Cmd{tea.Tick(probe, fn), tea.Every(probe, fn)} {
+		c := *(**tickClosure)(unsafe.Pointer(&cmd))
+		// Check the duration before calling the callback, so as
+		// to not call an invalid function.
+		if c.pc != reflect.ValueOf(cmd).Pointer() || c.d != probe || c.fn == nil {
+			return fmt.Errorf("unsupported implementation of %s", cmdName(cmd))
+		}
+		if _, ok := c.fn(time.Time{}).(tickProbeMsg); !ok {
+			return fmt.Errorf("unsupported implementation of %s", cmdName(cmd))
+		}
+	}
+	return nil
+}
+
+// virtualEpoch is the time passed to the tick callbacks at the
+// virtual time 0.
+var virtualEpoch = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// deferTicks removes the tick commands from the given commands and
+// defers them with the virtual clock, if enabled.
+func (d *driver) deferTicks(cmds []tea.Cmd, origins []string, trace bool) ([]tea.Cmd, []string) {
+	if !d.virtualClock {
+		return cmds, origins
+	}
+	var resCmds []tea.Cmd
+	var resOrigins []string
+	for i, cmd := range cmds {
+		if !d.deferTick(cmd, origins[i], trace) {
+			resCmds = append(resCmds, cmd)
+			resOrigins = append(resOrigins, origins[i])
+		}
+	}
+	return resCmds, resOrigins
+}
+
+// deferTick defers the given command until the virtual time reaches
+// its deadline, if the virtual clock is enabled and the command was
+// produced by tea.Tick or tea.Every. It returns false otherwise.
+func (d *driver) deferTick(cmd tea.Cmd, origin string, trace bool) bool {
+	if !d.virtualClock || cmd == nil || errTickLayout() != nil {
+		return false
+	}
+	var kind string
+	switch cmdName(cmd) {
+	case tickCmdName:
+		kind = "tick"
+	case everyCmdName:
+		kind = "every"
+	default:
+		return false
+	}
+	c := *(**tickClosure)(unsafe.Pointer(&cmd))
+	tk := pendingTick{at: d.now + c.d, kind: kind, fn: c.fn, origin: d.cmdOriginOf(cmd, origin)}
+	if kind == "every" && c.d > 0 {
+		// Like tea.Every, align the deadline on the duration.
+		tk.at = (d.now/c.d + 1) * c.d
+	}
+	d.trace(trace, "deferred %s until %s", kind, tk.at)
+	// Keep the ticks sorted by time; ticks with the same deadline
+	// fire in the order they were deferred.
+	i := sort.Search(len(d.ticks), func(i int) bool { return d.ticks[i].at > tk.at })
+	d.ticks = append(d.ticks, pendingTick{})
+	copy(d.ticks[i+1:], d.ticks[i:])
+	d.ticks[i] = tk
+	return true
+}
@@ -0,0 +1,56 @@
+// Command catwalk-approve promotes the output received by
+// catwalk.RunApprovals to approved.
+//
+// Usage:
+//
+//	catwalk-approve [-list-orphans | -prune] [dir...]
+//
+// All the .received files under the given directories (by default,
+// the current directory) are renamed to .approved.
+//
+// With -list-orphans, the .approved and .received files whose test
+// file does not exist any more are listed instead. With -prune, they
+// are removed.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/knz/catwalk"
+)
+
+func main() {
+	listOrphans := flag.Bool("list-orphans", false, "list the approved and received files without a test file")
+	prune := flag.Bool("prune", false, "remove the approved and received files without a test file")
+	flag.Parse()
+
+	dirs := flag.Args()
+	if len(dirs) == 0 {
+		dirs = []string{"."}
+	}
+	for _, dir := range dirs {
+		var paths []string
+		var err error
+		var verb string
+		switch {
+		case *prune:
+			paths, err = catwalk.PruneOrphans(dir)
+			verb = "pruned"
+		case *listOrphans:
+			paths, err = catwalk.ListOrphans(dir)
+			verb = "orphan"
+		default:
+			paths, err = catwalk.ApproveAll(dir)
+			verb = "approved"
+		}
+		for _, path := range paths {
+			fmt.Printf("%s: %s\n", verb, path)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "catwalk-approve:", err)
+			os.Exit(1)
+		}
+	}
+}
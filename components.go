@@ -0,0 +1,81 @@
+package catwalk
+
+import (
+	"reflect"
+	"unsafe"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// component is a value of a well-known type found inside a model.
+type component struct {
+	// path is the path to the component from the model, for
+	// example "list" or "Pager.Model". It is "." for the model
+	// itself.
+	path string
+	// val is a copy of the component.
+	val interface{}
+}
+
+// findComponents returns the values of the types selected by match
+// which are reachable from the model via struct fields, pointers and
+// interfaces, in field order. Unexported fields are included. The
+// components themselves are not searched further.
+func findComponents(m tea.Model, match func(reflect.Type) bool) []component {
+	if m == nil {
+		return nil
+	}
+	// Start from an addressable copy of the model, so that the
+	// unexported fields can be copied out below.
+	root := reflect.New(reflect.TypeOf(m)).Elem()
+	root.Set(reflect.ValueOf(m))
+	var res []component
+	seen := make(map[uintptr]bool)
+	var walk func(v reflect.Value, path string)
+	walk = func(v reflect.Value, path string) {
+		if match(v.Type()) {
+			if path == "" {
+				path = "."
+			}
+			res = append(res, component{path: path, val: exportValue(v)})
+			return
+		}
+		switch v.Kind() {
+		case reflect.Ptr:
+			if v.IsNil() || seen[v.Pointer()] {
+				return
+			}
+			seen[v.Pointer()] = true
+			walk(v.Elem(), path)
+		case reflect.Interface:
+			if !v.IsNil() {
+				// The value in an interface is not addressable;
+				// make an addressable copy.
+				e := exportValue(v)
+				c := reflect.New(reflect.TypeOf(e)).Elem()
+				c.Set(reflect.ValueOf(e))
+				walk(c, path)
+			}
+		case reflect.Struct:
+			for i := 0; i < v.NumField(); i++ {
+				p := v.Type().Field(i).Name
+				if path != "" {
+					p = path + "." + p
+				}
+				walk(v.Field(i), p)
+			}
+		}
+	}
+	walk(root, "")
+	return res
+}
+
+// exportValue returns a copy of v, including when v was obtained
+// via unexported struct fields. v must be addressable, or
+// obtained without unexported fields.
+func exportValue(v reflect.Value) interface{} {
+	if v.CanInterface() {
+		return v.Interface()
+	}
+	return reflect.NewAt(v.Type(), unsafe.Pointer(v.UnsafeAddr())).Elem().Interface()
+}
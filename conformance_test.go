@@ -0,0 +1,185 @@
+package catwalk
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/cockroachdb/datadriven"
+)
+
+// seqMsg is a message recorded by recModel.
+type seqMsg string
+
+// recModel records the seqMsg messages it receives, and quits
+// after it has received the expected number of messages. When reply
+// is set, the model answers each message with the command it
+// returns.
+type recModel struct {
+	init  tea.Cmd
+	want  int
+	reply func(string) tea.Cmd
+	recv  []string
+}
+
+func (m *recModel) Init() tea.Cmd { return m.init }
+func (m *recModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if s, ok := msg.(seqMsg); ok {
+		m.recv = append(m.recv, string(s))
+		if len(m.recv) == m.want {
+			return m, tea.Quit
+		}
+		if m.reply != nil {
+			return m, m.reply(string(s))
+		}
+	}
+	return m, nil
+}
+func (m *recModel) View() string { return strings.Join(m.recv, " ") }
+
+func send(s string) tea.Cmd { return func() tea.Msg { return seqMsg(s) } }
+
+// runRealProgram runs the given command in a real bubbletea program
+// and returns the order in which the program delivered the messages.
+func runRealProgram(t *testing.T, cmd tea.Cmd, want int) string {
+	return runRealProgramModel(t, &recModel{init: cmd, want: want})
+}
+
+// runRealProgramModel is like runRealProgram, for a given model.
+func runRealProgramModel(t *testing.T, m *recModel) string {
+	p := tea.NewProgram(m,
+		tea.WithInput(&bytes.Buffer{}),
+		tea.WithOutput(ioutil.Discard),
+		tea.WithoutRenderer())
+	errc := make(chan error, 1)
+	go func() { errc <- p.Start() }()
+	select {
+	case err := <-errc:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		p.Kill()
+		t.Fatal("timeout waiting for program")
+	}
+	return m.View()
+}
+
+// runDriver runs the given command through the test driver and
+// returns the order in which the driver delivered the messages.
+func runDriver(t *testing.T, cmd tea.Cmd, want int, opts ...Option) string {
+	return runDriverModel(t, &recModel{init: cmd, want: want}, opts...)
+}
+
+// runDriverModel is like runDriver, for a given model.
+func runDriverModel(t *testing.T, m *recModel, opts ...Option) string {
+	d := NewDriver(m, opts...)
+	defer d.Close(t)
+	d.RunOneTest(t, &datadriven.TestData{Cmd: "run"})
+	return m.View()
+}
+
+// TestSequenceConformance checks that the driver delivers the
+// messages produced by tea.Sequence in the same order as a real
+// bubbletea program.
+func TestSequenceConformance(t *testing.T) {
+	td := []struct {
+		cmd  tea.Cmd
+		want int
+	}{
+		{tea.Sequence(send("a"), send("b"), send("c")), 3},
+		{tea.Batch(tea.Sequence(send("a"), send("b"), send("c"))), 3},
+		// Note: nested sequences are not compared here, because the
+		// version of bubbletea in use runs them asynchronously with
+		// respect to the enclosing sequence, so the resulting order
+		// is not deterministic.
+	}
+	for i, tc := range td {
+		t.Run(fmt.Sprint(i), func(t *testing.T) {
+			expected := runRealProgram(t, tc.cmd, tc.want)
+			actual := runDriver(t, tc.cmd, tc.want)
+			if expected != actual {
+				t.Errorf("expected %q, got %q", expected, actual)
+			}
+		})
+	}
+}
+
+// sendAfter is like send, but the command takes some time.
+func sendAfter(s string, delay time.Duration) tea.Cmd {
+	return func() tea.Msg {
+		time.Sleep(delay)
+		return seqMsg(s)
+	}
+}
+
+// TestNestedCmdConformance checks that, with WithProgramConformance,
+// the driver expands the batches and sequences nested in commands in
+// the same order as a real bubbletea program.
+func TestNestedCmdConformance(t *testing.T) {
+	const delay = 50 * time.Millisecond
+	td := []struct {
+		cmd  tea.Cmd
+		want int
+		// dflt is the order without WithProgramConformance.
+		dflt string
+	}{
+		{tea.Batch(tea.Batch(tea.Batch(send("a")))), 1, "a"},
+		{func() tea.Msg { return tea.Batch(send("a"))() }, 1, "a"},
+		// The batch nested in the sequence runs asynchronously in a
+		// program; the delay makes the order deterministic.
+		{tea.Sequence(tea.Batch(sendAfter("a", delay)), send("b")), 2, "a b"},
+		{tea.Sequence(tea.Sequence(sendAfter("a", delay), send("b")), send("c")), 3, "a b c"},
+	}
+	for i, tc := range td {
+		t.Run(fmt.Sprint(i), func(t *testing.T) {
+			expected := runRealProgram(t, tc.cmd, tc.want)
+			actual := runDriver(t, tc.cmd, tc.want, WithSyncCmds(), WithProgramConformance())
+			if expected != actual {
+				t.Errorf("expected %q, got %q", expected, actual)
+			}
+			if actual := runDriver(t, tc.cmd, tc.want, WithSyncCmds()); actual != tc.dflt {
+				t.Errorf("without conformance: expected %q, got %q", tc.dflt, actual)
+			}
+		})
+	}
+}
+
+// TestSequenceDeliveryConformance checks that the driver delivers
+// the messages of each member of a tea.Sequence, including the
+// messages which follow from them, before it runs the next member,
+// like a real bubbletea program. The delays make the order of the
+// program deterministic.
+func TestSequenceDeliveryConformance(t *testing.T) {
+	const delay = 50 * time.Millisecond
+	// Each message "a" is answered with a message "x".
+	reply := func(s string) tea.Cmd {
+		if s == "a" {
+			return send("x")
+		}
+		return nil
+	}
+	td := []struct {
+		cmd  tea.Cmd
+		want int
+	}{
+		{tea.Sequence(send("a"), sendAfter("b", delay)), 3},
+		// Batch inside Sequence.
+		{tea.Sequence(tea.Batch(send("a")), sendAfter("b", delay)), 3},
+		// Sequence inside Batch.
+		{tea.Batch(tea.Sequence(send("a"), sendAfter("b", delay)), sendAfter("c", 3*delay)), 4},
+	}
+	for i, tc := range td {
+		t.Run(fmt.Sprint(i), func(t *testing.T) {
+			expected := runRealProgramModel(t, &recModel{init: tc.cmd, want: tc.want, reply: reply})
+			actual := runDriverModel(t, &recModel{init: tc.cmd, want: tc.want, reply: reply}, WithSyncCmds())
+			if expected != actual {
+				t.Errorf("expected %q, got %q", expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package catwalk
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// WithStrictUpdaters tells the test driver to fail the test when
+// more than one updater would claim the same input command. By
+// default, the first updater in the chain which supports a command
+// silently shadows the following ones, which can hide mistakes when
+// several updaters handle overlapping commands, e.g. via generic
+// prefixes. This can also be changed with `set strict_updaters`.
+//
+// To detect the ambiguity, every updater is called on the model,
+// the results are discarded and the model is restored from a deep
+// copy, including the values it points to. The updaters must thus
+// not have side effects outside of the model.
+func WithStrictUpdaters() Option {
+	return func(d *driver) {
+		d.strictUpdaters = true
+	}
+}
+
+// label identifies the updater in messages: by name if it was
+// registered with WithNamedUpdater, by position in the chain
+// otherwise.
+func (u namedUpdater) label(idx int) string {
+	if u.name != "" {
+		return fmt.Sprintf("%q", u.name)
+	}
+	return fmt.Sprintf("#%d", idx+1)
+}
+
+// dispatchUpdaters passes the input command to the first updater
+// in the chain which supports it, like ChainUpdaters.
+func (d *driver) dispatchUpdaters(
+	t TB, cmd string, args ...string,
+) (supported bool, newModel tea.Model, teaCmd tea.Cmd) {
+	if d.strictUpdaters {
+		d.checkAmbiguousUpdaters(t, cmd, args...)
+	}
+	for i, u := range d.updaters {
+		if d.runTarget != "" && d.hasNamedUpdater(d.runTarget) && u.name != d.runTarget {
+			continue
+		}
+		supported, newModel, teaCmd, err := u.upd(d.m, cmd, args...)
+		if err != nil {
+			t.Fatalf("%s: updater %s error: %v", d.pos, u.label(i), err)
+		}
+		if supported {
+			d.trace(d.tracing, "command %q claimed by updater %s", cmd, u.label(i))
+			return true, newModel, teaCmd
+		}
+	}
+	return false, nil, nil
+}
+
+// checkAmbiguousUpdaters fails the test if more than one updater
+// claims the input command. An updater which returns an error
+// claims the command too.
+func (d *driver) checkAmbiguousUpdaters(t TB, cmd string, args ...string) {
+	var claims []string
+	for i, u := range d.updaters {
+		if d.runTarget != "" && d.hasNamedUpdater(d.runTarget) && u.name != d.runTarget {
+			continue
+		}
+		if d.dryRunUpdater(u.upd, cmd, args...) {
+			claims = append(claims, u.label(i))
+		}
+	}
+	if len(claims) > 1 {
+		t.Fatalf("%s: command %q is claimed by multiple updaters: %s",
+			d.pos, cmd, strings.Join(claims, ", "))
+	}
+}
+
+// dryRunUpdater returns true if the updater claims the input command.
+// The updater is called on the model, which is then restored
+// in-place from a deep copy, together with the values it points to.
+// This undoes the changes made by the updater, including those made
+// via pointers into the model captured by the updater, e.g. with
+// SimpleKeyMapApplier or SimpleStylesApplier.
+func (d *driver) dryRunUpdater(upd Updater, cmd string, args ...string) bool {
+	v := reflect.ValueOf(d.m)
+	if !v.IsValid() {
+		supported, _, _, err := upd(d.m, cmd, args...)
+		return supported || err != nil
+	}
+	seen := make(map[visit]copied)
+	snap := deepCopy(v, seen)
+	defer func() {
+		dst := reflect.New(v.Type()).Elem()
+		dst.Set(v)
+		restoreInPlace(dst, snap, seen)
+	}()
+	supported, _, _, err := upd(d.m, cmd, args...)
+	return supported || err != nil
+}
+
+// hasNamedUpdater returns true if an updater was registered with
+// WithNamedUpdater under the given name.
+func (d *driver) hasNamedUpdater(name string) bool {
+	for _, u := range d.updaters {
+		if u.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// setRunTarget implements the target argument of the run directive:
+// the input commands of the directive only reach the updater
+// registered under the given name with WithNamedUpdater, and/or the
+// messages they produce are delivered to the sub-model registered
+// under that name with WithSubModel.
+func (d *driver) setRunTarget(t TB, vals []string) {
+	if len(vals) != 1 {
+		t.Fatalf("%s: syntax: run target=<name>", d.pos)
+	}
+	name := vals[0]
+	if !d.hasNamedUpdater(name) {
+		if sub, ok := d.subModels[name]; !ok || sub.get == nil {
+			var names []string
+			for _, u := range d.updaters {
+				if u.name != "" {
+					names = append(names, u.name)
+				}
+			}
+			for n, s := range d.subModels {
+				if s.get != nil {
+					names = append(names, n)
+				}
+			}
+			sort.Strings(names)
+			if sugg := didYouMean(name, names); sugg != "" {
+				t.Fatalf("%s: unknown target %q%s", d.pos, name, sugg)
+			}
+			t.Fatalf("%s: unknown target %q, did you call WithNamedUpdater() or WithSubModel()?", d.pos, name)
+		}
+	}
+	d.runTarget = name
+}
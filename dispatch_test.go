@@ -0,0 +1,194 @@
+package catwalk
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/charmbracelet/bubbles/spinner"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/cockroachdb/datadriven"
+)
+
+func TestUpdaterDispatch(t *testing.T) {
+	prefixUpdater := func(m tea.Model, cmd string, args ...string) (bool, tea.Model, tea.Cmd, error) {
+		if len(cmd) > 0 && cmd[0] == 'd' {
+			return true, m.(intModel) + 10, nil, nil
+		}
+		return false, nil, nil, nil
+	}
+	opts := []Option{WithUpdater(updater), WithNamedUpdater("prefix", prefixUpdater)}
+
+	// The trace reports which updater claimed each command.
+	RunModelFromString(t, `
+run trace=on
+double
+delta
+----
+-- trace: calling Init
+-- trace: before "double"
+-- trace: command "double" claimed by updater #1
+-- trace: processing 1 cmds
+-- trace: translated cmd: tea.printLineMessage
+-- trace: after "double"
+-- view:
+VALUE: 0🛇
+-- trace: before "delta"
+-- trace: processing 1 messages
+-- trace: msg tea.printLineMessage{messageBody:"TEST UPDATE CALLED WITH double []"}
+TEA PRINT: {TEST UPDATE CALLED WITH double []}
+-- trace: command "delta" claimed by updater "prefix"
+-- trace: after "delta"
+-- view:
+VALUE: 10🛇
+-- trace: before finish
+-- view:
+VALUE: 10🛇
+-- trace: at end
+-- view:
+VALUE: 10🛇
+`, intModel(0), opts...)
+
+	// In strict mode, the ambiguous commands fail the test.
+	d := NewDriver(intModel(0), append(opts, WithStrictUpdaters())...)
+	defer d.Close(t)
+	run := func(input string) string {
+		return expectFatal(t, d, &datadriven.TestData{Pos: "test:1", Cmd: "run", Input: input})
+	}
+	if fatal := run("delta"); fatal != "" {
+		t.Errorf("unexpected error: %s", fatal)
+	}
+	if exp, fatal := `test:1: command "double" is claimed by multiple updaters: #1, "prefix"`, run("double"); fatal != exp {
+		t.Errorf("expected %q, got %q", exp, fatal)
+	}
+}
+
+func TestRunTarget(t *testing.T) {
+	adder := func(n intModel) Updater {
+		return func(m tea.Model, cmd string, args ...string) (bool, tea.Model, tea.Cmd, error) {
+			if cmd != "inc" {
+				return false, nil, nil, nil
+			}
+			switch m := m.(type) {
+			case valueCompositeModel:
+				m.n += int(n)
+				return true, m, nil, nil
+			default:
+				return true, m.(intModel) + n, nil, nil
+			}
+		}
+	}
+	RunModelFromString(t, `
+# Without a target, the first updater claims the command.
+run
+inc
+----
+-- view:
+parent: 1, child: VALUE: 0🛇
+
+run target=sidebar
+inc
+----
+-- view:
+parent: 101, child: VALUE: 0🛇
+
+# The messages are routed to the sub-model.
+run target=child
+type ab
+to child type c
+----
+-- view:
+parent: 101, child: VALUE: 3🛇
+
+# The target only applies to its run directive.
+run
+type a
+----
+-- view:
+parent: 102, child: VALUE: 3🛇
+`, valueCompositeModel{},
+		WithNamedUpdater("editor", adder(1)),
+		WithNamedUpdater("sidebar", adder(100)),
+		WithSubModel("child", func(m tea.Model) tea.Model { return m.(valueCompositeModel).child }),
+		WithSubModelSetter("child", func(m, c tea.Model) tea.Model {
+			vm := m.(valueCompositeModel)
+			vm.child = c.(intModel)
+			return vm
+		}))
+
+	d := NewDriver(intModel(0), WithNamedUpdater("editor", adder(1)))
+	defer d.Close(t)
+	fatal := expectFatal(t, d, &datadriven.TestData{
+		Pos: "test:1", Cmd: "run", CmdArgs: []datadriven.CmdArg{{Key: "target", Vals: []string{"editr"}}},
+	})
+	if exp := `test:1: unknown target "editr" (did you mean "editor"?)`; fatal != exp {
+		t.Errorf("expected %q, got %q", exp, fatal)
+	}
+}
+
+// counterState is pointed to by nestedModel.
+type counterState struct{ n int }
+
+// nestedModel holds its state behind a pointer.
+type nestedModel struct{ state *counterState }
+
+func (nestedModel) Init() tea.Cmd                         { return nil }
+func (m nestedModel) Update(tea.Msg) (tea.Model, tea.Cmd) { return m, nil }
+func (m nestedModel) View() string                        { return fmt.Sprintf("n: %d", m.state.n) }
+
+// TestStrictUpdatersCapturedModel checks that the dry run of the
+// updaters in strict mode does not change the model, even when the
+// updaters refer to the model directly.
+func TestStrictUpdatersCapturedModel(t *testing.T) {
+	m := &componentsModel{Spin: spinner.New()}
+	RunModelFromString(t, `
+run
+tick comps.Spin
+----
+-- view:
+input: value="" cursor=0␤
+list: index=0 filter=""␤
+oldlist: state= filter=""␤
+table: cursor=0␤
+spin: frame=1/4␤
+last msg: 🛇
+
+run
+tick comps.Spin
+----
+-- view:
+input: value="" cursor=0␤
+list: index=0 filter=""␤
+oldlist: state= filter=""␤
+table: cursor=0␤
+spin: frame=2/4␤
+last msg: 🛇
+`, m, WithStrictUpdaters(),
+		WithUpdater(StylesUpdater("styles", SimpleStylesApplier(&mystyles{}))),
+		WithUpdater(BubblesUpdater("comps", SimpleBubblesApplier(m))))
+
+	nm := nestedModel{state: &counterState{}}
+	inc := func(tm tea.Model, cmd string, args ...string) (bool, tea.Model, tea.Cmd, error) {
+		if cmd != "inc" {
+			return false, nil, nil, nil
+		}
+		// The updater refers to the state of the original model.
+		nm.state.n++
+		return true, tm, nil, nil
+	}
+	RunModelFromString(t, `
+run
+inc
+----
+-- view:
+n: 1🛇
+
+run
+inc
+----
+-- view:
+n: 2🛇
+`, nm, WithStrictUpdaters(), WithUpdater(inc),
+		WithUpdater(func(tea.Model, string, ...string) (bool, tea.Model, tea.Cmd, error) {
+			return false, nil, nil, nil
+		}))
+}
@@ -5,10 +5,17 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"go/ast"
 	"io"
+	"io/ioutil"
+	"math/rand"
+	"os"
 	"reflect"
+	"regexp"
+	"runtime"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
@@ -27,10 +34,105 @@ type driver struct {
 
 	// Queued commands left for processing.
 	cmds []tea.Cmd
+	// cmdOrigins and msgOrigins describe where each queued command
+	// and message comes from, for tracing. They are aligned with
+	// cmds and msgs respectively. See WithMsgProvenance().
+	cmdOrigins []string
+	msgOrigins []string
+	// origin describes what produces the commands and messages
+	// queued at this point, when msgProvenance is set.
+	origin        string
+	msgProvenance bool
+	// lastCmd is the command returned by the last call to
+	// Update(). See the expect_cmd input command.
+	lastCmd tea.Cmd
+	// initCmds is the names of the commands returned by the model's
+	// Init(), recorded before they are executed. It is nil if Init()
+	// was not called. See the initcmds observer.
+	initCmds []string
 
 	// cmdTimeout is how long to wait for a tea.Cmd
 	// to return a tea.Msg.
 	cmdTimeout time.Duration
+	// cmdTimeoutMax, when greater than cmdTimeout, is the hard cap
+	// up to which slow commands are waited for. See
+	// WithAdaptiveCmdTimeout().
+	cmdTimeoutMax time.Duration
+	// cmdDeadline, when set, bounds the wait for every command,
+	// e.g. during wait_init.
+	cmdDeadline time.Time
+	// abandonedCmds counts the commands abandoned after a timeout.
+	abandonedCmds int
+	// syncCmds, when set, runs commands to completion without
+	// a timeout. See WithSyncCmds().
+	syncCmds bool
+
+	// cmdStats, when set, enables tracing of command
+	// execution statistics.
+	cmdStats bool
+	// stats collects command execution statistics
+	// for the current run directive.
+	stats cmdStatistics
+
+	// concurrentCmds, when set, runs all the queued commands
+	// concurrently. The resulting messages are delivered in
+	// an order determined by rng.
+	concurrentCmds bool
+	rng            *rand.Rand
+
+	// envIsolation, when set, snapshots the environment when the
+	// driver is created; restoreEnv restores it when the driver is
+	// closed. See WithEnvIsolation().
+	envIsolation bool
+	restoreEnv   func()
+
+	// programConformance, when set, expands the commands nested in
+	// a tea.Sequence like a bubbletea program does. See
+	// WithProgramConformance().
+	programConformance bool
+
+	// cmdStubs are the simulated commands, by function name.
+	cmdStubs map[string]CmdStub
+
+	// listeners are notified of the driver's lifecycle events.
+	listeners []Listener
+
+	// recordTimings, when set, records the time spent in each
+	// directive and input command in timings.
+	recordTimings bool
+	timings       []Timing
+	// timingSummary, when set, reports a summary of
+	// the timings when the driver is closed.
+	timingSummary bool
+	// timingPath, when set, is the file where the timings
+	// are written as JSON when the driver is closed.
+	timingPath string
+
+	// reportDir, when set, is the directory where the HTML
+	// report is written when the driver is closed.
+	// See WithHTMLReport().
+	reportDir string
+	// report contains the directives recorded so far
+	// for the HTML report.
+	report []reportDirective
+	// reportCur is the directive being recorded.
+	reportCur *reportDirective
+
+	// artifactsDir, when set, is the directory where the failure
+	// artifacts are written. See WithFailureArtifacts().
+	artifactsDir string
+
+	// fileSummary, when set, accounts for the directives run
+	// for the summary of Walk.
+	fileSummary *FileSummary
+
+	// failureHandler, when set, is called for every directive
+	// that fails. See WithFailureHandler().
+	failureHandler func(*Failure)
+
+	// history records the messages delivered and
+	// commands executed so far.
+	history []HistoryEntry
 
 	// Queued messages left for processing.
 	msgs []tea.Msg
@@ -38,26 +140,312 @@ type driver struct {
 	// Test observers.
 	observers map[string]Observer
 
-	// Test model updater (optional).
-	upd Updater
+	// traceMode is the tracing mode for run directives that
+	// do not specify trace=: on, off or log.
+	traceMode string
+	// traceLog, when set, receives the trace output
+	// instead of the test output.
+	traceLog TB
+	// tracing is set while tracing is enabled in the current
+	// run directive.
+	tracing bool
+	// traceLines collects the trace output of the current
+	// directive, for the failure handler.
+	traceLines []string
+
+	// idGen, when set, generates the identifiers provided to
+	// models which implement IDConsumer. See WithIDGenerator().
+	idGen func() string
+	// idSeq is the last identifier generated by the built-in
+	// ID generator.
+	idSeq int
+
+	// fieldLocator, when set, determines the focused field for
+	// the fill input command. See WithFieldLocator().
+	fieldLocator FieldLocator
+
+	// vars contains the variables substituted in input
+	// commands. See WithVariable().
+	vars map[string]string
+
+	// observe is the list of observers to use in run
+	// directives that do not specify observe=.
+	observe []string
+
+	// newlineMarker is printed at the end of every line
+	// in the view observer.
+	newlineMarker string
+	// eofMarker is printed at the end of the view when
+	// it does not end with a newline.
+	eofMarker string
+
+	// stateCodec serializes the model for the dumpstate and
+	// loadstate commands. See WithStateCodec().
+	stateCodec StateCodec
+
+	// keyLayouts are the keyboard layouts registered with
+	// WithKeyLayout(); keyLayout is the name of the active layout.
+	keyLayouts map[string]KeyLayout
+	keyLayout  string
+
+	// keyAliases maps additional key names to the names of
+	// special keys. See WithKeyAliases().
+	keyAliases map[string]string
+	// coalesceKeys, when set, delivers the text of the type, enter
+	// and fill input commands as a single key message. See
+	// WithKeyCoalescing().
+	coalesceKeys bool
+
+	// observerFilters are the transformations applied to the
+	// output of each observer. See WithObserverFilter().
+	observerFilters map[string][]func(string) string
+
+	// cachedObservers are the observers whose output is reused
+	// while the model does not change; obsCache contains their last
+	// output, keyed by observation. See WithObserverCaching().
+	cachedObservers map[string]bool
+	obsCache        map[string]string
+
+	// maxOutputSize, when positive, is the maximum size in bytes
+	// of the output of a directive. See WithMaxOutputSize().
+	maxOutputSize int
+
+	// truncateWidth, when positive, is the maximum display width
+	// of observed lines. See WithTruncatedLines().
+	truncateWidth int
+
+	// markerLabels overrides the labels used to report special
+	// messages in the test output. See WithMarkerLabels().
+	markerLabels map[string]string
+
+	// placeholders, when set, enables placeholders in the
+	// expected output. See WithPlaceholders().
+	placeholders bool
+
+	// ignoreLines are the patterns of the lines ignored when
+	// comparing the output. See WithIgnoreLines().
+	ignoreLines []*regexp.Regexp
+
+	// showPrints, when set, reports the tea.Println
+	// messages in the test output.
+	showPrints bool
+
+	// observeHeader is the header printed before each observation.
+	// Its first "%s" is replaced by the observer name.
+	observeHeader string
+	// observeSeparator, if non-empty, is printed between
+	// consecutive observations.
+	observeSeparator string
+
+	// updaterCmds are the input commands supported by the
+	// updaters, as declared with WithUpdaterCommands().
+	updaterCmds []string
+	// updaterCmdSites and observerSites record where each updater
+	// command and observer was registered, to report collisions.
+	updaterCmdSites map[string]string
+	observerSites   map[string]string
+
+	// runArgHandlers are the custom arguments of the run directive,
+	// registered with WithRunArg, in registration order.
+	runArgHandlers []namedRunArg
+
+	// Test model updaters (optional), in the order they
+	// were registered. See dispatchUpdaters().
+	updaters []namedUpdater
+	// runTarget is the updater or sub-model targeted by the
+	// current run directive. See setRunTarget().
+	runTarget string
+	// strictUpdaters, when set, fails the test when multiple
+	// updaters claim the same command. See WithStrictUpdaters().
+	strictUpdaters bool
 
 	startDone bool
 
+	// externalMsgs, when non-nil, queues the messages sent
+	// from outside of the driver. See WithExternalSender().
+	externalMsgs chan tea.Msg
+	// senderSetup is the function which hands the send function
+	// to the model.
+	senderSetup func(send func(tea.Msg))
+
+	// subscriptions are the active subscriptions, in the order
+	// they were registered. See Subscription.
+	subscriptions []Subscription
+
+	// maxIterations is the maximum number of messages and commands
+	// processed per run directive; 0 means unlimited.
+	maxIterations int
+	// iterations is the number of messages and commands processed
+	// so far in the current run directive.
+	iterations int
+	// loopDetected is set when iterations exceeds maxIterations.
+	loopDetected bool
+
+	// checkView, when set, checks that View() is pure
+	// after each observation. See WithViewPurityCheck().
+	checkView bool
+	// lastView is the result of View() at the last check.
+	// viewChecked is true if lastView is valid, i.e. the
+	// model has not been updated since it was computed.
+	lastView    string
+	viewChecked bool
+
+	// checkUpdate, when set, checks that Update() does not
+	// mutate by-value models. See WithUpdateImmutabilityCheck().
+	checkUpdate bool
+	// updateViolation describes the first mutation detected
+	// by the check.
+	updateViolation string
+
+	// frozen are the regions of the view declared with the
+	// freeze input command.
+	frozen []frozenRegion
+	// frozenViolation describes the first change detected
+	// in a frozen region.
+	frozenViolation string
+
+	// msgBuilders are the message builders defined with
+	// WithMessageBuilder.
+	msgBuilders map[string]MessageBuilder
+
+	// initialModel is the model at the start of the test, restored
+	// by the scenario directive.
+	initialModel modelSnapshot
+	// rewriteCheck, when set, re-runs the test file after it was
+	// rewritten. See WithRewriteCheck().
+	rewriteCheck bool
+	// scenario is the name of the current scenario. See the
+	// scenario directive.
+	scenario string
+
+	// configured restores the settings to the values configured
+	// with the options, for the reset directive.
+	configured map[string]func(d *driver) error
+
+	// normalizers are the normalizers defined with WithNormalizer.
+	normalizers map[string]Normalizer
+	// outputNormalizers are the rewrites applied to the output of
+	// the observers. See WithOutputNormalizer().
+	outputNormalizers []outputNormalizer
+	// normalized are the regions of the view declared with the
+	// normalize input command.
+	normalized []normalizedRegion
+
+	// concurrentView, when set, calls View() concurrently with
+	// Update(). See WithConcurrentView().
+	concurrentView bool
+
+	// viewBudget, when non-zero, is the maximum time a call
+	// to View() may take. See WithViewBudget().
+	viewBudget time.Duration
+	// viewBudgetWarn, when set, reports View() calls exceeding
+	// the budget in the test log instead of failing the test.
+	viewBudgetWarn bool
+	// viewBudgetViolation describes the last View() call which
+	// exceeded the budget.
+	viewBudgetViolation string
+
+	// subModels are the child components of the model, by name.
+	// See WithSubModel().
+	subModels map[string]*subModel
+	// focusables are the focusable components of the model, by
+	// name. See WithFocusable().
+	focusables map[string]focusable
+	// target, when set, is the sub-model which receives the
+	// messages instead of the model.
+	target *subModel
+
+	// deferProcessing, when set, disables the automatic processing
+	// of messages and commands until the next process input
+	// command. See the defer_processing input command.
+	deferProcessing bool
+
+	// breakpoints are the message types, as printed by %T, before
+	// which the observations are performed in the current run
+	// directive. See the break_on input command.
+	breakpoints map[string]bool
+	// breakObserve performs the observations of the current
+	// run directive.
+	breakObserve func()
+
+	// now is the virtual time elapsed since the beginning of
+	// the test, as advanced by the advance input command.
+	now time.Duration
+	// scheduled are the inputs scheduled with the after input
+	// command, in the order they will be applied.
+	scheduled []scheduledInput
+	// virtualClock, when set, defers the tea.Tick and tea.Every
+	// commands until the virtual time reaches their deadline.
+	// See WithVirtualClock().
+	virtualClock bool
+	// ticks are the tick commands deferred by the virtual clock,
+	// in the order they will fire.
+	ticks []pendingTick
+
+	// memGrowthLimit, when positive, is the maximum growth of the
+	// estimated size of the model. See WithMemGrowthLimit().
+	memGrowthLimit int64
+	// memBaseline is the first estimate of the size of the model,
+	// if memBaselineSet is true.
+	memBaseline    int64
+	memBaselineSet bool
+
+	// altScreen is true when the model is rendered in the
+	// alternate screen buffer.
+	altScreen bool
+	// initialAltScreen is the value of altScreen configured with
+	// WithAltScreen, restored by the scenario directive.
+	initialAltScreen bool
+
+	// updateCalls and viewCalls count the calls to Update() and
+	// View() in the current run directive. See the counters
+	// observer.
+	updateCalls int
+	viewCalls   int
+
 	// Don't call m.Init() on start.
 	disableAutoInit bool
 
+	// errMsg converts the argument of the senderr command
+	// to a tea.Msg.
+	errMsg func(error) tea.Msg
+
 	// Send a WindowSizeMsg on start.
 	autoSize bool
-	width    int
-	height   int
+	// width and height are the last window size delivered to the
+	// model, if sizeKnown is true.
+	width     int
+	height    int
+	sizeKnown bool
+	// altScreenResize, when set, re-sends the window size upon
+	// alternate screen transitions. See WithAltScreenResize().
+	altScreenResize bool
 
 	// pos is the position in the input data file.
 	// Used to produce error messages etc.
 	pos string
+	// steps counts the calls to Step, to report positions outside
+	// of the test files.
+	steps int
 }
 
 const defaultCmdTimeout time.Duration = 20 * time.Millisecond
 
+const defaultObserve = "view"
+
+const (
+	defaultNewlineMarker = "␤"
+	defaultEOFMarker     = "🛇"
+)
+
+const defaultObserveHeader = "-- %s:"
+
+const defaultMaxIterations = 10000
+
+// numLoopHistory is the number of history entries reported when
+// the iteration limit is reached.
+const numLoopHistory = 10
+
 // NewDriver creates a test driver for the given model.
 func NewDriver(m tea.Model, opts ...Option) Driver {
 	ctx, cancel := context.WithCancel(context.Background())
@@ -65,24 +453,63 @@ func NewDriver(m tea.Model, opts ...Option) Driver {
 		ctx:    ctx,
 		cancel: cancel,
 
-		m:          m,
-		cmdTimeout: defaultCmdTimeout,
-		observers: map[string]Observer{
-			"view":     observeView,
-			"debug":    observeDebug,
-			"gostruct": observeGoStruct,
-		},
+		m:             m,
+		initialModel:  takeModelSnapshot(m),
+		cmdTimeout:    defaultCmdTimeout,
+		observe:       []string{defaultObserve},
+		traceMode:     "off",
+		observeHeader: defaultObserveHeader,
+		errMsg:        func(err error) tea.Msg { return err },
+		newlineMarker: defaultNewlineMarker,
+		eofMarker:     defaultEOFMarker,
+		showPrints:    true,
+		maxIterations: defaultMaxIterations,
+		artifactsDir:  os.Getenv("CATWALK_ARTIFACTS_DIR"),
 	}
+	d.observers = map[string]Observer{
+		"view":     d.observeView,
+		"debug":    observeDebug,
+		"gostruct": observeGoStruct,
+		"links":    observeLinks,
+		"memsize":  observeMemSize,
+		"scroll":   observeScroll,
+		"bubbles":  observeBubbles,
+	}
+	d.recordBuiltinObservers()
 
 	for _, opt := range opts {
 		opt(d)
 	}
 
+	// Like ChainUpdaters, ignore the nil updaters.
+	upds := d.updaters[:0]
+	for _, u := range d.updaters {
+		if u.upd != nil {
+			upds = append(upds, u)
+		}
+	}
+	d.updaters = upds
+
+	d.snapshotSettings()
+	d.initialAltScreen = d.altScreen
+	d.setupExternalSender()
+	d.setupIDGenerator()
+	if d.envIsolation {
+		d.snapshotEnv()
+	}
+
 	return d
 }
 
 func (d *driver) trace(traceEnabled bool, format string, args ...interface{}) {
 	if traceEnabled {
+		if d.failureHandler != nil {
+			d.traceLines = append(d.traceLines, fmt.Sprintf(format, args...))
+		}
+		if d.traceLog != nil {
+			d.traceLog.Logf("%s: -- trace: "+format, append([]interface{}{d.pos}, args...)...)
+			return
+		}
 		fmt.Fprintf(&d.result, "-- trace: "+format+"\n", args...)
 	}
 }
@@ -91,55 +518,345 @@ func (d *driver) processTeaCmds(trace bool) {
 	if len(d.cmds) > 0 {
 		d.trace(trace, "processing %d cmds", len(d.cmds))
 	}
-	// TODO(knz): handle timeouts.
+	d.drainTeaCmds(trace)
+}
+
+// drainTeaCmds runs the queued commands, and the commands they
+// expand to, until there are no more commands to run.
+func (d *driver) drainTeaCmds(trace bool) {
 	var inputs []tea.Cmd
+	var origins []string
 	for {
 		if len(d.cmds) >= 0 {
 			inputs = append(make([]tea.Cmd, 0, len(d.cmds)+len(inputs)), inputs...)
 			inputs = append(inputs, d.cmds...)
-			d.cmds = nil
+			origins = append(append([]string(nil), origins...), d.cmdOrigins...)
+			d.cmds, d.cmdOrigins = nil, nil
 		}
+		inputs, origins = d.deferTicks(inputs, origins, trace)
 		if len(inputs) == 0 {
 			break
 		}
-		cmd := inputs[0]
-		inputs = inputs[1:]
-		msg := d.runTeaCmd(cmd, trace)
+		// Count the commands actually run in this pass: all of them
+		// in concurrent mode, only the first one otherwise.
+		n := 1
+		if d.concurrentCmds {
+			n = len(inputs)
+		}
+		if !d.countIterations(n) {
+			// Abandon the remaining commands; the loop is
+			// reported by checkIterations.
+			d.cmds, d.cmdOrigins = nil, nil
+			break
+		}
+		var msgs []tea.Msg
+		var msgOrigins []string
+		if d.concurrentCmds {
+			var order []int
+			msgs, order = d.runTeaCmdsConcurrently(inputs, trace)
+			for _, i := range order {
+				msgOrigins = append(msgOrigins, d.cmdOriginOf(inputs[i], origins[i]))
+			}
+			inputs, origins = nil, nil
+		} else {
+			cmd, origin := inputs[0], origins[0]
+			inputs, origins = inputs[1:], origins[1:]
+			msgs = []tea.Msg{d.runTeaCmd(cmd, trace)}
+			msgOrigins = []string{d.cmdOriginOf(cmd, origin)}
+		}
+		for i, msg := range msgs {
+			d.origin = msgOrigins[i]
+			d.handleCmdResult(msg, trace)
+		}
+	}
+}
 
-		if msg != nil {
-			rmsg := reflect.ValueOf(msg)
-			if rmsg.Type().ConvertibleTo(cmdsType) {
-				rcmds := rmsg.Convert(cmdsType)
-				cmds := rcmds.Interface().([]tea.Cmd)
-				d.trace(trace, "expanded %d commands", len(cmds))
-				d.addCmds(cmds...)
-				continue
+// handleCmdResult handles the message produced by a command:
+// either expands it into further commands, or queues it
+// for delivery to the model.
+func (d *driver) handleCmdResult(msg tea.Msg, trace bool) {
+	if msg != nil {
+		rmsg := reflect.ValueOf(msg)
+		if rmsg.Type().ConvertibleTo(cmdsType) {
+			rcmds := rmsg.Convert(cmdsType)
+			cmds := rcmds.Interface().([]tea.Cmd)
+			if rmsg.Type() == sequenceType {
+				d.trace(trace, "running sequence of %d commands", len(cmds))
+				d.runSequence(cmds, trace)
+				return
 			}
+			d.trace(trace, "expanded %d commands", len(cmds))
+			d.addCmds(cmds...)
+			return
 		}
+	}
 
-		d.trace(trace, "translated cmd: %T", msg)
-		d.addMsg(msg)
+	if sub, ok := msg.(Subscription); ok {
+		d.trace(trace, "registered subscription")
+		d.subscriptions = append(d.subscriptions, sub)
+		return
 	}
+
+	d.trace(trace, "translated cmd: %T", msg)
+	d.addMsg(msg)
 }
 
-func (d *driver) runTeaCmd(cmd tea.Cmd, trace bool) (res tea.Msg) {
-	ctx, cancel := context.WithTimeout(d.ctx, d.cmdTimeout)
+// runSequence runs the commands of a tea.Sequence one after
+// another. Each command, including all the commands it expands to
+// (e.g. via a nested tea.Batch), completes and its messages are
+// delivered to the model, together with the messages and commands
+// that follow from them, before the next command in the sequence
+// starts. The messages and commands queued before the sequence
+// are set aside while it runs, so that the messages delivered while
+// waiting for each step are only those of that step; they are
+// delivered after the sequence.
+func (d *driver) runSequence(cmds []tea.Cmd, trace bool) {
+	if d.programConformance {
+		d.runSequenceLikeProgram(cmds, trace)
+		return
+	}
+	pending, pendingOrigins := d.cmds, d.cmdOrigins
+	d.cmds, d.cmdOrigins = nil, nil
+	pendingMsgs, pendingMsgOrigins := d.msgs, d.msgOrigins
+	d.msgs, d.msgOrigins = nil, nil
+	origin := d.origin
+	for _, cmd := range cmds {
+		d.origin = origin
+		d.addCmds(cmd)
+		for !d.loopDetected {
+			d.drainTeaCmds(trace)
+			if len(d.msgs) == 0 {
+				break
+			}
+			d.processTeaMsgs(trace)
+		}
+	}
+	d.cmds = append(pending, d.cmds...)
+	d.cmdOrigins = append(pendingOrigins, d.cmdOrigins...)
+	d.msgs = append(pendingMsgs, d.msgs...)
+	d.msgOrigins = append(pendingMsgOrigins, d.msgOrigins...)
+}
+
+// runSequenceLikeProgram runs the commands of a tea.Sequence like a
+// bubbletea program does: the batches and sequences returned by the
+// commands are not waited for, and their commands are queued to run
+// after the sequence instead.
+func (d *driver) runSequenceLikeProgram(cmds []tea.Cmd, trace bool) {
+	origin := d.origin
+	for _, cmd := range cmds {
+		if cmd == nil || !d.countIterations(1) {
+			continue
+		}
+		d.origin = origin
+		if d.deferTick(cmd, origin, trace) {
+			continue
+		}
+		msg := d.runTeaCmd(cmd, trace)
+		if rmsg := reflect.ValueOf(msg); msg != nil && rmsg.Type().ConvertibleTo(cmdsType) {
+			nested := rmsg.Convert(cmdsType).Interface().([]tea.Cmd)
+			if rmsg.Type() == sequenceType {
+				d.trace(trace, "deferred nested sequence of %d commands", len(nested))
+				d.addCmds(func() tea.Msg { return msg })
+			} else {
+				d.trace(trace, "deferred nested batch of %d commands", len(nested))
+				d.addCmds(nested...)
+			}
+			continue
+		}
+		d.handleCmdResult(msg, trace)
+		// Like in a program, the message is delivered before the
+		// next command in the sequence runs.
+		d.processTeaMsgs(trace)
+	}
+}
+
+func (d *driver) runTeaCmd(cmd tea.Cmd, trace bool) tea.Msg {
+	d.emit(Event{Kind: EventCmdStarted, Cmd: cmdName(cmd)})
+	res, latency, timedOut := d.execTeaCmd(cmd)
+	d.recordCmd(trace, cmd, res, latency, timedOut)
+	return res
+}
+
+// runTeaCmdsConcurrently runs all the given commands concurrently,
+// like bubbletea does. The resulting messages are returned in an
+// order determined by the driver's seeded random source, together
+// with the index of the command which produced each message.
+func (d *driver) runTeaCmdsConcurrently(cmds []tea.Cmd, trace bool) ([]tea.Msg, []int) {
+	d.trace(trace, "running %d cmds concurrently", len(cmds))
+	type result struct {
+		msg      tea.Msg
+		latency  time.Duration
+		timedOut bool
+	}
+	results := make([]result, len(cmds))
+	var wg sync.WaitGroup
+	for i, cmd := range cmds {
+		d.emit(Event{Kind: EventCmdStarted, Cmd: cmdName(cmd)})
+		wg.Add(1)
+		go func(i int, cmd tea.Cmd) {
+			defer wg.Done()
+			r := &results[i]
+			r.msg, r.latency, r.timedOut = d.execTeaCmd(cmd)
+		}(i, cmd)
+	}
+	wg.Wait()
+
+	msgs := make([]tea.Msg, len(cmds))
+	order := make([]int, len(cmds))
+	for i, r := range results {
+		d.recordCmd(trace, cmds[i], r.msg, r.latency, r.timedOut)
+		msgs[i] = r.msg
+		order[i] = i
+	}
+	d.rng.Shuffle(len(msgs), func(i, j int) {
+		msgs[i], msgs[j] = msgs[j], msgs[i]
+		order[i], order[j] = order[j], order[i]
+	})
+	return msgs, order
+}
+
+// execTeaCmd runs one command, waiting at most for the
+// configured command timeout.
+func (d *driver) execTeaCmd(cmd tea.Cmd) (res tea.Msg, latency time.Duration, timedOut bool) {
+	limit := d.cmdWaitLimit()
+	if stub, ok := d.findCmdStub(cmd); ok {
+		if stub.Delay >= limit && !d.syncCmds {
+			return nil, limit, true
+		}
+		return stub.Msg, stub.Delay, false
+	}
+
+	if d.syncCmds {
+		start := time.Now()
+		res = cmd()
+		return res, time.Since(start), false
+	}
+
+	ctx, cancel := context.WithTimeout(d.ctx, limit)
 	defer cancel()
 
+	start := time.Now()
 	msg := make(chan tea.Msg, 1)
 	go func() {
 		msg <- cmd()
 	}()
 	select {
 	case <-ctx.Done():
-		d.trace(trace, "timeout waiting for command")
+		return nil, time.Since(start), true
 	case res = <-msg:
+		return res, time.Since(start), false
+	}
+}
+
+// cmdWaitLimit returns how long to wait for a command in total.
+func (d *driver) cmdWaitLimit() time.Duration {
+	limit := d.cmdTimeout
+	if d.cmdTimeoutMax > limit {
+		limit = d.cmdTimeoutMax
+	}
+	if !d.cmdDeadline.IsZero() {
+		if remaining := time.Until(d.cmdDeadline); remaining < limit {
+			limit = remaining
+		}
+	}
+	return limit
+}
+
+// cmdStatistics collects statistics about command execution.
+type cmdStatistics struct {
+	count    int
+	timeouts int
+	total    time.Duration
+	max      time.Duration
+	slowest  string
+}
+
+// recordCmd records the execution of one command in the history
+// and the statistics.
+func (d *driver) recordCmd(
+	trace bool, cmd tea.Cmd, res tea.Msg, latency time.Duration, timedOut bool,
+) {
+	d.history = append(d.history, HistoryEntry{
+		Kind:     HistoryCmd,
+		Pos:      d.pos,
+		Cmd:      cmdName(cmd),
+		Msg:      res,
+		TimedOut: timedOut,
+	})
+	d.emit(Event{Kind: EventCmdFinished, Cmd: cmdName(cmd), Msg: res, Latency: latency, TimedOut: timedOut})
+	d.recordCmdStats(trace, cmd, latency, timedOut)
+	if timedOut {
+		d.abandonedCmds++
+		d.trace(trace, "timeout waiting for command")
+	} else if latency > d.cmdTimeout && d.cmdTimeoutMax > d.cmdTimeout {
+		d.trace(trace, "cmd %s took %s, beyond cmd_timeout", cmdName(cmd), latency)
 	}
-	return res
+}
+
+// recordCmdStats records the execution of one command in the statistics.
+func (d *driver) recordCmdStats(trace bool, cmd tea.Cmd, latency time.Duration, timedOut bool) {
+	if !d.cmdStats {
+		return
+	}
+	name := cmdName(cmd)
+	d.stats.count++
+	d.stats.total += latency
+	if timedOut {
+		d.stats.timeouts++
+		d.trace(trace, "cmd %s timed out after %s", name, latency)
+	} else {
+		d.trace(trace, "cmd %s took %s", name, latency)
+	}
+	if latency > d.stats.max || d.stats.slowest == "" {
+		d.stats.max = latency
+		d.stats.slowest = name
+	}
+}
+
+// traceCmdStats reports the command statistics for the current
+// run directive.
+func (d *driver) traceCmdStats(trace bool) {
+	if !d.cmdStats || d.stats.count == 0 {
+		return
+	}
+	d.trace(trace, "cmd stats: %d executed, %d timed out, total %s, slowest %s (%s)",
+		d.stats.count, d.stats.timeouts, d.stats.total, d.stats.max, d.stats.slowest)
+}
+
+// findCmdStub returns the stub registered for the given command,
+// if any.
+func (d *driver) findCmdStub(cmd tea.Cmd) (CmdStub, bool) {
+	if len(d.cmdStubs) == 0 {
+		return CmdStub{}, false
+	}
+	name := cmdName(cmd)
+	for {
+		if stub, ok := d.cmdStubs[name]; ok {
+			return stub, true
+		}
+		// Strip the leading package path or component, and
+		// try again.
+		idx := strings.IndexAny(name, "./")
+		if idx < 0 {
+			return CmdStub{}, false
+		}
+		name = name[idx+1:]
+	}
+}
+
+// cmdName returns the name of the function implementing
+// the given command.
+func cmdName(cmd tea.Cmd) string {
+	f := runtime.FuncForPC(reflect.ValueOf(cmd).Pointer())
+	if f == nil {
+		return "<unknown>"
+	}
+	return f.Name()
 }
 
 var (
 	cmdsType       = reflect.TypeOf([]tea.Cmd{})
+	sequenceType   = reflect.TypeOf(tea.Sequence()())
 	printType      = reflect.TypeOf(tea.Println("hello")())
 	quitType       = reflect.TypeOf(tea.Quit())
 	execType       = reflect.TypeOf(tea.ExecProcess(nil, nil)())
@@ -156,41 +873,177 @@ func (d *driver) processTeaMsgs(trace bool) {
 	if len(d.msgs) > 0 {
 		d.trace(trace, "processing %d messages", len(d.msgs))
 	}
-	for _, msg := range d.msgs {
-		d.trace(trace, "msg %#v", msg)
-
-		switch reflect.TypeOf(msg) {
-		case printType:
-			fmt.Fprintf(&d.result, "TEA PRINT: %v\n", msg)
-		case szType:
-			fmt.Fprintf(&d.result, "TEA WINDOW SIZE: %v\n", msg)
-			// Window size is also visible to the model.
-			newM, newCmd := d.m.Update(msg)
-			d.m = newM
-			d.addCmds(newCmd)
-		case quitType:
-			fmt.Fprintf(&d.result, "TEA QUIT\n")
-		case execType:
-			fmt.Fprintf(&d.result, "TEA EXEC\n")
-		case hideCursorType:
-			fmt.Fprintf(&d.result, "TEA HIDE CURSOR\n")
-		case enterAltType:
-			fmt.Fprintf(&d.result, "TEA ENTER ALT\n")
-		case exitAltType:
-			fmt.Fprintf(&d.result, "TEA EXIT ALT\n")
-		case mouseCellType:
-			fmt.Fprintf(&d.result, "TEA ENABLE MOUSE CELL MOTION\n")
-		case mouseAllType:
-			fmt.Fprintf(&d.result, "TEA ENABLE MOUSE MOTION ALL\n")
-		case mouseDisType:
-			fmt.Fprintf(&d.result, "TEA DISABLE MOUSE\n")
-		default:
-			newM, newCmd := d.m.Update(msg)
-			d.m = newM
-			d.addCmds(newCmd)
+	// Messages queued during delivery, e.g. by setAltScreen, are
+	// delivered too.
+	for i := 0; i < len(d.msgs); i++ {
+		if !d.countIterations(1) {
+			break
 		}
+		d.deliverMsg(trace, d.msgs[i], d.msgOrigins[i])
 	}
 	d.msgs = d.msgs[:0]
+	d.msgOrigins = d.msgOrigins[:0]
+}
+
+// stepTeaMsg delivers the first queued message, if any.
+// This implements the step input command.
+func (d *driver) stepTeaMsg(trace bool) {
+	if len(d.msgs) == 0 {
+		d.trace(trace, "no message to deliver")
+		return
+	}
+	msg, origin := d.msgs[0], d.msgOrigins[0]
+	d.msgs, d.msgOrigins = d.msgs[1:], d.msgOrigins[1:]
+	if d.countIterations(1) {
+		d.deliverMsg(trace, msg, origin)
+	}
+}
+
+// deliverMsg delivers one message to the model, or reports it in
+// the test output if it is a special message.
+func (d *driver) deliverMsg(trace bool, msg tea.Msg, origin string) {
+	if d.msgProvenance {
+		d.trace(trace, "msg %s (from %s)", fmtMsg(msg), origin)
+		d.setOrigin("Update(%T)", msg)
+	} else {
+		d.trace(trace, "msg %s", fmtMsg(msg))
+	}
+	d.checkBreakpoint(msg)
+	d.history = append(d.history, HistoryEntry{Kind: HistoryMsg, Pos: d.pos, Msg: msg})
+	d.emit(Event{Kind: EventMsgDelivered, Msg: msg})
+
+	switch reflect.TypeOf(msg) {
+	case printType:
+		if d.showPrints {
+			fmt.Fprintf(&d.result, "%s: %v\n", d.label("TEA PRINT"), msg)
+		}
+	case szType:
+		fmt.Fprintf(&d.result, "%s: %v\n", d.label("TEA WINDOW SIZE"), msg)
+		sz := msg.(tea.WindowSizeMsg)
+		d.width, d.height, d.sizeKnown = sz.Width, sz.Height, true
+		// Window size is also visible to the model.
+		d.updateModel(msg)
+	case quitType:
+		fmt.Fprintln(&d.result, d.label("TEA QUIT"))
+	case execType:
+		fmt.Fprintln(&d.result, d.label("TEA EXEC"))
+	case hideCursorType:
+		fmt.Fprintln(&d.result, d.label("TEA HIDE CURSOR"))
+	case enterAltType:
+		fmt.Fprintln(&d.result, d.label("TEA ENTER ALT"))
+		d.setAltScreen(true)
+	case exitAltType:
+		fmt.Fprintln(&d.result, d.label("TEA EXIT ALT"))
+		d.setAltScreen(false)
+	case mouseCellType:
+		fmt.Fprintln(&d.result, d.label("TEA ENABLE MOUSE CELL MOTION"))
+	case mouseAllType:
+		fmt.Fprintln(&d.result, d.label("TEA ENABLE MOUSE MOTION ALL"))
+	case mouseDisType:
+		fmt.Fprintln(&d.result, d.label("TEA DISABLE MOUSE"))
+	default:
+		if isUnknownTeaMsg(msg) {
+			d.trace(trace, "unknown bubbletea message %T, passed to the model", msg)
+			d.emit(Event{Kind: EventUnknownMsg, Msg: msg})
+		}
+		d.updateModel(msg)
+	}
+}
+
+// isUnknownTeaMsg returns true if the message is of a type internal
+// to bubbletea, which the driver does not handle specially.
+func isUnknownTeaMsg(msg tea.Msg) bool {
+	typ := reflect.TypeOf(msg)
+	if typ == nil {
+		return false
+	}
+	name := typ.Name()
+	return typ.PkgPath() == quitType.PkgPath() &&
+		name != "" && !ast.IsExported(name)
+}
+
+// markerLabels are the default labels used to report special
+// messages in the test output.
+var markerLabels = map[string]bool{
+	"TEA PRINT":                    true,
+	"TEA WINDOW SIZE":              true,
+	"TEA QUIT":                     true,
+	"TEA EXEC":                     true,
+	"TEA HIDE CURSOR":              true,
+	"TEA ENTER ALT":                true,
+	"TEA EXIT ALT":                 true,
+	"TEA ENABLE MOUSE CELL MOTION": true,
+	"TEA ENABLE MOUSE MOTION ALL":  true,
+	"TEA DISABLE MOUSE":            true,
+}
+
+// label returns the label to use in the test output for
+// the special message with the given default label.
+func (d *driver) label(def string) string {
+	if l, ok := d.markerLabels[def]; ok {
+		return l
+	}
+	return def
+}
+
+// updateModel delivers a message to the model.
+func (d *driver) updateModel(msg tea.Msg) {
+	if d.target != nil {
+		d.updateSubModel(msg)
+		return
+	}
+	var snapshot reflect.Value
+	if d.checkUpdate {
+		snapshot = d.snapshotModel()
+	}
+	prevM := d.m
+	d.updateCalls++
+	newM, newCmd := d.callUpdate(prevM, func() (tea.Model, tea.Cmd) { return prevM.Update(msg) })
+	if d.checkUpdate {
+		d.checkModelUnchanged(prevM, snapshot, msg)
+	}
+	d.m = newM
+	d.modelUpdated()
+	d.checkFrozenRegions(msg)
+	d.lastCmd = newCmd
+	d.addCmds(newCmd)
+}
+
+// countIterations accounts for n messages or commands about to be
+// processed. It returns false if this exceeds the iteration limit.
+func (d *driver) countIterations(n int) bool {
+	if d.loopDetected {
+		return false
+	}
+	d.iterations += n
+	if d.maxIterations > 0 && d.iterations > d.maxIterations {
+		d.loopDetected = true
+		return false
+	}
+	return true
+}
+
+// checkIterations fails the test if the iteration limit was reached,
+// reporting the recent history of messages and commands.
+func (d *driver) checkIterations(t TB) {
+	if !d.loopDetected {
+		return
+	}
+	var buf strings.Builder
+	h := d.history
+	if len(h) > numLoopHistory {
+		h = h[len(h)-numLoopHistory:]
+	}
+	for _, e := range h {
+		switch e.Kind {
+		case HistoryMsg:
+			fmt.Fprintf(&buf, "\n  msg: %T", e.Msg)
+		case HistoryCmd:
+			fmt.Fprintf(&buf, "\n  cmd: %s -> %T", e.Cmd, e.Msg)
+		}
+	}
+	t.Fatalf("%s: more than %d messages and commands processed, possible infinite loop; recent history:%s",
+		d.pos, d.maxIterations, buf.String())
 }
 
 func (d *driver) addCmds(cmds ...tea.Cmd) {
@@ -199,6 +1052,7 @@ func (d *driver) addCmds(cmds ...tea.Cmd) {
 			continue
 		}
 		d.cmds = append(d.cmds, cmd)
+		d.cmdOrigins = append(d.cmdOrigins, d.origin)
 	}
 }
 
@@ -207,188 +1061,535 @@ func (d *driver) addMsg(msg tea.Msg) {
 		return
 	}
 	d.msgs = append(d.msgs, msg)
+	d.msgOrigins = append(d.msgOrigins, d.origin)
+}
+
+func (d *driver) PostMsg(msg tea.Msg) {
+	d.setOrigin("PostMsg()")
+	d.addMsg(msg)
+}
+
+func (d *driver) PostCmd(cmd tea.Cmd) {
+	d.setOrigin("PostCmd()")
+	d.addCmds(cmd)
+}
+
+func (d *driver) Step(t TB) bool {
+	d.steps++
+	defer func(prev string) { d.pos = prev }(d.pos)
+	d.pos = fmt.Sprintf("Step() #%d", d.steps)
+	// Each step has its own budget of iterations.
+	d.iterations = 0
+	d.loopDetected = false
+	d.updateViolation = ""
+	d.start(false, false)
+	if len(d.msgs) == 0 && len(d.cmds) == 0 {
+		return false
+	}
+	d.processTeaMsgs(false)
+	d.processTeaCmds(false)
+	d.checkIterations(t)
+	d.checkUpdateViolation(t)
+	return true
+}
+
+func (d *driver) Model() tea.Model {
+	return d.m
+}
+
+// start initializes the model, if not done yet. The commands
+// returned by Init are run if processInitCmds is set; otherwise they
+// are left in the queue.
+func (d *driver) start(trace bool, processInitCmds bool) {
+	if d.startDone {
+		return
+	}
+	if !d.disableAutoInit {
+		d.trace(trace, "calling Init")
+		d.emit(Event{Kind: EventInit})
+		d.setOrigin("Init()")
+		initCmd := d.m.Init()
+		d.initCmds = append([]string{}, expandCmdNames(initCmd)...)
+		d.addCmds(initCmd)
+		if processInitCmds {
+			d.processTeaCmds(trace)
+		}
+	}
+
+	if d.autoSize {
+		d.setOrigin("WithWindowSize()")
+		msg := tea.WindowSizeMsg{Width: d.width, Height: d.height}
+		d.addMsg(msg)
+	}
+	d.startDone = true
+}
+
+func (d *driver) History() []HistoryEntry {
+	return d.history
 }
 
 func (d *driver) Close(t TB) {
 	d.cancel()
+	d.reportTimings(t)
+	d.writeReport(t)
+	if d.restoreEnv != nil {
+		d.restoreEnv()
+	}
 }
 
-func (d *driver) RunOneTest(t TB, td *datadriven.TestData) string {
+func (d *driver) RunOneTest(t TB, td *datadriven.TestData) (output string) {
 	// Save the input position.
 	d.pos = td.Pos
+	d.traceLines = nil
+	if d.failureHandler != nil {
+		t = &failureTB{TB: t, d: d, td: td}
+	}
+
+	d.emit(Event{Kind: EventDirectiveStart, Directive: td.Cmd})
+	d.startReportDirective(td)
+	defer func(start time.Time) {
+		d.recordTiming(td.Cmd, start)
+		d.finishReportDirective(td, output)
+		d.writeFailureArtifacts(t, td, output)
+		d.reportFailure(t, td, output)
+		d.recordSummary(td, output)
+		d.emit(Event{Kind: EventDirectiveEnd, Directive: td.Cmd, Output: output})
+	}(time.Now())
 
 	switch td.Cmd {
 	case "set", "reset":
 		return d.handleSet(t, td)
+	case "scenario":
+		return d.startScenario(t, td)
 	case "run":
-		return d.handleRun(t, td)
+		out := d.handleRun(t, td)
+		d.checkOutputSize(t, out)
+		return d.matchExpected(t, td, out)
 	default:
 		t.Fatalf("%s: unrecognized test directive: %s", td.Pos, td.Cmd)
 		panic("unreachable")
 	}
 }
 
-func (d *driver) handleSet(t TB, td *datadriven.TestData) string {
-	reset := td.Cmd == "reset"
-	if len(td.CmdArgs) != 1 ||
-		(!reset && len(td.CmdArgs[0].Vals) != 1) ||
-		(reset && len(td.CmdArgs[0].Vals) != 0) {
-		t.Fatalf("%s: invalid syntax", d.pos)
-	}
-	key := td.CmdArgs[0].Key
-	val := ""
-	if !reset {
-		val = td.CmdArgs[0].Vals[0]
-	}
-
-	switch key {
-	case "cmd_timeout":
-		if reset {
-			val = defaultCmdTimeout.String()
-		}
-		tm, err := time.ParseDuration(val)
-		if err != nil {
-			t.Fatalf("%s: invalid timeout value: %v", d.pos, err)
-		}
-		d.cmdTimeout = tm
-		val = d.cmdTimeout.String()
-	default:
-		t.Fatalf("%s: unknown option %q", d.pos, key)
-	}
-	if reset {
-		return "ok"
-	}
-	return fmt.Sprintf("%s: %s", key, val)
-}
-
 func (d *driver) handleRun(t TB, td *datadriven.TestData) string {
 	d.result.Reset()
+	d.stats = cmdStatistics{}
+	d.iterations = 0
+	d.loopDetected = false
+	d.updateViolation = ""
+	d.frozenViolation = ""
+	d.updateCalls = 0
+	d.viewCalls = 0
+	d.breakpoints = nil
+
+	d.checkRunArgs(t, td.CmdArgs)
+	finish := d.startRunArgs(t, td.CmdArgs)
 
 	// Observations: check if there's an observe=() key
-	// on the first test input line. If not, just observe the view.
-	var observe []string
-	seen := false
+	// on the first test input line. If not, use the
+	// default observers configured with "set observe".
+	observe := d.observe
 	for i := range td.CmdArgs {
 		if td.CmdArgs[i].Key == "observe" {
 			observe = td.CmdArgs[i].Vals
-			seen = true
 			break
 		}
 	}
-	if !seen {
-		observe = []string{"view"}
+
+	// Tracing: use the trace=... argument if specified,
+	// otherwise the default configured with "set trace".
+	traceMode := d.traceMode
+	for i := range td.CmdArgs {
+		if td.CmdArgs[i].Key == "trace" {
+			traceMode = "on"
+			if len(td.CmdArgs[i].Vals) > 0 {
+				traceMode = td.CmdArgs[i].Vals[0]
+			}
+			break
+		}
+	}
+	if err := checkTraceMode(traceMode); err != nil {
+		t.Fatalf("%s: %v", d.pos, err)
 	}
+	traceEnabled := traceMode != "off"
+	d.tracing = traceEnabled
+	defer func() { d.tracing = false }()
 
-	traceEnabled := td.HasArg("trace")
+	// Scoping: use the target=... argument if specified.
+	for i := range td.CmdArgs {
+		if td.CmdArgs[i].Key == "target" {
+			d.setRunTarget(t, td.CmdArgs[i].Vals)
+			defer func() { d.runTarget = "" }()
+			break
+		}
+	}
+	if traceMode == "log" {
+		d.traceLog = t
+		defer func() { d.traceLog = nil }()
+	}
 	trace := func(format string, args ...interface{}) {
 		d.trace(traceEnabled, format, args...)
 	}
 
 	doObserve := func() {
-		for _, obs := range observe {
+		var buf strings.Builder
+		for i, obs := range observe {
+			if i > 0 && d.observeSeparator != "" {
+				buf.WriteString(d.observeSeparator)
+				buf.WriteByte('\n')
+			}
 			o := d.Observe(t, obs)
-			d.result.WriteString(o)
+			buf.WriteString(o)
 			// Terminate items with a newline if there's none yet.
-			if d.result.Len() > 0 {
-				if d.result.Bytes()[d.result.Len()-1] != '\n' {
-					d.result.WriteByte('\n')
-				}
+			if len(o) > 0 && o[len(o)-1] != '\n' {
+				buf.WriteByte('\n')
 			}
 		}
+		d.result.WriteString(buf.String())
+		d.checkViewPurity(t)
+		d.checkMemGrowth(t)
 	}
-
-	// Process the initialization, if not done yet.
-	if !d.startDone {
-		if !d.disableAutoInit {
-			trace("calling Init")
-			d.addCmds(d.m.Init())
-			d.processTeaCmds(traceEnabled)
+	d.breakObserve = doObserve
+	defer func() { d.breakObserve = nil }()
+	// traceObserve performs the observations for the trace,
+	// if enabled.
+	traceObserve := func() {
+		if !traceEnabled {
+			return
 		}
-
-		if d.autoSize {
-			msg := tea.WindowSizeMsg{Width: d.width, Height: d.height}
-			d.addMsg(msg)
+		if d.traceLog == nil {
+			doObserve()
+			return
 		}
-		d.startDone = true
+		// Observations are written to the log instead
+		// of the test output.
+		defer func(prevLen int) {
+			d.traceLog.Logf("%s:\n%s", d.pos, d.result.Bytes()[prevLen:])
+			d.result.Truncate(prevLen)
+		}(d.result.Len())
+		doObserve()
 	}
 
+	// Process the initialization, if not done yet.
+	d.start(traceEnabled, !startsWithWaitInit(td.Input))
+
 	// Process the commands in the test's input.
-	testInputCommands := strings.Split(td.Input, "\n")
+	testInputCommands := d.shuffleInputs(t, traceEnabled, strings.Split(td.Input, "\n"), td.CmdArgs)
 
+	cmdIndex := 0
 	for _, testInputCmd := range testInputCommands {
 		testInputCmd = strings.TrimSpace(testInputCmd)
 		if testInputCmd == "" || strings.HasPrefix(testInputCmd, "#") {
 			// Comment or emptyline.
 			continue
 		}
+		testInputCmd = d.expandVars(testInputCmd)
+		cmdIndex++
+		fullInputCmd := testInputCmd
 
 		trace("before %q", testInputCmd)
 
 		// If the previous testInputCmd produced
 		// some tea.Cmds, process them now.
-		d.processTeaMsgs(traceEnabled)
+		if !d.deferProcessing {
+			d.processTeaMsgs(traceEnabled)
+		}
 
 		// Apply the new testInputCmd.
+		d.setOrigin("input %q", testInputCmd)
 		args := strings.Split(testInputCmd, " ")
 		testInputCmd = args[0]
 		args = args[1:]
-		cmd := d.ApplyTextCommand(t, testInputCmd, args...)
-		d.addCmds(cmd)
-		d.processTeaCmds(traceEnabled)
+		start := time.Now()
+		d.applyInput(t, traceEnabled, testInputCmd, args...)
+		d.recordCmdTiming(td.Cmd, cmdIndex, fullInputCmd, start)
+		d.checkIterations(t)
+		d.checkUpdateViolation(t)
+		d.checkFrozenViolation(t)
 
 		if traceEnabled {
 			trace("after %q", testInputCmd)
-			doObserve()
+			traceObserve()
 		}
 	}
 
 	if traceEnabled {
 		trace("before finish")
-		doObserve()
+		traceObserve()
 	}
 	// Last round of command execution.
-	d.processTeaMsgs(traceEnabled)
-	d.processTeaCmds(traceEnabled)
-	d.processTeaMsgs(traceEnabled)
+	if !d.deferProcessing {
+		d.processTeaMsgs(traceEnabled)
+		d.processTeaCmds(traceEnabled)
+		d.processTeaMsgs(traceEnabled)
+	}
+	d.checkIterations(t)
+	d.checkUpdateViolation(t)
+	d.checkFrozenViolation(t)
 
+	d.traceCmdStats(traceEnabled)
 	trace("at end")
 	doObserve()
-	return d.result.String()
+	return finish(d.result.String())
+}
+
+// runArgs are the arguments accepted by the run directive.
+var runArgs = []string{"observe", "trace", "ignore_lines", "target", "shuffle"}
+
+// checkRunArgs fails the test if the run directive uses an
+// unknown argument.
+func (d *driver) checkRunArgs(t TB, args []datadriven.CmdArg) {
+	names := d.runArgNames()
+	for _, arg := range args {
+		known := false
+		for _, k := range names {
+			if arg.Key == k {
+				known = true
+				break
+			}
+		}
+		if !known {
+			t.Fatalf("%s: unknown argument %q for run%s", d.pos, arg.Key, didYouMean(arg.Key, names))
+		}
+	}
+}
+
+// applyInput applies one input command from a run directive, then
+// runs the tea.Cmds it produces.
+func (d *driver) applyInput(t TB, trace bool, cmd string, args ...string) {
+	switch cmd {
+	case "with_timeout":
+		if len(args) < 2 {
+			t.Fatalf("%s: syntax: with_timeout <duration> <cmd> <args...>", d.pos)
+		}
+		tm, err := time.ParseDuration(args[0])
+		if err != nil {
+			t.Fatalf("%s: invalid timeout value: %v", d.pos, err)
+		}
+		d.trace(trace, "using cmd timeout %s", tm)
+		defer func(prev time.Duration) { d.cmdTimeout = prev }(d.cmdTimeout)
+		d.cmdTimeout = tm
+		d.applyInput(t, trace, args[1], args[2:]...)
+		// Also deliver the resulting messages now, so that the
+		// commands produced by the model in response also use the
+		// custom timeout.
+		d.processTeaMsgs(trace)
+		d.processTeaCmds(trace)
+
+	case "wait_msgs":
+		d.waitExternalMsgs(t, trace, args...)
+
+	case "pump":
+		d.pumpSubscriptions(t, trace, args...)
+
+	case "focus", "blurcomponent":
+		d.setFocus(t, trace, cmd, args...)
+
+	case "to":
+		d.applyToSubModel(t, trace, args...)
+
+	case "wait_for":
+		d.waitFor(t, trace, args...)
+
+	case "wait_init":
+		d.waitInit(t, trace, args...)
+
+	case "after":
+		d.scheduleInput(t, trace, args...)
+
+	case "process":
+		d.assertArgc(t, args, 0)
+		d.deferProcessing = false
+		d.processTeaMsgs(trace)
+		d.processTeaCmds(trace)
+		d.processTeaMsgs(trace)
+
+	case "step":
+		d.assertArgc(t, args, 0)
+		d.stepTeaMsg(trace)
+
+	case "break_on":
+		d.addBreakpoints(t, trace, args...)
+
+	case "defer_processing":
+		d.assertArgc(t, args, 0)
+		d.trace(trace, "deferring processing")
+		d.deferProcessing = true
+
+	case "advance":
+		d.advanceClock(t, trace, args...)
+
+	case "expect_cmd", "expect_no_cmd":
+		d.expectCmd(t, trace, cmd, args...)
+
+	case "expect_any_of":
+		d.expectAnyOf(t, trace, args...)
+
+	case "reset_ids":
+		d.resetIDs(t, trace, args...)
+
+	case "fill":
+		d.fillForm(t, trace, args...)
+
+	case "resize_storm":
+		d.resizeStorm(t, trace, args...)
+
+	case "freeze":
+		d.freezeRegion(t, trace, args...)
+
+	case "unfreeze":
+		d.unfreezeRegions(t, trace, args...)
+
+	case "normalize":
+		d.normalizeRegion(t, trace, args...)
+
+	default:
+		if name, ok := subModelPrefix(cmd); ok {
+			d.applyToSubModel(t, trace, append([]string{name}, args...)...)
+			break
+		}
+		if sub, ok := d.subModels[d.runTarget]; ok && sub.get != nil && d.target == nil {
+			d.applyToSubModel(t, trace, append([]string{d.runTarget, cmd}, args...)...)
+			break
+		}
+		teaCmd := d.ApplyTextCommand(t, cmd, args...)
+		d.addCmds(teaCmd)
+		if !d.deferProcessing {
+			d.processTeaCmds(trace)
+		}
+	}
 }
 
 func (d *driver) Observe(t TB, what string) string {
 	var buf strings.Builder
-	fmt.Fprintf(&buf, "-- %s:\n", what)
-	switch what {
+	if d.observeHeader != "" {
+		buf.WriteString(strings.Replace(d.observeHeader, "%s", what, 1))
+		buf.WriteByte('\n')
+	}
+	d.observeBody(t, &buf, what)
+	d.emit(Event{Kind: EventObservation, Observer: what, Output: buf.String()})
+	d.recordReportFrame(what, buf.String())
+	return buf.String()
+}
+
+// observeBody writes the output of the given observer to buf, after
+// applying the filters configured with WithObserverFilter.
+func (d *driver) observeBody(t TB, out *strings.Builder, what string) {
+	var buf strings.Builder
+	builtin := what
+	if _, ok := d.observers[what]; ok {
+		// An observer registered with WithObserverOverride under
+		// the name of a built-in observer replaces it.
+		builtin = ""
+	}
+	switch builtin {
 	case "msgs":
 		fmt.Fprintf(&buf, "msg queue sz: %d\n", len(d.msgs))
 		for i, msg := range d.msgs {
+			if k, ok := msg.(tea.KeyMsg); ok {
+				fmt.Fprintf(&buf, "%d:%s\n", i, fmtMsg(k))
+				continue
+			}
 			t := reflect.TypeOf(msg)
 			fmt.Fprintf(&buf, "%d:%s: %v\n", i, t, msg)
 		}
 
 	case "cmds":
 		fmt.Fprintf(&buf, "command queue sz: %d\n", len(d.cmds))
+		if len(d.subscriptions) > 0 {
+			fmt.Fprintf(&buf, "active subscriptions: %d\n", len(d.subscriptions))
+		}
+
+	case "initcmds":
+		if d.initCmds == nil {
+			buf.WriteString("Init() not called\n")
+			break
+		}
+		fmt.Fprintf(&buf, "init cmds: %d\n", len(d.initCmds))
+		for i, name := range d.initCmds {
+			fmt.Fprintf(&buf, "%d:%s\n", i, name)
+		}
+
+	case "focus":
+		if err := d.observeFocus(&buf); err != nil {
+			t.Fatalf("%s: observing %q: %v", d.pos, what, err)
+		}
+
+	case "counters":
+		fmt.Fprintf(&buf, "update: %d\nview: %d\n", d.updateCalls, d.viewCalls)
+
+	case "screen":
+		if d.altScreen {
+			buf.WriteString("alt screen\n")
+		} else {
+			buf.WriteString("inline\n")
+		}
 
 	default:
-		obs, ok := d.observers[what]
+		m := d.m
+		obsName, subName, isSub := splitSubModel(what)
+		if isSub {
+			m = d.getSubModel(t, subName).get(m)
+		} else if obsName == "view" && len(d.normalized) > 0 {
+			m = normalizedModel{Model: m, d: d}
+		}
+		_, registered := d.observers[obsName]
+		if width, isHelp, err := parseHelpObserver(obsName); isHelp && !registered {
+			if err == nil {
+				err = observeHelp(&buf, m, width)
+			}
+			if err != nil {
+				t.Fatalf("%s: observing %q: %v", d.pos, what, err)
+			}
+			break
+		}
+		if path, isPath := parsePathObserver(obsName); isPath && !registered {
+			if err := observePath(&buf, m, path); err != nil {
+				t.Fatalf("%s: observing %q: %v", d.pos, what, err)
+			}
+			break
+		}
+		obs, ok := d.observers[obsName]
 		if !ok {
-			t.Fatalf("%s: unsupported observer %q, did you call WithObserver()?", d.pos, what)
+			if sugg := didYouMean(obsName, d.observerNames()); sugg != "" {
+				t.Fatalf("%s: unsupported observer %q%s", d.pos, obsName, sugg)
+			}
+			t.Fatalf("%s: unsupported observer %q, did you call WithObserver()?", d.pos, obsName)
 		}
-		if err := obs(&buf, d.m); err != nil {
+		if out, ok := d.obsCache[what]; ok {
+			buf.WriteString(out)
+			break
+		}
+		if err := obs(&buf, m); err != nil {
 			t.Fatalf("%s: observing %q: %v", d.pos, what, err)
 		}
+		d.checkViewBudget(t)
+		if d.cachedObservers[obsName] {
+			if d.obsCache == nil {
+				d.obsCache = make(map[string]string)
+			}
+			d.obsCache[what] = buf.String()
+		}
 	}
-	return buf.String()
+	res := buf.String()
+	obsName, _, _ := splitSubModel(what)
+	for _, f := range d.observerFilters[obsName] {
+		res = f(res)
+	}
+	res = d.normalizeOutput(res)
+	out.WriteString(d.truncateLines(res))
 }
 
-func observeView(buf io.Writer, m tea.Model) error {
+func (d *driver) observeView(buf io.Writer, m tea.Model) error {
+	d.viewCalls++
+	start := time.Now()
 	o := m.View()
+	d.recordViewTime(time.Since(start))
 	// Make newlines visible.
-	o = strings.ReplaceAll(o, "\n", "␤\n")
+	o = strings.ReplaceAll(o, "\n", d.newlineMarker+"\n")
 	// Add a "no newline at end" marker if there was no newline at the end.
 	if len(o) == 0 || o[len(o)-1] != '\n' {
-		o += "🛇"
+		o += d.eofMarker
 	}
 	_, err := io.WriteString(buf, o)
 	return err
@@ -434,25 +1635,24 @@ func (d *driver) ApplyTextCommand(t TB, cmd string, args ...string) tea.Cmd {
 
 	case "key":
 		d.assertArgc(t, args, 1)
-		keyName := args[0]
-		alt := false
-		if strings.HasPrefix(keyName, "alt+") {
-			alt = true
-			keyName = strings.TrimPrefix(keyName, "alt+")
-		}
-		k, ok := allKeys[keyName]
-		if !ok && len(keyName) != 1 {
-			t.Fatalf("%s: unknown key: %s", d.pos, keyName)
-		}
-		if ok {
-			k.Alt = alt
-			msg := tea.KeyMsg(k)
+		keys := args[:1]
+		if strings.HasPrefix(args[0], "@") {
+			var err error
+			keys, err = d.layoutKeys(args[0][1:])
+			if err != nil {
+				t.Fatalf("%s: %v", d.pos, err)
+			}
+		}
+		for _, k := range keys {
+			msg, err := parseKey(k, d.lookupKey, d.keyNames)
+			if err != nil {
+				t.Fatalf("%s: %v", d.pos, err)
+			}
 			d.addMsg(msg)
-			break
 		}
-		// Not a special key: it's runes.
-		args[0] = keyName
-		d.typeIn(args, alt)
+
+	case "mouse":
+		d.mouseInput(t, args...)
 
 	case "type":
 		d.typeIn(args, false)
@@ -461,6 +1661,37 @@ func (d *driver) ApplyTextCommand(t TB, cmd string, args ...string) tea.Cmd {
 		d.typeIn(args, false)
 		d.addMsg(tea.KeyMsg(tea.Key{Type: tea.KeyEnter}))
 
+	case "dumpstate":
+		d.assertArgc(t, args, 1)
+		if err := d.dumpState(args[0]); err != nil {
+			t.Fatalf("%s: dumpstate: %v", d.pos, err)
+		}
+
+	case "loadstate":
+		d.assertArgc(t, args, 1)
+		if err := d.loadState(args[0]); err != nil {
+			t.Fatalf("%s: loadstate: %v", d.pos, err)
+		}
+
+	case "typefile":
+		d.assertArgc(t, args, 1)
+		data, err := ioutil.ReadFile(args[0])
+		if err != nil {
+			t.Fatalf("%s: typefile: %v", d.pos, err)
+		}
+		for _, r := range string(data) {
+			switch r {
+			case '\r':
+				// Windows line endings: the newline follows.
+			case '\n':
+				d.addMsg(tea.KeyMsg(tea.Key{Type: tea.KeyEnter}))
+			case '\t':
+				d.addMsg(tea.KeyMsg(tea.Key{Type: tea.KeyTab}))
+			default:
+				d.addMsg(tea.KeyMsg(tea.Key{Type: tea.KeyRunes, Runes: []rune{r}}))
+			}
+		}
+
 	case "paste":
 		arg := strings.Join(args, " ")
 		s, err := strconv.Unquote(arg)
@@ -469,19 +1700,32 @@ func (d *driver) ApplyTextCommand(t TB, cmd string, args ...string) tea.Cmd {
 		}
 		d.addMsg(tea.KeyMsg(tea.Key{Type: tea.KeyRunes, Runes: []rune(s)}))
 
+	case "senderr":
+		arg := strings.Join(args, " ")
+		s, err := strconv.Unquote(arg)
+		if err != nil {
+			t.Fatalf("%s: senderr argument error: %v", d.pos, err)
+		}
+		d.addMsg(d.errMsg(errors.New(s)))
+
+	case "msg":
+		d.buildMsg(t, args...)
+
 	default:
-		if d.upd != nil {
+		if len(d.updaters) > 0 {
 			t.Logf("%s: applying command %q via model updater", d.pos, cmd)
-			supported, newModel, teaCmd, err := d.upd(d.m, cmd, args...)
-			if err != nil {
-				t.Fatalf("%s: updater error: %v", d.pos, err)
-			}
+			d.emit(Event{Kind: EventUpdaterDispatch, Cmd: cmd})
+			supported, newModel, teaCmd := d.dispatchUpdaters(t, cmd, args...)
 			if !supported {
-				t.Fatalf("%s: unknown command %q", d.pos, cmd)
+				t.Fatalf("%s: unknown command %q%s", d.pos, cmd, didYouMean(cmd, d.commandNames()))
 			}
 			d.m = newModel
+			d.modelUpdated()
 			return teaCmd
 		} else {
+			if sugg := didYouMean(cmd, d.commandNames()); sugg != "" {
+				t.Fatalf("%s: unknown command %q%s", d.pos, cmd, sugg)
+			}
 			t.Fatalf("%s: unknown command %q, and no Updater defined", d.pos, cmd)
 		}
 	}
@@ -497,6 +1741,12 @@ func (d *driver) typeIn(args []string, alt bool) {
 		}
 		buf.WriteString(arg)
 	}
+	if d.coalesceKeys {
+		if buf.Len() > 0 {
+			d.addMsg(tea.KeyMsg(tea.Key{Type: tea.KeyRunes, Runes: []rune(buf.String()), Alt: alt}))
+		}
+		return
+	}
 	for _, r := range buf.String() {
 		d.addMsg(tea.KeyMsg(tea.Key{Type: tea.KeyRunes, Runes: []rune{r}, Alt: alt}))
 	}
@@ -524,5 +1774,6 @@ var allKeys = func() map[string]tea.Key {
 	}
 	result["space"] = tea.Key{Type: tea.KeySpace, Runes: []rune(" ")}
 	result["backspace"] = tea.Key{Type: tea.KeyBackspace}
+	addKeyAliasGroups(result)
 	return result
 }()
@@ -0,0 +1,46 @@
+package catwalk
+
+import (
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// WithEnvIsolation tells the test driver to snapshot the process
+// environment and the global color settings of lipgloss (color
+// profile and background color) when it is created, and to restore
+// them when it is closed, e.g. at the end of RunModel. This way, a
+// test which sets NO_COLOR or changes the color profile cannot
+// contaminate the subsequent tests in the same package.
+//
+// After the restore, the color settings of lipgloss remain pinned to
+// the values they had when the driver was created, instead of being
+// detected from the environment.
+//
+// Since the environment is global to the process, the tests using
+// this option should not run in parallel with other tests.
+func WithEnvIsolation() Option {
+	return func(d *driver) {
+		d.envIsolation = true
+	}
+}
+
+// snapshotEnv records the environment and the color settings, for
+// restoreEnv.
+func (d *driver) snapshotEnv() {
+	env := os.Environ()
+	profile := lipgloss.ColorProfile()
+	dark := lipgloss.HasDarkBackground()
+	d.restoreEnv = func() {
+		os.Clearenv()
+		for _, kv := range env {
+			// On Windows, some special variables start with "=".
+			if i := strings.IndexByte(kv, '='); i > 0 {
+				_ = os.Setenv(kv[:i], kv[i+1:])
+			}
+		}
+		lipgloss.SetColorProfile(profile)
+		lipgloss.SetHasDarkBackground(dark)
+	}
+}
@@ -0,0 +1,42 @@
+package catwalk
+
+import (
+	"os"
+	"testing"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+)
+
+func TestEnvIsolation(t *testing.T) {
+	defer os.Unsetenv("CATWALK_TEST_KEEP")
+	if err := os.Setenv("CATWALK_TEST_KEEP", "a"); err != nil {
+		t.Fatal(err)
+	}
+	defer func(p termenv.Profile, dark bool) {
+		lipgloss.SetColorProfile(p)
+		lipgloss.SetHasDarkBackground(dark)
+	}(lipgloss.ColorProfile(), lipgloss.HasDarkBackground())
+	lipgloss.SetColorProfile(termenv.ANSI)
+	dark := lipgloss.HasDarkBackground()
+
+	d := NewDriver(intModel(0), WithEnvIsolation())
+	_ = os.Setenv("CATWALK_TEST_KEEP", "b")
+	_ = os.Setenv("CATWALK_TEST_NEW", "c")
+	lipgloss.SetColorProfile(termenv.Ascii)
+	lipgloss.SetHasDarkBackground(!dark)
+	d.Close(t)
+
+	if v := os.Getenv("CATWALK_TEST_KEEP"); v != "a" {
+		t.Errorf("expected the variable to be restored, got %q", v)
+	}
+	if v, ok := os.LookupEnv("CATWALK_TEST_NEW"); ok {
+		t.Errorf("expected the variable to be removed, got %q", v)
+	}
+	if p := lipgloss.ColorProfile(); p != termenv.ANSI {
+		t.Errorf("expected the color profile to be restored, got %v", p)
+	}
+	if lipgloss.HasDarkBackground() != dark {
+		t.Errorf("expected the background color to be restored")
+	}
+}
@@ -0,0 +1,104 @@
+package catwalk
+
+import (
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// EventKind is the kind of an Event.
+type EventKind int
+
+const (
+	// EventDirectiveStart is emitted when the driver starts
+	// processing a test directive.
+	EventDirectiveStart EventKind = iota
+	// EventDirectiveEnd is emitted when the driver has finished
+	// processing a test directive. Output contains the result.
+	EventDirectiveEnd
+	// EventInit is emitted when the driver calls the model's Init
+	// method.
+	EventInit
+	// EventMsgDelivered is emitted when a message is delivered.
+	EventMsgDelivered
+	// EventCmdStarted is emitted when the driver starts a command.
+	EventCmdStarted
+	// EventCmdFinished is emitted when a command has completed, or
+	// has timed out.
+	EventCmdFinished
+	// EventObservation is emitted after an observer has run.
+	EventObservation
+	// EventUpdaterDispatch is emitted when an input command is passed
+	// to the model updater(s). Cmd contains the input command.
+	EventUpdaterDispatch
+	// EventUnknownMsg is emitted when a message internal to bubbletea
+	// that the driver does not know how to handle, for example that
+	// of tea.ClearScrollArea(), is passed to the model as-is. Msg
+	// contains the message.
+	EventUnknownMsg
+)
+
+var eventKindNames = [...]string{
+	EventDirectiveStart:  "directive-start",
+	EventDirectiveEnd:    "directive-end",
+	EventInit:            "init",
+	EventMsgDelivered:    "msg-delivered",
+	EventCmdStarted:      "cmd-started",
+	EventCmdFinished:     "cmd-finished",
+	EventObservation:     "observation",
+	EventUpdaterDispatch: "updater-dispatch",
+	EventUnknownMsg:      "unknown-msg",
+}
+
+func (k EventKind) String() string {
+	if k < 0 || int(k) >= len(eventKindNames) {
+		return "unknown"
+	}
+	return eventKindNames[k]
+}
+
+// Event describes a step in the lifecycle of a test driver.
+// See WithListener().
+type Event struct {
+	// Kind is the kind of event.
+	Kind EventKind
+	// Pos is the position in the test input.
+	Pos string
+	// Directive is the name of the test directive, for
+	// EventDirectiveStart and EventDirectiveEnd.
+	Directive string
+	// Msg is the message delivered, for EventMsgDelivered and
+	// EventUnknownMsg; or the message returned by the command, for
+	// EventCmdFinished.
+	Msg tea.Msg
+	// Cmd is the name of the function implementing the command,
+	// for EventCmdStarted and EventCmdFinished; or the input
+	// command, for EventUpdaterDispatch.
+	Cmd string
+	// Latency is how long the command took, for EventCmdFinished.
+	Latency time.Duration
+	// TimedOut is set for EventCmdFinished if the command
+	// did not complete within the command timeout.
+	TimedOut bool
+	// Observer is the name of the observer, for EventObservation.
+	Observer string
+	// Output is the result of the observation, for
+	// EventObservation; or the output of the directive, for
+	// EventDirectiveEnd.
+	Output string
+}
+
+// Listener is a function which is notified of the events in
+// the lifecycle of a test driver.
+type Listener func(Event)
+
+// emit notifies the listeners of an event.
+func (d *driver) emit(ev Event) {
+	if len(d.listeners) == 0 {
+		return
+	}
+	ev.Pos = d.pos
+	for _, l := range d.listeners {
+		l(ev)
+	}
+}
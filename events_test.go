@@ -0,0 +1,52 @@
+package catwalk
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// TestListener checks that listeners are notified of the driver's
+// lifecycle events.
+func TestListener(t *testing.T) {
+	var buf strings.Builder
+	l := func(ev Event) {
+		fmt.Fprintf(&buf, "%s %s", ev.Pos, ev.Kind)
+		switch ev.Kind {
+		case EventDirectiveStart, EventDirectiveEnd:
+			fmt.Fprintf(&buf, " %s", ev.Directive)
+		case EventMsgDelivered:
+			fmt.Fprintf(&buf, " %T", ev.Msg)
+		case EventCmdStarted, EventCmdFinished:
+			fmt.Fprintf(&buf, " %s", ev.Cmd)
+		case EventObservation:
+			fmt.Fprintf(&buf, " %s %q", ev.Observer, ev.Output)
+		}
+		buf.WriteByte('\n')
+	}
+	RunModelFromString(t, `
+run
+type q
+----
+TEA PRINT: {MODEL INIT}
+TEA QUIT
+-- view:
+MODEL VIEW🛇
+`, emptyModel{}, WithListener(l))
+
+	const expected = `<string>:2 directive-start run
+<string>:2 init
+<string>:2 cmd-started github.com/charmbracelet/bubbletea.Println.func1
+<string>:2 cmd-finished github.com/charmbracelet/bubbletea.Println.func1
+<string>:2 msg-delivered tea.printLineMessage
+<string>:2 msg-delivered tea.KeyMsg
+<string>:2 cmd-started github.com/charmbracelet/bubbletea.Quit
+<string>:2 cmd-finished github.com/charmbracelet/bubbletea.Quit
+<string>:2 msg-delivered tea.quitMsg
+<string>:2 observation view "-- view:\nMODEL VIEW🛇"
+<string>:2 directive-end run
+`
+	if actual := buf.String(); actual != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, actual)
+	}
+}
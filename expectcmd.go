@@ -0,0 +1,99 @@
+package catwalk
+
+import (
+	"reflect"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// expectCmd implements the expect_cmd and expect_no_cmd input
+// commands, which check the command returned by the last call to
+// Update(), before it is executed.
+func (d *driver) expectCmd(t TB, trace bool, cmd string, args ...string) {
+	if cmd == "expect_no_cmd" {
+		d.assertArgc(t, args, 0)
+	} else {
+		d.assertArgc(t, args, 1)
+	}
+	// Deliver the pending messages, so that the last Update() call
+	// reflects the previous input commands. The resulting commands
+	// remain queued.
+	if !d.deferProcessing {
+		d.processTeaMsgs(trace)
+	}
+	names := d.lastCmdNames()
+	d.trace(trace, "last Update() returned: %s", describeCmdNames(names))
+	if cmd == "expect_no_cmd" {
+		if len(names) > 0 {
+			t.Fatalf("%s: expected no command, but the last Update() returned: %s",
+				d.pos, describeCmdNames(names))
+		}
+		return
+	}
+	for _, name := range names {
+		if cmdNameMatches(name, args[0]) {
+			return
+		}
+	}
+	t.Fatalf("%s: expected command %s, but the last Update() returned: %s",
+		d.pos, args[0], describeCmdNames(names))
+}
+
+// lastCmdNames returns the names of the functions implementing the
+// command returned by the last call to Update(). Batches and
+// sequences are expanded.
+func (d *driver) lastCmdNames() []string {
+	return expandCmdNames(d.lastCmd)
+}
+
+// expandCmdNames returns the names of the functions implementing the
+// given command, without executing it. Batches and sequences are
+// expanded.
+func expandCmdNames(cmd tea.Cmd) []string {
+	var names []string
+	var expand func(cmd tea.Cmd)
+	expand = func(cmd tea.Cmd) {
+		if cmd == nil {
+			return
+		}
+		name := cmdName(cmd)
+		if strings.HasPrefix(name, teaPkgPath+".Batch.") || strings.HasPrefix(name, teaPkgPath+".Sequence.") {
+			// These commands have no side effect and can be
+			// expanded safely.
+			if rmsg := reflect.ValueOf(cmd()); rmsg.IsValid() && rmsg.Type().ConvertibleTo(cmdsType) {
+				for _, c := range rmsg.Convert(cmdsType).Interface().([]tea.Cmd) {
+					expand(c)
+				}
+				return
+			}
+		}
+		names = append(names, name)
+	}
+	expand(cmd)
+	return names
+}
+
+// teaPkgPath is the import path of bubbletea.
+var teaPkgPath = reflect.TypeOf(tea.KeyMsg{}).PkgPath()
+
+// cmdNameMatches returns true if the command name matches the given
+// pattern: either the fully qualified name of the function, or any
+// suffix of it starting after a period or slash. The prefix "tea."
+// designates the bubbletea package, e.g. "tea.Quit".
+func cmdNameMatches(name, pattern string) bool {
+	if strings.HasPrefix(pattern, "tea.") {
+		pattern = teaPkgPath + strings.TrimPrefix(pattern, "tea")
+	}
+	return name == pattern ||
+		strings.HasSuffix(name, "."+pattern) ||
+		strings.HasSuffix(name, "/"+pattern)
+}
+
+// describeCmdNames formats command names for error messages.
+func describeCmdNames(names []string) string {
+	if len(names) == 0 {
+		return "no command"
+	}
+	return strings.Join(names, ", ")
+}
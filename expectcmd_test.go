@@ -0,0 +1,135 @@
+package catwalk
+
+import (
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/cockroachdb/datadriven"
+)
+
+// cmdBatchModel returns a batch of commands upon every update.
+type cmdBatchModel struct{}
+
+func (cmdBatchModel) Init() tea.Cmd { return nil }
+func (m cmdBatchModel) Update(tea.Msg) (tea.Model, tea.Cmd) {
+	return m, tea.Batch(tea.Println("hello"), tea.Sequence(tea.HideCursor, tea.Quit))
+}
+func (cmdBatchModel) View() string { return "BATCH" }
+
+func TestExpectCmd(t *testing.T) {
+	const test = `
+run
+type q
+expect_cmd tea.Quit
+----
+TEA PRINT: {MODEL INIT}
+TEA QUIT
+-- view:
+MODEL VIEW🛇
+
+run
+type bq
+expect_cmd bubbletea.Quit
+type b
+expect_cmd tea.Println.func1
+----
+TEA PRINT: {MODEL UPDATE}
+TEA QUIT
+TEA PRINT: {MODEL UPDATE}
+-- view:
+MODEL VIEW🛇
+`
+	RunModelFromString(t, test, emptyModel{})
+
+	const batch = `
+run trace=on
+type a
+expect_cmd tea.Quit
+----
+-- trace: calling Init
+-- trace: before "type a"
+-- trace: after "type"
+-- view:
+BATCH🛇
+-- trace: before "expect_cmd tea.Quit"
+-- trace: processing 1 messages
+-- trace: msg tea.KeyMsg: a
+-- trace: last Update() returned: github.com/charmbracelet/bubbletea.Println.func1, github.com/charmbracelet/bubbletea.HideCursor, github.com/charmbracelet/bubbletea.Quit
+-- trace: after "expect_cmd"
+-- view:
+BATCH🛇
+-- trace: before finish
+-- view:
+BATCH🛇
+-- trace: processing 1 cmds
+-- trace: expanded 2 commands
+-- trace: translated cmd: tea.printLineMessage
+-- trace: running sequence of 2 commands
+-- trace: translated cmd: tea.hideCursorMsg
+-- trace: processing 1 messages
+-- trace: msg tea.hideCursorMsg{}
+TEA HIDE CURSOR
+-- trace: translated cmd: tea.quitMsg
+-- trace: processing 1 messages
+-- trace: msg tea.quitMsg{}
+TEA QUIT
+-- trace: processing 1 messages
+-- trace: msg tea.printLineMessage{messageBody:"hello"}
+TEA PRINT: {hello}
+-- trace: at end
+-- view:
+BATCH🛇
+`
+	RunModelFromString(t, batch, cmdBatchModel{})
+}
+
+func TestExpectCmdFailure(t *testing.T) {
+	runTest := func(input string) string {
+		d := NewDriver(emptyModel{}, WithAutoInitDisabled())
+		defer d.Close(t)
+		return expectFatal(t, d, &datadriven.TestData{Pos: "test:1", Cmd: "run", Input: input})
+	}
+
+	testData := []struct {
+		input    string
+		expected string
+	}{
+		{"type a\nexpect_cmd tea.Quit",
+			`test:1: expected command tea.Quit, but the last Update() returned: github.com/charmbracelet/bubbletea.EnterAltScreen`},
+		{"type q\nexpect_no_cmd",
+			`test:1: expected no command, but the last Update() returned: github.com/charmbracelet/bubbletea.Quit`},
+		{"expect_cmd tea.Quit",
+			`test:1: expected command tea.Quit, but the last Update() returned: no command`},
+		{"expect_no_cmd", ``},
+	}
+	for _, tc := range testData {
+		if err := runTest(tc.input); err != tc.expected {
+			t.Errorf("%q: expected:\n%s\ngot:\n%s", tc.input, tc.expected, err)
+		}
+	}
+}
+
+func TestInitCmds(t *testing.T) {
+	const test = `
+run observe=initcmds
+----
+TEA PRINT: {init2}
+TEA PRINT: {init3}
+TEA PRINT: {init1}
+-- initcmds:
+init cmds: 4
+0:github.com/charmbracelet/bubbletea.Println.func1
+1:github.com/knz/catwalk.cmdModel.Init.func1
+2:github.com/charmbracelet/bubbletea.Println.func1
+3:github.com/charmbracelet/bubbletea.Println.func1
+`
+	RunModelFromString(t, test, cmdModel{})
+
+	const disabled = `
+run observe=initcmds
+----
+-- initcmds:
+Init() not called
+`
+	RunModelFromString(t, disabled, cmdModel{}, WithAutoInitDisabled())
+}
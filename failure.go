@@ -0,0 +1,121 @@
+package catwalk
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/cockroachdb/datadriven"
+)
+
+// Failure describes the failure of a test directive. It is passed
+// to the handler configured with WithFailureHandler, so that IDE
+// plugins and custom reporters can present failures without parsing
+// the test log.
+type Failure struct {
+	// File is the path to the test file.
+	File string
+	// Line is the line number of the directive in the test file.
+	Line int
+	// Directive is the directive line, for example
+	// "run observe=(view,msgs)".
+	Directive string
+	// Input contains the input commands of the directive.
+	Input string
+	// Message is the error reported by the test driver when the
+	// directive could not complete, for example upon an unknown
+	// input command. It is empty when the directive completed but
+	// its output did not match the expected output.
+	Message string
+	// Expected is the expected output of the directive.
+	Expected string
+	// Observed is the actual output of the directive. It is empty
+	// when Message is set.
+	Observed string
+	// Trace contains the trace lines emitted during the directive,
+	// without their "-- trace:" prefix, when tracing was enabled.
+	Trace []string
+}
+
+// Error implements the error interface.
+func (f *Failure) Error() string {
+	if f.Message != "" {
+		return f.Message
+	}
+	return fmt.Sprintf("%s:%d: output does not match the expected output", f.File, f.Line)
+}
+
+// WithFailureHandler tells the test driver to call the given function
+// for every directive that fails, either because the test driver
+// reported an error or because the output of the directive does not
+// match the expected output. The handler is called before the
+// failure is reported to the test. Mismatched output is not
+// reported when the tests run with -rewrite.
+func WithFailureHandler(fn func(*Failure)) Option {
+	return func(d *driver) {
+		d.failureHandler = fn
+	}
+}
+
+// newFailure creates a Failure for the given directive.
+func (d *driver) newFailure(td *datadriven.TestData) *Failure {
+	f := &Failure{
+		File:     td.Pos,
+		Input:    td.Input,
+		Expected: td.Expected,
+		Trace:    d.traceLines,
+	}
+	if idx := strings.LastIndexByte(td.Pos, ':'); idx >= 0 {
+		if line, err := strconv.Atoi(td.Pos[idx+1:]); err == nil {
+			f.File, f.Line = td.Pos[:idx], line
+		}
+	}
+	directive := []string{td.Cmd}
+	for _, arg := range td.CmdArgs {
+		directive = append(directive, arg.String())
+	}
+	f.Directive = strings.Join(directive, " ")
+	return f
+}
+
+// reportFailure calls the failure handler if the output of the
+// directive does not match the expected output, unless the failure
+// was already reported as a fatal error.
+func (d *driver) reportFailure(t TB, td *datadriven.TestData, output string) {
+	if d.failureHandler == nil || outputMatches(output, td.Expected) || rewriting() {
+		return
+	}
+	if ft, ok := t.(*failureTB); ok && ft.reported {
+		return
+	}
+	f := d.newFailure(td)
+	f.Observed = output
+	d.failureHandler(f)
+}
+
+// failureTB is a TB which calls the failure handler upon fatal
+// errors.
+type failureTB struct {
+	TB
+	d  *driver
+	td *datadriven.TestData
+	// reported is set once a fatal error has been reported.
+	reported bool
+}
+
+func (t *failureTB) Fatal(args ...interface{}) {
+	t.report(fmt.Sprint(args...))
+	t.TB.Fatal(args...)
+}
+
+func (t *failureTB) Fatalf(format string, args ...interface{}) {
+	t.report(fmt.Sprintf(format, args...))
+	t.TB.Fatalf(format, args...)
+}
+
+func (t *failureTB) report(msg string) {
+	t.reported = true
+	f := t.d.newFailure(t.td)
+	f.Message = msg
+	t.d.failureHandler(f)
+}
@@ -0,0 +1,76 @@
+package catwalk
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/cockroachdb/datadriven"
+)
+
+func TestFailureHandler(t *testing.T) {
+	var failures []*Failure
+	d := NewDriver(intModel(0), WithFailureHandler(func(f *Failure) {
+		failures = append(failures, f)
+	}))
+	defer d.Close(t)
+
+	// A directive with the expected output is not reported.
+	td := &datadriven.TestData{Pos: "testdata/foo:1", Cmd: "run", Input: "type a", Expected: "-- view:\nVALUE: 1🛇\n"}
+	if out := d.RunOneTest(t, td); out != td.Expected {
+		t.Fatalf("unexpected output: %q", out)
+	}
+	if len(failures) != 0 {
+		t.Fatalf("expected no failure, got %+v", failures)
+	}
+	// Likewise for a set directive, whose output does not end with
+	// a newline.
+	d.RunOneTest(t, &datadriven.TestData{Pos: "testdata/foo:3", Cmd: "set",
+		CmdArgs: []datadriven.CmdArg{{Key: "strict_updaters", Vals: []string{"on"}}}, Expected: "strict_updaters: on\n"})
+	if len(failures) != 0 {
+		t.Fatalf("expected no failure, got %+v", failures)
+	}
+
+	// A directive with a different output is reported, with its trace.
+	td = &datadriven.TestData{
+		Pos:      "testdata/foo:5",
+		Cmd:      "run",
+		CmdArgs:  []datadriven.CmdArg{{Key: "trace"}},
+		Input:    "type a",
+		Expected: "-- view:\nVALUE: 1🛇\n",
+	}
+	out := d.RunOneTest(t, td)
+	if len(failures) != 1 {
+		t.Fatalf("expected one failure, got %+v", failures)
+	}
+	f := failures[0]
+	if f.File != "testdata/foo" || f.Line != 5 || f.Directive != "run trace" || f.Input != "type a" {
+		t.Errorf("unexpected failure position: %+v", f)
+	}
+	if f.Expected != td.Expected || f.Observed != out || f.Message != "" {
+		t.Errorf("unexpected failure output: %+v", f)
+	}
+	expTrace := []string{
+		"before \"type a\"",
+		"after \"type\"",
+		"before finish",
+		"processing 1 messages",
+		"msg tea.KeyMsg: a",
+		"at end",
+	}
+	if !reflect.DeepEqual(f.Trace, expTrace) {
+		t.Errorf("expected trace %q, got %q", expTrace, f.Trace)
+	}
+	if exp := "testdata/foo:5: output does not match the expected output"; f.Error() != exp {
+		t.Errorf("expected %q, got %q", exp, f.Error())
+	}
+
+	// Fatal errors are reported once, with their message.
+	failures = nil
+	fatal := expectFatal(t, d, &datadriven.TestData{Pos: "testdata/foo:9", Cmd: "run", Input: "unknown"})
+	if len(failures) != 1 {
+		t.Fatalf("expected one failure, got %+v", failures)
+	}
+	if f := failures[0]; f.Line != 9 || f.Message != fatal || f.Error() != fatal || f.Observed != "" {
+		t.Errorf("unexpected failure: %+v (fatal: %q)", f, fatal)
+	}
+}
@@ -0,0 +1,161 @@
+package catwalk
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// FocusableAccessor extracts a focusable component from the model,
+// e.g. a textinput.Model. Unlike a SubModelAccessor, it can return
+// a component which does not implement tea.Model. See
+// WithFocusable().
+type FocusableAccessor func(m tea.Model) interface{}
+
+// FocusableSetter stores a focusable component back into the model
+// after its Focus() or Blur() method was called. It returns the new
+// model. See WithFocusable().
+type FocusableSetter func(m tea.Model, comp interface{}) tea.Model
+
+// WithFocusable registers a component of the model which has Focus()
+// and Blur() methods, e.g. a textinput.Model, under the given name.
+// This makes it possible to use the focus and blurcomponent input
+// commands and the focus observer on components which do not
+// implement tea.Model, and thus cannot be registered with
+// WithSubModel().
+//
+// The setter is used to store the component back into the model, in
+// case the accessor returns the component by value. It can be nil if
+// the accessor returns a pointer to the component inside the model.
+func WithFocusable(name string, get FocusableAccessor, set FocusableSetter) Option {
+	return func(d *driver) {
+		if d.focusables == nil {
+			d.focusables = make(map[string]focusable)
+		}
+		d.focusables[name] = focusable{name: name, get: get, set: set}
+	}
+}
+
+// focusable is a component targeted by the focus and blurcomponent
+// input commands: either registered with WithFocusable, or a
+// sub-model registered with WithSubModel.
+type focusable struct {
+	name string
+	get  FocusableAccessor
+	set  FocusableSetter
+	// noSetter is the error reported when the component is
+	// implemented by value and set is nil.
+	noSetter string
+}
+
+// getFocusable returns the focusable component with the given name.
+func (d *driver) getFocusable(t TB, name string) focusable {
+	if f, ok := d.focusables[name]; ok {
+		if f.set == nil {
+			f.noSetter = fmt.Sprintf("component %q is implemented by value, did you pass a setter to WithFocusable()?", name)
+		}
+		return f
+	}
+	sub := d.getSubModel(t, name)
+	f := focusable{
+		name:     name,
+		get:      func(m tea.Model) interface{} { return sub.get(m) },
+		noSetter: fmt.Sprintf("sub-model %q is implemented by value, did you call WithSubModelSetter()?", name),
+	}
+	if sub.set != nil {
+		f.set = func(m tea.Model, comp interface{}) tea.Model { return sub.set(m, comp.(tea.Model)) }
+	}
+	return f
+}
+
+// setFocus implements the focus and blurcomponent input commands:
+// the Focus() or Blur() method of the component registered with
+// WithFocusable() or WithSubModel() is called. The method can return
+// a tea.Cmd, as that of textinput.Model does; the command is then
+// run like the commands returned by Update.
+//
+// A component implemented by value needs a setter, so that the
+// change is visible in the model.
+func (d *driver) setFocus(t TB, trace bool, cmd string, args ...string) {
+	if len(args) != 1 {
+		t.Fatalf("%s: syntax: %s <submodel>", d.pos, cmd)
+	}
+	method := "Focus"
+	if cmd == "blurcomponent" {
+		method = "Blur"
+	}
+	f := d.getFocusable(t, args[0])
+	comp := f.get(d.m)
+	v := reflect.ValueOf(comp)
+	byRef := v.Kind() == reflect.Ptr
+	if !byRef {
+		if f.set == nil {
+			t.Fatalf("%s: %s", d.pos, f.noSetter)
+		}
+		// Use an addressable copy, so that the methods with a
+		// pointer receiver can be called.
+		c := reflect.New(v.Type())
+		c.Elem().Set(v)
+		v = c
+	}
+	fn := v.MethodByName(method)
+	if !fn.IsValid() || fn.Type().NumIn() != 0 {
+		t.Fatalf("%s: component %q (%T) does not have a %s() method", d.pos, f.name, comp, method)
+	}
+	d.trace(trace, "calling %s() on component %q", method, f.name)
+	res := fn.Call(nil)
+	if !byRef {
+		d.m = f.set(d.m, v.Elem().Interface())
+	}
+	d.modelUpdated()
+	if len(res) == 1 {
+		if cmd, ok := res[0].Interface().(tea.Cmd); ok {
+			d.setOrigin("%s(%s)", method, f.name)
+			d.addCmds(cmd)
+			d.processTeaCmds(trace)
+		}
+	}
+}
+
+// observeFocus implements the focus observer: it reports whether
+// each component registered with WithFocusable() or WithSubModel()
+// which has a Focused() bool method claims the focus.
+func (d *driver) observeFocus(buf io.Writer) error {
+	comps := make(map[string]interface{})
+	for name, sub := range d.subModels {
+		if sub.get != nil {
+			comps[name] = sub.get(d.m)
+		}
+	}
+	for name, f := range d.focusables {
+		comps[name] = f.get(d.m)
+	}
+	names := make([]string, 0, len(comps))
+	for name := range comps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	found := false
+	for _, name := range names {
+		f, ok := comps[name].(interface{ Focused() bool })
+		if !ok {
+			continue
+		}
+		found = true
+		state := "blurred"
+		if f.Focused() {
+			state = "focused"
+		}
+		if _, err := fmt.Fprintf(buf, "%s: %s\n", name, state); err != nil {
+			return err
+		}
+	}
+	if !found {
+		_, err := io.WriteString(buf, "no focusable components\n")
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package catwalk
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/cockroachdb/datadriven"
+)
+
+// loginModel contains a textinput by reference and one by value.
+type loginModel struct {
+	user    *textinput.Model
+	pass    textinput.Model
+	lastMsg string
+}
+
+func (loginModel) Init() tea.Cmd { return nil }
+func (m loginModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	m.lastMsg = fmt.Sprintf("%T", msg)
+	return m, nil
+}
+func (m loginModel) View() string { return "last msg: " + m.lastMsg }
+
+func newLoginModel() loginModel {
+	user, pass := textinput.New(), textinput.New()
+	// Make the blink command returned by Focus complete quickly.
+	user.BlinkSpeed, pass.BlinkSpeed = time.Millisecond, time.Millisecond
+	return loginModel{user: &user, pass: pass}
+}
+
+func TestFocus(t *testing.T) {
+	const test = `
+run observe=focus
+----
+-- focus:
+pass: blurred
+user: blurred
+
+# The command returned by Focus is run, and its message
+# delivered to the model.
+run observe=(focus,view)
+focus user
+----
+-- focus:
+pass: blurred
+user: focused
+-- view:
+last msg: textinput.blinkMsg🛇
+
+run observe=focus
+blurcomponent user
+focus pass
+----
+-- focus:
+pass: focused
+user: blurred
+`
+	RunModelFromString(t, test, newLoginModel(),
+		WithSyncCmds(),
+		WithFocusable("user", func(m tea.Model) interface{} { return m.(loginModel).user }, nil),
+		WithFocusable("pass", func(m tea.Model) interface{} { return m.(loginModel).pass },
+			func(m tea.Model, comp interface{}) tea.Model {
+				lm := m.(loginModel)
+				lm.pass = comp.(textinput.Model)
+				return lm
+			}))
+
+	for _, tc := range []struct {
+		input    string
+		expected string
+	}{
+		{"focus", "test:1: syntax: focus <submodel>"},
+		{"focus pass", `test:1: component "pass" is implemented by value, did you pass a setter to WithFocusable()?`},
+		{"focus sub", `test:1: sub-model "sub" is implemented by value, did you call WithSubModelSetter()?`},
+		{"blurcomponent other", `test:1: component "other" (catwalk.intModel) does not have a Blur() method`},
+	} {
+		t.Run(tc.input, func(t *testing.T) {
+			d := NewDriver(newLoginModel(),
+				WithFocusable("pass", func(m tea.Model) interface{} { return m.(loginModel).pass }, nil),
+				WithSubModel("sub", func(m tea.Model) tea.Model { return intModel(0) }),
+				WithSubModel("other", func(m tea.Model) tea.Model { return intModel(0) }),
+				WithSubModelSetter("other", func(m, child tea.Model) tea.Model { return m }))
+			defer d.Close(t)
+			fatal := expectFatal(t, d, &datadriven.TestData{Pos: "test:1", Cmd: "run", Input: tc.input})
+			if fatal != tc.expected {
+				t.Errorf("expected:\n%s\ngot:\n%s", tc.expected, fatal)
+			}
+		})
+	}
+}
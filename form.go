@@ -0,0 +1,122 @@
+package catwalk
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// FieldLocator returns the name of the form field which has focus
+// in the model, or an empty string if no field has focus. It is used
+// by the fill input command. See WithFieldLocator().
+type FieldLocator func(m tea.Model) string
+
+// WithFieldLocator configures how the fill input command determines
+// which form field has focus. By default, the model must implement
+// a FocusedField() string method.
+func WithFieldLocator(loc FieldLocator) Option {
+	return func(d *driver) {
+		d.fieldLocator = loc
+	}
+}
+
+// maxFieldHops is the maximum number of focus changes performed to
+// reach one field with the fill input command.
+const maxFieldHops = 100
+
+// fillForm implements the fill input command: for each field=value
+// argument, the focus is moved to the field with the next_field
+// action of the key layout, then the value is typed in.
+func (d *driver) fillForm(t TB, trace bool, args ...string) {
+	locate := d.fieldLocator
+	if locate == nil {
+		locate = func(m tea.Model) string {
+			if f, ok := m.(interface{ FocusedField() string }); ok {
+				return f.FocusedField()
+			}
+			t.Fatalf("%s: fill: model %T does not implement FocusedField(), use WithFieldLocator()", d.pos, m)
+			panic("unreachable")
+		}
+	}
+	fields, err := parseFields(strings.Join(args, " "))
+	if err != nil {
+		t.Fatalf("%s: fill: %v", d.pos, err)
+	}
+	if len(fields) == 0 {
+		t.Fatalf("%s: syntax: fill <field>=<value>...", d.pos)
+	}
+	for _, f := range fields {
+		start := locate(d.m)
+		for hops := 0; locate(d.m) != f.name; hops++ {
+			if hops >= maxFieldHops || (hops > 0 && locate(d.m) == start) {
+				t.Fatalf("%s: fill: field %q not found", d.pos, f.name)
+			}
+			d.applyInput(t, trace, "key", "@next_field")
+			d.processTeaMsgs(trace)
+			d.processTeaCmds(trace)
+			d.processTeaMsgs(trace)
+		}
+		d.trace(trace, "filling field %q", f.name)
+		d.typeIn([]string{f.value}, false)
+		d.processTeaMsgs(trace)
+		d.processTeaCmds(trace)
+		d.processTeaMsgs(trace)
+	}
+}
+
+// formField is one argument of the fill input command.
+type formField struct {
+	name, value string
+}
+
+// parseFields parses a list of field=value pairs. Values containing
+// spaces can be quoted with double quotes, using Go syntax.
+func parseFields(s string) ([]formField, error) {
+	var res []formField
+	for {
+		s = strings.TrimLeft(s, " ")
+		if s == "" {
+			return res, nil
+		}
+		eq := strings.IndexByte(s, '=')
+		if eq <= 0 || strings.Contains(s[:eq], " ") {
+			return nil, fmt.Errorf("expected <field>=<value>, got %q", s)
+		}
+		f := formField{name: s[:eq]}
+		s = s[eq+1:]
+		if strings.HasPrefix(s, `"`) {
+			end := closingQuote(s)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated quoted value for field %q", f.name)
+			}
+			v, err := strconv.Unquote(s[:end+1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid value for field %q: %v", f.name, err)
+			}
+			f.value, s = v, s[end+1:]
+		} else {
+			end := strings.IndexByte(s, ' ')
+			if end < 0 {
+				end = len(s)
+			}
+			f.value, s = s[:end], s[end:]
+		}
+		res = append(res, f)
+	}
+}
+
+// closingQuote returns the index of the double quote which
+// terminates the quoted string at the start of s, or -1.
+func closingQuote(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
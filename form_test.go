@@ -0,0 +1,93 @@
+package catwalk
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/cockroachdb/datadriven"
+)
+
+// formModel is a form with three fields; tab moves the
+// focus to the next field.
+type formModel struct {
+	focus  int
+	values [3]string
+}
+
+var formFields = [3]string{"name", "email", "age"}
+
+func (m formModel) Init() tea.Cmd { return nil }
+
+func (m formModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if k, ok := msg.(tea.KeyMsg); ok {
+		switch k.Type {
+		case tea.KeyTab:
+			m.focus = (m.focus + 1) % len(formFields)
+		case tea.KeyRunes:
+			m.values[m.focus] += string(k.Runes)
+		}
+	}
+	return m, nil
+}
+
+func (m formModel) View() string {
+	var buf strings.Builder
+	for i, f := range formFields {
+		marker := " "
+		if i == m.focus {
+			marker = ">"
+		}
+		fmt.Fprintf(&buf, "%s %s: %s\n", marker, f, m.values[i])
+	}
+	return buf.String()
+}
+
+func (m formModel) FocusedField() string { return formFields[m.focus] }
+
+func TestFill(t *testing.T) {
+	RunModel(t, "testdata/fill", formModel{})
+}
+
+func TestFillUnknownField(t *testing.T) {
+	d := NewDriver(formModel{})
+	defer d.Close(t)
+	fatal := expectFatal(t, d, &datadriven.TestData{Pos: "test:1", Cmd: "run", Input: "fill phone=123"})
+	if exp := `test:1: fill: field "phone" not found`; fatal != exp {
+		t.Errorf("expected %q, got %q", exp, fatal)
+	}
+}
+
+func TestParseFields(t *testing.T) {
+	testData := []struct {
+		input    string
+		expected []formField
+		err      string
+	}{
+		{"", nil, ""},
+		{"a=b", []formField{{"a", "b"}}, ""},
+		{"a=b  c=", []formField{{"a", "b"}, {"c", ""}}, ""},
+		{`a="hello world" b="x\"y"`, []formField{{"a", "hello world"}, {"b", `x"y`}}, ""},
+		{"a", nil, `expected <field>=<value>, got "a"`},
+		{"a b=c", nil, `expected <field>=<value>, got "a b=c"`},
+		{`a="b`, nil, `unterminated quoted value for field "a"`},
+	}
+	for _, tc := range testData {
+		res, err := parseFields(tc.input)
+		if tc.err != "" {
+			if err == nil || err.Error() != tc.err {
+				t.Errorf("%q: expected error %q, got %v", tc.input, tc.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", tc.input, err)
+			continue
+		}
+		if !reflect.DeepEqual(res, tc.expected) {
+			t.Errorf("%q: expected %+v, got %+v", tc.input, tc.expected, res)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package catwalk
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// lineRange is a range of lines of the view, written <start>:<end>
+// in input commands.
+type lineRange struct {
+	// start and end delimit the lines, as in a Go slice
+	// expression.
+	start, end int
+}
+
+func (r lineRange) String() string {
+	return fmt.Sprintf("%d:%d", r.start, r.end)
+}
+
+// frozenRegion is a range of lines of the view declared with the
+// freeze input command.
+type frozenRegion struct {
+	lineRange
+	// content is the content of the region when it was frozen.
+	content string
+}
+
+// viewRegion extracts the lines from start to end of the view.
+// The lines beyond the end of the view are empty.
+func viewRegion(view string, start, end int) string {
+	lines := strings.Split(view, "\n")
+	res := make([]string, end-start)
+	for i := range res {
+		if start+i < len(lines) {
+			res[i] = lines[start+i]
+		}
+	}
+	return strings.Join(res, "\n")
+}
+
+// freezeRegion implements the freeze input command: the given range
+// of lines of the view must not change in the rest of the test, until
+// the unfreeze command.
+func (d *driver) freezeRegion(t TB, trace bool, args ...string) {
+	if len(args) != 1 {
+		t.Fatalf("%s: syntax: freeze <start>:<end>", d.pos)
+	}
+	lr, err := parseRegion(args[0])
+	if err != nil {
+		t.Fatalf("%s: freeze: %v", d.pos, err)
+	}
+	r := frozenRegion{lineRange: lr}
+	r.content = viewRegion(d.m.View(), r.start, r.end)
+	d.trace(trace, "freezing lines %s", r)
+	d.frozen = append(d.frozen, r)
+}
+
+// unfreezeRegions implements the unfreeze input command.
+func (d *driver) unfreezeRegions(t TB, trace bool, args ...string) {
+	d.assertArgc(t, args, 0)
+	d.trace(trace, "unfreezing %d regions", len(d.frozen))
+	d.frozen = nil
+}
+
+// parseRegion parses a range of lines of the form <start>:<end>.
+func parseRegion(s string) (lineRange, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 2 {
+		return lineRange{}, fmt.Errorf("invalid region %q, expected <start>:<end>", s)
+	}
+	start, err1 := strconv.Atoi(parts[0])
+	end, err2 := strconv.Atoi(parts[1])
+	if err1 != nil || err2 != nil || start < 0 || end <= start {
+		return lineRange{}, fmt.Errorf("invalid region %q, expected <start>:<end>", s)
+	}
+	return lineRange{start: start, end: end}, nil
+}
+
+// checkFrozenRegions compares the frozen regions of the view to their
+// content when they were frozen, after the model processed the given
+// message. The first difference found is reported by
+// checkFrozenViolation.
+func (d *driver) checkFrozenRegions(msg tea.Msg) {
+	if len(d.frozen) == 0 || d.frozenViolation != "" {
+		return
+	}
+	view := d.m.View()
+	for _, r := range d.frozen {
+		content := viewRegion(view, r.start, r.end)
+		if content == r.content {
+			continue
+		}
+		var buf strings.Builder
+		fmt.Fprintf(&buf, "Update(%T) changed the frozen region %s of the view:", msg, r)
+		for _, l := range lineDiff(r.content, content) {
+			buf.WriteString("\n" + l.Op + " " + l.Text)
+		}
+		d.frozenViolation = buf.String()
+		return
+	}
+}
+
+func (d *driver) checkFrozenViolation(t TB) {
+	if d.frozenViolation != "" {
+		t.Fatalf("%s: %s", d.pos, d.frozenViolation)
+	}
+}
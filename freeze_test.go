@@ -0,0 +1,50 @@
+package catwalk
+
+import (
+	"fmt"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/cockroachdb/datadriven"
+)
+
+// headerModel displays a header above a counter. The "h" key changes
+// the header.
+type headerModel struct {
+	title string
+	count int
+}
+
+func (m headerModel) Init() tea.Cmd { return nil }
+
+func (m headerModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if k, ok := msg.(tea.KeyMsg); ok {
+		if k.String() == "h" {
+			m.title += "!"
+		} else {
+			m.count++
+		}
+	}
+	return m, nil
+}
+
+func (m headerModel) View() string {
+	return fmt.Sprintf("%s\n-----\ncount: %d", m.title, m.count)
+}
+
+func TestFreeze(t *testing.T) {
+	RunModel(t, "testdata/freeze", headerModel{title: "TITLE"})
+}
+
+func TestFreezeViolation(t *testing.T) {
+	d := NewDriver(headerModel{title: "TITLE"})
+	defer d.Close(t)
+	fatal := expectFatal(t, d, &datadriven.TestData{Pos: "test:1", Cmd: "run", Input: "freeze 0:2\ntype ahb"})
+	exp := `test:1: Update(tea.KeyMsg) changed the frozen region 0:2 of the view:
+- TITLE
++ TITLE!
+  -----`
+	if fatal != exp {
+		t.Errorf("expected:\n%s\ngot:\n%s", exp, fatal)
+	}
+}
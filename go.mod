@@ -9,4 +9,6 @@ require (
 	github.com/cockroachdb/datadriven v1.0.2
 	github.com/knz/lipgloss-convert v0.1.0
 	github.com/kr/pretty v0.3.0
+	github.com/muesli/reflow v0.3.0
+	github.com/muesli/termenv v0.11.1-0.20220212125758-44cd13922739
 )
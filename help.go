@@ -0,0 +1,76 @@
+package catwalk
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/help"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+var (
+	helpModelType = reflect.TypeOf(help.Model{})
+	keyMapType    = reflect.TypeOf((*help.KeyMap)(nil)).Elem()
+)
+
+// parseHelpObserver recognizes the help observer, of the form help
+// or help(width=<N>). The width is zero if not specified.
+func parseHelpObserver(name string) (width int, isHelp bool, err error) {
+	if name == "help" {
+		return 0, true, nil
+	}
+	if !strings.HasPrefix(name, "help(") || !strings.HasSuffix(name, ")") {
+		return 0, false, nil
+	}
+	params := strings.TrimSuffix(strings.TrimPrefix(name, "help("), ")")
+	for _, p := range strings.Split(params, ",") {
+		kv := strings.SplitN(strings.TrimSpace(p), "=", 2)
+		if len(kv) != 2 || kv[0] != "width" {
+			return 0, true, fmt.Errorf("unsupported parameter %q, expected width=<N>", p)
+		}
+		if width, err = strconv.Atoi(kv[1]); err != nil || width < 0 {
+			return 0, true, fmt.Errorf("invalid width: %q", kv[1])
+		}
+	}
+	return width, true, nil
+}
+
+// observeHelp implements the help observer: it renders the short
+// and full help of the first help.Model found inside the model, with
+// the key bindings of the first help.KeyMap found inside the model
+// (possibly the model itself). This isolates the layout of the help
+// from the rest of the view. When width is non-zero, the help is
+// rendered at that width instead of the width of the help.Model.
+func observeHelp(buf io.Writer, m tea.Model, width int) error {
+	helps := findComponents(m, func(typ reflect.Type) bool { return typ == helpModelType })
+	if len(helps) == 0 {
+		return errors.New("model does not contain a help.Model")
+	}
+	keyMaps := findComponents(m, func(typ reflect.Type) bool {
+		return typ != helpModelType && typ.Implements(keyMapType)
+	})
+	if len(keyMaps) == 0 {
+		return errors.New("model does not contain a help.KeyMap")
+	}
+	h := helps[0].val.(help.Model)
+	if width > 0 {
+		h.Width = width
+	}
+	km := keyMaps[0].val.(help.KeyMap)
+	var out strings.Builder
+	for _, section := range []struct{ name, view string }{
+		{"short", h.ShortHelpView(km.ShortHelp())},
+		{"full", h.FullHelpView(km.FullHelp())},
+	} {
+		out.WriteString(section.name + ":\n")
+		if section.view != "" {
+			out.WriteString(section.view + "\n")
+		}
+	}
+	_, err := io.WriteString(buf, out.String())
+	return err
+}
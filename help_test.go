@@ -0,0 +1,90 @@
+package catwalk
+
+import (
+	"testing"
+
+	"github.com/charmbracelet/bubbles/help"
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/cockroachdb/datadriven"
+)
+
+// menuKeys is a help.KeyMap.
+type menuKeys struct {
+	Up, Down, Open, Quit key.Binding
+}
+
+func (k menuKeys) ShortHelp() []key.Binding { return []key.Binding{k.Up, k.Down, k.Open, k.Quit} }
+func (k menuKeys) FullHelp() [][]key.Binding {
+	return [][]key.Binding{{k.Up, k.Down}, {k.Open, k.Quit}}
+}
+
+// menuModel embeds a help bubble.
+type menuModel struct {
+	help help.Model
+	keys menuKeys
+}
+
+func newMenuModel() menuModel {
+	return menuModel{
+		help: help.New(),
+		keys: menuKeys{
+			Up:   key.NewBinding(key.WithKeys("up"), key.WithHelp("↑", "move up")),
+			Down: key.NewBinding(key.WithKeys("down"), key.WithHelp("↓", "move down")),
+			Open: key.NewBinding(key.WithKeys("enter"), key.WithHelp("enter", "open")),
+			Quit: key.NewBinding(key.WithKeys("q"), key.WithHelp("q", "quit")),
+		},
+	}
+}
+
+func (m menuModel) Init() tea.Cmd                       { return nil }
+func (m menuModel) Update(tea.Msg) (tea.Model, tea.Cmd) { return m, nil }
+func (m menuModel) View() string                        { return "menu\n" + m.help.View(m.keys) }
+
+func TestHelpObserver(t *testing.T) {
+	const test = `
+# Without a width, the help is rendered at the width of the
+# help.Model, which is unlimited for the short help and empty for
+# the full help.
+run observe=help
+----
+-- help:
+short:
+↑ move up • ↓ move down • enter open • q quit
+full:
+
+# The help can be rendered at a different width than the view.
+run observe=(view,help(width=30))
+----
+-- view:
+menu␤
+↑ move up • ↓ move down • enter open • q quit🛇
+-- help(width=30):
+short:
+↑ move up • ↓ move down …
+full:
+↑ move up      enter open    
+↓ move down    q     quit    
+`
+	RunModelFromString(t, test, newMenuModel())
+
+	for _, tc := range []struct {
+		m        tea.Model
+		obs      string
+		expected string
+	}{
+		{newMenuModel(), "help(height=3)", `test:1: observing "help(height=3)": unsupported parameter "height=3", expected width=<N>`},
+		{newMenuModel(), "help(width=x)", `test:1: observing "help(width=x)": invalid width: "x"`},
+		{intModel(0), "help", `test:1: observing "help": model does not contain a help.Model`},
+	} {
+		t.Run(tc.obs, func(t *testing.T) {
+			d := NewDriver(tc.m)
+			defer d.Close(t)
+			fatal := expectFatal(t, d, &datadriven.TestData{Pos: "test:1", Cmd: "run",
+				CmdArgs: []datadriven.CmdArg{{Key: "observe", Vals: []string{tc.obs}}}})
+			if fatal != tc.expected {
+				t.Errorf("expected:\n%s\ngot:\n%s", tc.expected, fatal)
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package catwalk
+
+import "fmt"
+
+// IDConsumer is implemented by models which display generated
+// identifiers, e.g. UUIDs. The test driver calls SetIDGenerator on
+// the model when it is created, and again when the model is replaced
+// by the scenario directive or the loadstate input command, so that
+// the identifiers are deterministic and the model can be
+// golden-tested. Since the interface is structural, the model does
+// not need to import catwalk to implement it.
+//
+// The model should use the given function instead of its own ID
+// generator. The model is responsible for carrying the function over
+// to the new models returned by Update(), e.g. in a struct field.
+type IDConsumer interface {
+	SetIDGenerator(gen func() string)
+}
+
+// WithIDGenerator configures the function which generates the
+// identifiers provided to models which implement IDConsumer.
+//
+// By default, the test driver generates UUID-shaped identifiers from
+// a counter: 00000000-0000-0000-0000-000000000001, then ...002, etc.
+// The counter can be restarted with the reset_ids input command.
+func WithIDGenerator(gen func() string) Option {
+	return func(d *driver) {
+		d.idGen = gen
+	}
+}
+
+// setupIDGenerator provides the ID generator to the model, if it
+// implements IDConsumer. It must be called every time the driver
+// replaces the model.
+func (d *driver) setupIDGenerator() {
+	c, ok := d.m.(IDConsumer)
+	if !ok {
+		return
+	}
+	if d.idGen != nil {
+		c.SetIDGenerator(d.idGen)
+		return
+	}
+	c.SetIDGenerator(func() string {
+		d.idSeq++
+		return fmt.Sprintf("00000000-0000-0000-0000-%012d", d.idSeq)
+	})
+}
+
+// resetIDs implements the reset_ids input command.
+func (d *driver) resetIDs(t TB, trace bool, args ...string) {
+	d.assertArgc(t, args, 0)
+	if d.idGen != nil {
+		t.Fatalf("%s: reset_ids cannot reset the generator configured with WithIDGenerator()", d.pos)
+	}
+	d.trace(trace, "resetting the id sequence")
+	d.idSeq = 0
+}
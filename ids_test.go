@@ -0,0 +1,87 @@
+package catwalk
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// idModel creates a new item with a generated identifier
+// upon every key press.
+type idModel struct {
+	newID func() string
+	Items []string
+}
+
+func (m *idModel) SetIDGenerator(gen func() string) { m.newID = gen }
+
+func (m *idModel) Init() tea.Cmd { return nil }
+
+func (m *idModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if _, ok := msg.(tea.KeyMsg); ok {
+		m.Items = append(m.Items, m.newID())
+	}
+	return m, nil
+}
+
+func (m *idModel) View() string { return strings.Join(m.Items, "\n") }
+
+func TestIDGenerator(t *testing.T) {
+	RunModel(t, "testdata/ids", &idModel{})
+}
+
+func TestCustomIDGenerator(t *testing.T) {
+	n := 0
+	gen := func() string { n++; return "item" + strconv.Itoa(n) }
+	RunModelFromString(t, `
+run
+type ab
+----
+-- view:
+item1␤
+item2🛇
+`, &idModel{}, WithIDGenerator(gen))
+}
+
+// TestIDGeneratorReplacedModel checks that the generator is provided
+// again to the models restored by scenario or loaded by loadstate.
+func TestIDGeneratorReplacedModel(t *testing.T) {
+	dir, err := ioutil.TempDir("", "catwalk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "state.json")
+
+	RunModelFromString(t, fmt.Sprintf(`
+run
+type a
+dumpstate %s
+----
+-- view:
+00000000-0000-0000-0000-000000000001🛇
+
+scenario second
+----
+
+run
+type a
+----
+-- view:
+00000000-0000-0000-0000-000000000001🛇
+
+run
+loadstate %s
+type a
+----
+-- view:
+00000000-0000-0000-0000-000000000001␤
+00000000-0000-0000-0000-000000000002🛇
+`, path, path), &idModel{})
+}
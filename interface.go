@@ -2,6 +2,7 @@ package catwalk
 
 import (
 	"io"
+	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/cockroachdb/datadriven"
@@ -23,6 +24,45 @@ type Updater func(m tea.Model, testCmd string, args ...string) (supported bool,
 // tests.
 type Observer func(out io.Writer, m tea.Model) error
 
+// CmdStub describes how the test driver should simulate a tea.Cmd
+// instead of running it. See WithCmdStub().
+type CmdStub struct {
+	// Delay is the virtual time the command takes to complete. If it
+	// is larger than the command timeout, the command is handled as
+	// if it had timed out. No real time elapses in either case.
+	Delay time.Duration
+	// Msg is the message returned by the command.
+	Msg tea.Msg
+}
+
+// HistoryKind is the kind of a HistoryEntry.
+type HistoryKind int
+
+const (
+	// HistoryMsg is a message delivered to the model.
+	HistoryMsg HistoryKind = iota
+	// HistoryCmd is a command executed by the driver.
+	HistoryCmd
+)
+
+// HistoryEntry is one event in the history of a test driver.
+// See Driver.History().
+type HistoryEntry struct {
+	// Kind is the kind of event.
+	Kind HistoryKind
+	// Pos is the position in the test input where the event occurred.
+	Pos string
+	// Cmd is the name of the function implementing the command, for
+	// HistoryCmd entries.
+	Cmd string
+	// Msg is the message delivered, for HistoryMsg entries; or the
+	// message returned by the command, for HistoryCmd entries.
+	Msg tea.Msg
+	// TimedOut is set for HistoryCmd entries when the command did not
+	// complete within the command timeout.
+	TimedOut bool
+}
+
 // Option is the type of an option which can be specified
 // with RunModel or NewDriver.
 type Option func(*driver)
@@ -50,6 +90,34 @@ type Driver interface {
 	// - debug: call Debug()
 	Observe(t TB, what string) string
 
+	// History returns the ordered list of the messages delivered
+	// and the commands executed so far.
+	History() []HistoryEntry
+
+	// PostMsg queues a message for delivery to the model by the
+	// next call to Step, or by the next run directive.
+	PostMsg(msg tea.Msg)
+
+	// PostCmd queues a command to be run by the next call to Step,
+	// or by the next run directive.
+	PostCmd(cmd tea.Cmd)
+
+	// Step initializes the model if not done yet, then delivers the
+	// queued messages to the model and runs the queued commands.
+	// The messages produced by the commands are queued for the
+	// next step. It returns false if there was nothing to process.
+	// Each step has its own budget of messages and commands, as set
+	// with WithMaxIterations; errors are reported at position
+	// "Step() #N", for the N-th call to Step.
+	//
+	// Together with PostMsg, PostCmd and Model, this makes it
+	// possible to drive a model from Go code, e.g. to mix scripted
+	// runs with assertions on the model.
+	Step(t TB) bool
+
+	// Model returns the current model.
+	Model() tea.Model
+
 	// RunOneTest runs one step of a test file.
 	//
 	// The following directives are supported:
@@ -65,9 +133,29 @@ type Driver interface {
 	//     - gostruct: the result of printing the model with %#v.
 	//     - debug: the result of calling the Debug() method (it needs to be defined)
 	//     - msgs/cmds: print the residual tea.Cmd / tea.Msg input.
+	//     - screen: whether the view is rendered in the alt screen.
+	//     - counters: the number of calls to Update() and View().
+	//     - links: the OSC 8 hyperlinks in the view.
+	//     - memsize: the estimated size of the model.
+	//     - <observer>@<submodel>: observe a sub-model.
 	//
 	//   Supported input commands under "run":
 	//   - type: enter some runes as tea.Key
 	//   - key: enter a special key or combination as a tea.Key
+	//   - typefile: enter the contents of a file as tea.Keys
+	//   - senderr: deliver an error message to the model
+	//   - with_timeout: run another command with a custom cmd timeout
+	//   - wait_msgs: wait for messages sent by background goroutines
+	//   - pump: pull messages from subscriptions
+	//   - wait_for: process messages until the view matches a regexp
+	//   - after: schedule an input command in virtual time
+	//   - advance: advance the virtual time
+	//   - defer_processing, step, process: control the processing
+	//     of the queued messages and commands
+	//   - break_on: observe the model before messages of a given type
+	//   - to: deliver the messages of another command to a sub-model
+	//
+	// - scenario: restore the model to its initial state, to start
+	//   an independent case.
 	RunOneTest(t TB, d *datadriven.TestData) string
 }
@@ -0,0 +1,236 @@
+package catwalk
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// ParseKey returns the key message for the given key name, as
+// accepted by the key command in test scripts: either the name of a
+// special key, e.g. "enter" or "pgup", or a single character;
+// optionally prefixed by the modifiers "alt+", "ctrl+" and "shift+",
+// in any order, e.g. "alt+up" or "ctrl+alt+c". An error is returned
+// for combinations which bubbletea cannot represent, e.g.
+// "ctrl+shift+a".
+//
+// This makes it possible for programmatic tests and external tools
+// to construct the same messages as test scripts.
+func ParseKey(s string) (tea.KeyMsg, error) {
+	return parseKey(s, lookupKey, keyNames)
+}
+
+// lookupKey returns the special key with the given name.
+func lookupKey(name string) (tea.Key, bool) {
+	k, ok := allKeys[name]
+	return k, ok
+}
+
+// keyModifiers are the modifiers recognized by parseKey, in the
+// order used in the names of the special keys.
+var keyModifiers = []string{"ctrl", "shift", "alt"}
+
+// parseKey implements ParseKey using the given special key names.
+// names is used to suggest close matches for unknown keys.
+func parseKey(
+	s string, lookup func(string) (tea.Key, bool), names func() []string,
+) (tea.KeyMsg, error) {
+	// Names like "ctrl+@" or "ctrl+[" are special keys in their own
+	// right.
+	if k, ok := lookup(s); ok {
+		return tea.KeyMsg(k), nil
+	}
+
+	// Strip the modifiers, in any order. The last component is the
+	// key itself, even if it is named like a modifier.
+	keyName := s
+	mods := make(map[string]bool)
+	for {
+		found := false
+		for _, mod := range keyModifiers {
+			if strings.HasPrefix(keyName, mod+"+") && len(keyName) > len(mod)+1 {
+				if mods[mod] {
+					return tea.KeyMsg{}, fmt.Errorf("duplicate modifier %q in key: %s", mod, s)
+				}
+				mods[mod] = true
+				keyName = keyName[len(mod)+1:]
+				found = true
+			}
+		}
+		if !found {
+			break
+		}
+	}
+
+	k, ok := lookup(keyName)
+	if !ok {
+		if utf8.RuneCountInString(keyName) != 1 {
+			return tea.KeyMsg{}, fmt.Errorf("unknown key: %s%s", keyName, didYouMean(keyName, names()))
+		}
+		// Not a special key: it's a rune.
+		k = tea.Key{Type: tea.KeyRunes, Runes: []rune(keyName)}
+	}
+
+	// ctrl and shift designate distinct special keys in bubbletea.
+	if mods["ctrl"] || mods["shift"] {
+		var name strings.Builder
+		for _, mod := range keyModifiers[:2] {
+			if mods[mod] {
+				name.WriteString(mod + "+")
+			}
+		}
+		name.WriteString(keyName)
+		if mk, ok := lookup(name.String()); ok {
+			k = mk
+		} else if r := []rune(keyName); mods["shift"] && !mods["ctrl"] &&
+			k.Type == tea.KeyRunes && unicode.ToUpper(r[0]) != r[0] {
+			// shift on a letter produces the upper case letter.
+			k.Runes = []rune{unicode.ToUpper(r[0])}
+		} else {
+			return tea.KeyMsg{}, fmt.Errorf("unsupported key combination: %s", s)
+		}
+	}
+	k.Alt = mods["alt"]
+	return tea.KeyMsg(k), nil
+}
+
+// keyString returns the canonical name of the key, as accepted by
+// ParseKey. This is the name produced by bubbletea, except for the
+// space key.
+func keyString(k tea.Key) string {
+	if k.Type == tea.KeySpace {
+		if k.Alt {
+			return "alt+space"
+		}
+		return "space"
+	}
+	return k.String()
+}
+
+// fmtMsg formats a message for the test output. Key and mouse
+// messages are printed using their canonical name, as accepted by
+// ParseKey and ParseMouse, which is stable across bubbletea
+// versions; other messages use Go syntax.
+func fmtMsg(msg tea.Msg) string {
+	switch m := msg.(type) {
+	case tea.KeyMsg:
+		return fmt.Sprintf("%T: %s", msg, keyString(tea.Key(m)))
+	case tea.MouseMsg:
+		return fmt.Sprintf("%T: %s", msg, mouseString(tea.MouseEvent(m)))
+	}
+	return fmt.Sprintf("%#v", msg)
+}
+
+// WithKeyAliases defines additional names for the special keys
+// supported by the key command. Each entry maps a new name to the
+// name of an existing key, for example "escape" to "esc".
+//
+// This makes it possible to keep test scripts working when
+// bubbletea renames a key that is not yet covered by the built-in
+// alias table. This can also be changed with `set keyname_alias`.
+//
+// Using an unknown key as target is a programming error and panics.
+func WithKeyAliases(aliases map[string]string) Option {
+	return func(d *driver) {
+		for alias, target := range aliases {
+			if err := d.addKeyAlias(alias, target); err != nil {
+				panic(err)
+			}
+		}
+	}
+}
+
+// keyAliasGroups lists names which designate the same key across
+// bubbletea versions. The first name of each group supported by the
+// current version of bubbletea is the canonical name; the others
+// become aliases for it. This keeps older test scripts working after
+// a key is renamed.
+var keyAliasGroups = [][]string{
+	{"esc", "escape", "ctrl+["},
+	{"enter", "return", "ctrl+m"},
+	{"tab", "ctrl+i"},
+	{"backspace", "ctrl+?"},
+	{"delete", "del"},
+	{"insert", "ins"},
+	{"pgup", "pageup", "page_up"},
+	{"pgdown", "pagedown", "page_down"},
+	{"shift+tab", "backtab"},
+	{"ctrl+@", "ctrl+space"},
+}
+
+// addKeyAliasGroups extends keys with the aliases from
+// keyAliasGroups.
+func addKeyAliasGroups(keys map[string]tea.Key) {
+	for _, group := range keyAliasGroups {
+		var canonical string
+		for _, name := range group {
+			if _, ok := keys[name]; ok {
+				canonical = name
+				break
+			}
+		}
+		if canonical == "" {
+			// None of the names is known to this version of bubbletea.
+			continue
+		}
+		for _, name := range group {
+			if _, ok := keys[name]; !ok {
+				keys[name] = keys[canonical]
+			}
+		}
+	}
+}
+
+// addKeyAlias defines alias as a new name for the key target.
+func (d *driver) addKeyAlias(alias, target string) error {
+	if _, ok := allKeys[target]; !ok {
+		return fmt.Errorf("unknown key: %s%s", target, didYouMean(target, keyNames()))
+	}
+	if d.keyAliases == nil {
+		d.keyAliases = make(map[string]string)
+	}
+	d.keyAliases[alias] = target
+	return nil
+}
+
+// lookupKey returns the special key with the given name.
+func (d *driver) lookupKey(name string) (tea.Key, bool) {
+	if target, ok := d.keyAliases[name]; ok {
+		name = target
+	}
+	return lookupKey(name)
+}
+
+// fmtKeyAliases formats the key aliases for the keyname_alias
+// setting.
+func (d *driver) fmtKeyAliases() string {
+	aliases := make([]string, 0, len(d.keyAliases))
+	for alias, target := range d.keyAliases {
+		aliases = append(aliases, alias+":"+target)
+	}
+	sort.Strings(aliases)
+	return strings.Join(aliases, ",")
+}
+
+// setKeyAliases replaces the key aliases from the value of the
+// keyname_alias setting.
+func (d *driver) setKeyAliases(val string) error {
+	d.keyAliases = nil
+	if val == "" {
+		return nil
+	}
+	for _, def := range strings.Split(val, ",") {
+		parts := strings.SplitN(def, ":", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return fmt.Errorf("invalid key alias %q, expected <alias>:<key>", def)
+		}
+		if err := d.addKeyAlias(parts[0], parts[1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package catwalk
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestParseKey(t *testing.T) {
+	testData := []struct {
+		input    string
+		expected string
+	}{
+		{"enter", `tea.KeyMsg{Type:13, Runes:[]int32(nil), Alt:false}`},
+		{"alt+ctrl+down", `tea.KeyMsg{Type:-14, Runes:[]int32(nil), Alt:true}`},
+		{"space", `tea.KeyMsg{Type:-12, Runes:[]int32{32}, Alt:false}`},
+		{"escape", `tea.KeyMsg{Type:27, Runes:[]int32(nil), Alt:false}`},
+		{"a", `tea.KeyMsg{Type:-1, Runes:[]int32{97}, Alt:false}`},
+		{"alt+é", `tea.KeyMsg{Type:-1, Runes:[]int32{233}, Alt:true}`},
+		{"ctrl+alt+down", `tea.KeyMsg{Type:-14, Runes:[]int32(nil), Alt:true}`},
+		{"alt+up", `tea.KeyMsg{Type:-2, Runes:[]int32(nil), Alt:true}`},
+		{"shift+ctrl+up", `tea.KeyMsg{Type:-21, Runes:[]int32(nil), Alt:false}`},
+		{"alt+ctrl+c", `tea.KeyMsg{Type:3, Runes:[]int32(nil), Alt:true}`},
+		{"alt+ctrl+@", `tea.KeyMsg{Type:0, Runes:[]int32(nil), Alt:true}`},
+		{"shift+a", `tea.KeyMsg{Type:-1, Runes:[]int32{65}, Alt:false}`},
+		{"alt++", `tea.KeyMsg{Type:-1, Runes:[]int32{43}, Alt:true}`},
+		{"ctrl+shift+a", `error: unsupported key combination: ctrl+shift+a`},
+		{"shift+1", `error: unsupported key combination: shift+1`},
+		{"alt+alt+a", `error: duplicate modifier "alt" in key: alt+alt+a`},
+		{"ctrl+entr", `error: unknown key: entr (did you mean "enter"?)`},
+		{"entr", `error: unknown key: entr (did you mean "enter"?)`},
+		{"xyzzy", `error: unknown key: xyzzy`},
+	}
+	for _, tc := range testData {
+		var actual string
+		if msg, err := ParseKey(tc.input); err != nil {
+			actual = fmt.Sprintf("error: %v", err)
+		} else {
+			actual = fmt.Sprintf("%#v", msg)
+		}
+		if actual != tc.expected {
+			t.Errorf("%s: expected:\n%s\ngot:\n%s", tc.input, tc.expected, actual)
+		}
+	}
+}
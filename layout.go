@@ -0,0 +1,146 @@
+package catwalk
+
+import (
+	"fmt"
+	"sort"
+)
+
+// KeyLayout maps logical actions to the sequence of keys which
+// perform them, for use with `key @<action>` in test scripts.
+// The keys are named like in the key command, e.g. "ctrl+s" or "q".
+type KeyLayout map[string][]string
+
+// builtinKeyLayouts are the layouts available by default.
+var builtinKeyLayouts = map[string]KeyLayout{
+	"default": {
+		"up":         {"up"},
+		"down":       {"down"},
+		"left":       {"left"},
+		"right":      {"right"},
+		"home":       {"home"},
+		"end":        {"end"},
+		"page_up":    {"pgup"},
+		"page_down":  {"pgdown"},
+		"delete":     {"delete"},
+		"undo":       {"ctrl+z"},
+		"search":     {"ctrl+f"},
+		"save":       {"ctrl+s"},
+		"quit":       {"ctrl+c"},
+		"cancel":     {"esc"},
+		"confirm":    {"enter"},
+		"next_field": {"tab"},
+		"prev_field": {"shift+tab"},
+	},
+	"emacs": {
+		"up":         {"ctrl+p"},
+		"down":       {"ctrl+n"},
+		"left":       {"ctrl+b"},
+		"right":      {"ctrl+f"},
+		"home":       {"ctrl+a"},
+		"end":        {"ctrl+e"},
+		"page_up":    {"alt+v"},
+		"page_down":  {"ctrl+v"},
+		"delete":     {"ctrl+d"},
+		"undo":       {"ctrl+_"},
+		"search":     {"ctrl+s"},
+		"save":       {"ctrl+x", "ctrl+s"},
+		"quit":       {"ctrl+x", "ctrl+c"},
+		"cancel":     {"ctrl+g"},
+		"confirm":    {"enter"},
+		"next_field": {"tab"},
+		"prev_field": {"shift+tab"},
+	},
+	"vim": {
+		"up":         {"k"},
+		"down":       {"j"},
+		"left":       {"h"},
+		"right":      {"l"},
+		"home":       {"0"},
+		"end":        {"$"},
+		"page_up":    {"ctrl+b"},
+		"page_down":  {"ctrl+f"},
+		"delete":     {"x"},
+		"undo":       {"u"},
+		"search":     {"/"},
+		"save":       {":", "w", "enter"},
+		"quit":       {":", "q", "enter"},
+		"cancel":     {"esc"},
+		"confirm":    {"enter"},
+		"next_field": {"tab"},
+		"prev_field": {"shift+tab"},
+	},
+}
+
+// defaultKeyLayout is the name of the layout used by default.
+const defaultKeyLayout = "default"
+
+// WithKeyLayout registers a keyboard layout under the given name and
+// makes it the active layout. Test scripts then use `key @<action>`
+// to press the keys mapped to the action in the active layout. This
+// makes it possible to retarget a test suite to a different set of
+// key bindings by swapping the layout.
+//
+// The built-in layouts "default", "emacs" and "vim" can be replaced
+// this way. The active layout can also be changed with
+// `set key_layout`.
+func WithKeyLayout(name string, layout KeyLayout) Option {
+	return func(d *driver) {
+		if d.keyLayouts == nil {
+			d.keyLayouts = make(map[string]KeyLayout)
+		}
+		d.keyLayouts[name] = layout
+		d.keyLayout = name
+	}
+}
+
+// findKeyLayout returns the layout with the given name.
+func (d *driver) findKeyLayout(name string) (KeyLayout, bool) {
+	if l, ok := d.keyLayouts[name]; ok {
+		return l, true
+	}
+	l, ok := builtinKeyLayouts[name]
+	return l, ok
+}
+
+// setKeyLayout implements `set key_layout`.
+func (d *driver) setKeyLayout(name string) error {
+	if _, ok := d.findKeyLayout(name); !ok {
+		return fmt.Errorf("unknown key layout: %s%s", name, didYouMean(name, d.keyLayoutNames()))
+	}
+	d.keyLayout = name
+	return nil
+}
+
+// keyLayoutNames returns the names of the available layouts.
+func (d *driver) keyLayoutNames() []string {
+	var names []string
+	for name := range builtinKeyLayouts {
+		names = append(names, name)
+	}
+	for name := range d.keyLayouts {
+		if _, ok := builtinKeyLayouts[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// layoutKeys returns the key names mapped to the given action in the
+// active layout.
+func (d *driver) layoutKeys(action string) ([]string, error) {
+	name := d.keyLayout
+	if name == "" {
+		name = defaultKeyLayout
+	}
+	layout, _ := d.findKeyLayout(name)
+	keys, ok := layout[action]
+	if !ok {
+		actions := make([]string, 0, len(layout))
+		for a := range layout {
+			actions = append(actions, a)
+		}
+		return nil, fmt.Errorf("unknown action in key layout %s: %s%s", name, action, didYouMean(action, actions))
+	}
+	return keys, nil
+}
@@ -0,0 +1,93 @@
+package catwalk
+
+import (
+	"testing"
+
+	"github.com/cockroachdb/datadriven"
+)
+
+func TestKeyLayout(t *testing.T) {
+	const test = `
+run observe=msgs
+defer_processing
+key @save
+----
+-- msgs:
+msg queue sz: 1
+0:tea.KeyMsg: alt+s
+
+set key_layout=vim
+----
+key_layout: vim
+
+run observe=msgs
+key @save
+----
+-- msgs:
+msg queue sz: 4
+0:tea.KeyMsg: alt+s
+1:tea.KeyMsg: :
+2:tea.KeyMsg: w
+3:tea.KeyMsg: enter
+
+# The reset restores the layout configured with WithKeyLayout.
+reset key_layout
+----
+ok
+
+run observe=msgs
+key @save
+----
+-- msgs:
+msg queue sz: 5
+0:tea.KeyMsg: alt+s
+1:tea.KeyMsg: :
+2:tea.KeyMsg: w
+3:tea.KeyMsg: enter
+4:tea.KeyMsg: alt+s
+
+set key_layout=default
+----
+key_layout: default
+
+run observe=msgs
+key @quit
+----
+-- msgs:
+msg queue sz: 6
+0:tea.KeyMsg: alt+s
+1:tea.KeyMsg: :
+2:tea.KeyMsg: w
+3:tea.KeyMsg: enter
+4:tea.KeyMsg: alt+s
+5:tea.KeyMsg: ctrl+c
+`
+	RunModelFromString(t, test, intModel(0), WithKeyLayout("mine", KeyLayout{"save": {"alt+s"}}))
+}
+
+func TestBuiltinKeyLayouts(t *testing.T) {
+	for name, layout := range builtinKeyLayouts {
+		for action, keys := range layout {
+			for _, k := range keys {
+				if _, err := ParseKey(k); err != nil {
+					t.Errorf("%s: %s: %v", name, action, err)
+				}
+			}
+			if _, ok := builtinKeyLayouts[defaultKeyLayout][action]; !ok {
+				t.Errorf("%s: action %s missing from the default layout", name, action)
+			}
+		}
+	}
+}
+
+func TestKeyLayoutErrors(t *testing.T) {
+	runTest := func(input string) string {
+		d := NewDriver(intModel(0))
+		defer d.Close(t)
+		return expectFatal(t, d, &datadriven.TestData{Pos: "test:1", Cmd: "run", Input: input})
+	}
+	const expected = `test:1: unknown action in key layout default: svae (did you mean "save"?)`
+	if err := runTest("key @svae"); err != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, err)
+	}
+}
@@ -0,0 +1,83 @@
+package catwalk
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// link is a hyperlink emitted with an OSC 8 escape sequence.
+type link struct {
+	text   string
+	target string
+}
+
+// observeLinks implements the links observer: it lists the OSC 8
+// hyperlinks in the view, with their text and target.
+func observeLinks(buf io.Writer, m tea.Model) error {
+	links := parseLinks(m.View())
+	if len(links) == 0 {
+		_, err := io.WriteString(buf, "no links\n")
+		return err
+	}
+	for _, l := range links {
+		if _, err := fmt.Fprintf(buf, "%q -> %s\n", l.text, l.target); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// osc8 is the prefix of an OSC 8 hyperlink sequence.
+const osc8 = "\x1b]8;"
+
+// parseLinks extracts the OSC 8 hyperlinks from the given text.
+// A hyperlink has the form:
+//
+//	ESC ] 8 ; params ; target ST text ESC ] 8 ; ; ST
+//
+// where ST is either ESC \ or BEL.
+func parseLinks(s string) []link {
+	var links []link
+	var cur *link
+	for {
+		idx := strings.Index(s, osc8)
+		if idx < 0 {
+			break
+		}
+		if cur != nil {
+			cur.text += s[:idx]
+		}
+		s = s[idx+len(osc8):]
+		// Find the string terminator.
+		end, stLen := len(s), 0
+		if i := strings.Index(s, "\x1b\\"); i >= 0 {
+			end, stLen = i, 2
+		}
+		if i := strings.IndexByte(s, '\a'); i >= 0 && i < end {
+			end, stLen = i, 1
+		}
+		seq := s[:end]
+		s = s[end+stLen:]
+		// Skip the parameters.
+		target := seq
+		if i := strings.IndexByte(seq, ';'); i >= 0 {
+			target = seq[i+1:]
+		}
+		if cur != nil {
+			links = append(links, *cur)
+			cur = nil
+		}
+		if target != "" {
+			cur = &link{target: target}
+		}
+	}
+	if cur != nil {
+		// Unterminated link.
+		cur.text += s
+		links = append(links, *cur)
+	}
+	return links
+}
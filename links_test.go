@@ -0,0 +1,43 @@
+package catwalk
+
+import (
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// linkModel renders a view with hyperlinks.
+type linkModel struct{}
+
+func (linkModel) Init() tea.Cmd                       { return nil }
+func (linkModel) Update(tea.Msg) (tea.Model, tea.Cmd) { return linkModel{}, nil }
+func (linkModel) View() string {
+	return "see \x1b]8;;https://example.com\x1b\\the docs\x1b]8;;\x1b\\ or " +
+		"\x1b]8;id=1;https://example.com/faq\athe FAQ\x1b]8;;\a."
+}
+
+func TestLinks(t *testing.T) {
+	const test = `
+run observe=links
+----
+-- links:
+"the docs" -> https://example.com
+"the FAQ" -> https://example.com/faq
+
+run observe=links@self
+----
+-- links@self:
+"the docs" -> https://example.com
+"the FAQ" -> https://example.com/faq
+`
+	RunModelFromString(t, test, linkModel{},
+		WithSubModel("self", func(m tea.Model) tea.Model { return m }))
+
+	const noLinks = `
+run observe=links
+----
+-- links:
+no links
+`
+	RunModelFromString(t, noLinks, intModel(0))
+}
@@ -0,0 +1,61 @@
+package catwalk
+
+import "fmt"
+
+// logRecord is the description of an Event reported by WithLogger.
+type logRecord struct {
+	warn  bool
+	msg   string
+	attrs []logAttr
+}
+
+// logAttr is an attribute of a logRecord. The value is either a
+// string or a time.Duration.
+type logAttr struct {
+	key string
+	val interface{}
+}
+
+// describeEvent returns the description of the event reported by
+// WithLogger, or false if the event is not reported.
+//
+// Command timeouts and unknown messages are reported as warnings.
+func describeEvent(ev Event) (logRecord, bool) {
+	r := logRecord{attrs: []logAttr{{"pos", ev.Pos}}}
+	attr := func(key string, val interface{}) { r.attrs = append(r.attrs, logAttr{key, val}) }
+	switch ev.Kind {
+	case EventDirectiveStart, EventDirectiveEnd:
+		r.msg = ev.Kind.String()
+		attr("directive", ev.Directive)
+	case EventInit:
+		r.msg = "calling Init"
+	case EventMsgDelivered:
+		r.msg = "message delivered"
+		attr("type", fmt.Sprintf("%T", ev.Msg))
+	case EventCmdStarted:
+		// Reported with the finish event.
+		return r, false
+	case EventCmdFinished:
+		r.msg = "command executed"
+		attr("cmd", ev.Cmd)
+		attr("latency", ev.Latency)
+		attr("result", fmt.Sprintf("%T", ev.Msg))
+		if ev.TimedOut {
+			r.msg = "command timed out"
+			r.warn = true
+		}
+	case EventObservation:
+		r.msg = "observation"
+		attr("observer", ev.Observer)
+	case EventUpdaterDispatch:
+		r.msg = "command dispatched to updater"
+		attr("cmd", ev.Cmd)
+	case EventUnknownMsg:
+		r.msg = "unknown message passed to the model"
+		r.warn = true
+		attr("type", fmt.Sprintf("%T", ev.Msg))
+	default:
+		r.msg = ev.Kind.String()
+	}
+	return r, true
+}
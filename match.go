@@ -0,0 +1,385 @@
+package catwalk
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/cockroachdb/datadriven"
+)
+
+// WithPlaceholders tells the test driver to support placeholders in
+// the expected output of run directives, to match variable content
+// such as durations, sizes or versions:
+//
+//   - [[re]] inside a line matches the regular expression re.
+//   - [[~N ±D]] inside a line matches a number within D of N,
+//     for example [[~42 ±2]] matches 40 to 44. "+-" can be used
+//     in lieu of "±".
+//   - a line starting with re: matches an entire line against the
+//     regular expression that follows.
+//   - a line containing just ... matches zero or more lines.
+//
+// When the actual output matches the expected output with its
+// placeholders, the test passes and the placeholders are preserved
+// when rewriting the test file. Otherwise, the lines which match
+// their placeholders are reported with their expected form, so that
+// the difference only shows the lines which do not match, and the
+// placeholders of the matching lines are also preserved when
+// rewriting. This can also be changed with `set placeholders`.
+func WithPlaceholders() Option {
+	return func(d *driver) {
+		d.placeholders = true
+	}
+}
+
+// WithIgnoreLines tells the test driver to ignore the lines matching
+// any of the given regular expressions, in both the expected and the
+// actual output of run directives, when comparing them. This is
+// useful e.g. for debug banners and version strings. This can also
+// be changed with `set ignore_lines`, or for a single run directive
+// with its ignore_lines argument.
+//
+// Using an invalid regular expression is a programming error and
+// panics.
+func WithIgnoreLines(patterns ...string) Option {
+	return func(d *driver) {
+		for _, p := range patterns {
+			d.ignoreLines = append(d.ignoreLines, regexp.MustCompile(p))
+		}
+	}
+}
+
+// matchExpected returns the expected output of the directive if it
+// matches the actual output, once the ignored lines are removed and
+// taking placeholders into account; and the actual output otherwise.
+// This makes the comparison by datadriven succeed, and preserves the
+// expected output when rewriting the test file.
+func (d *driver) matchExpected(t TB, td *datadriven.TestData, actual string) string {
+	if actual == td.Expected {
+		return actual
+	}
+	ignore := d.ignoreLines
+	for _, arg := range td.CmdArgs {
+		if arg.Key == "ignore_lines" {
+			res, err := compilePatterns(arg.Vals)
+			if err != nil {
+				t.Fatalf("%s: invalid ignore_lines value: %v", d.pos, err)
+			}
+			ignore = append(ignore[:len(ignore):len(ignore)], res...)
+		}
+	}
+	expected := td.Expected
+	if len(ignore) > 0 {
+		expected = removeLines(expected, ignore)
+		actual = removeLines(actual, ignore)
+	}
+	if expected == actual || (d.placeholders && matchPlaceholders(expected, actual)) {
+		return td.Expected
+	}
+	if d.placeholders {
+		return mergePlaceholders(expected, actual)
+	}
+	return actual
+}
+
+// expectAnyOf implements the expect_any_of input command: the view
+// must contain at least one of the given fragments. This is useful
+// for behavior which legitimately differs across platforms.
+func (d *driver) expectAnyOf(t TB, trace bool, args ...string) {
+	alts, err := parseQuotedList(strings.Join(args, " "))
+	if err != nil {
+		t.Fatalf("%s: expect_any_of: %v", d.pos, err)
+	}
+	if len(alts) == 0 {
+		t.Fatalf("%s: syntax: expect_any_of \"<fragment>\"...", d.pos)
+	}
+	// Deliver the pending messages, so that the view reflects the
+	// previous input commands.
+	if !d.deferProcessing {
+		d.processTeaMsgs(trace)
+	}
+	view := d.m.View()
+	if len(d.normalized) > 0 {
+		view = d.normalizeView(view)
+	}
+	for i, alt := range alts {
+		if strings.Contains(view, alt) {
+			d.trace(trace, "view matches alternative %d: %q", i+1, alt)
+			return
+		}
+	}
+	var buf strings.Builder
+	for _, alt := range alts {
+		fmt.Fprintf(&buf, "\n  %q", alt)
+	}
+	t.Fatalf("%s: the view contains none of the alternatives:%s\nview:\n%s", d.pos, buf.String(), view)
+}
+
+// parseQuotedList parses a list of space-separated strings quoted
+// with double quotes, using Go syntax.
+func parseQuotedList(s string) ([]string, error) {
+	var res []string
+	for {
+		s = strings.TrimLeft(s, " ")
+		if s == "" {
+			return res, nil
+		}
+		if !strings.HasPrefix(s, `"`) {
+			return nil, fmt.Errorf("expected quoted string, got %q", s)
+		}
+		end := closingQuote(s)
+		if end < 0 {
+			return nil, fmt.Errorf("unterminated quoted string: %s", s)
+		}
+		v, err := strconv.Unquote(s[:end+1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid quoted string %s: %v", s[:end+1], err)
+		}
+		res = append(res, v)
+		s = s[end+1:]
+	}
+}
+
+// removeLines removes the lines matching any of the patterns.
+func removeLines(s string, patterns []*regexp.Regexp) string {
+	var buf strings.Builder
+	for _, line := range strings.SplitAfter(s, "\n") {
+		body := strings.TrimSuffix(line, "\n")
+		ignored := false
+		for _, re := range patterns {
+			if re.MatchString(body) {
+				ignored = true
+				break
+			}
+		}
+		if !ignored {
+			buf.WriteString(line)
+		}
+	}
+	return buf.String()
+}
+
+// compilePatterns compiles the given regular expressions.
+func compilePatterns(patterns []string) ([]*regexp.Regexp, error) {
+	res := make([]*regexp.Regexp, len(patterns))
+	for i, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, err
+		}
+		res[i] = re
+	}
+	return res, nil
+}
+
+// matchPlaceholders returns true if the actual output matches the
+// expected output, taking placeholders into account.
+func matchPlaceholders(expected, actual string) bool {
+	if !strings.Contains(expected, "[[") && !strings.Contains(expected, "...") &&
+		!strings.Contains(expected, regexpLinePrefix) {
+		return expected == actual
+	}
+	re, tols, err := placeholderRegexp(expected)
+	if err != nil {
+		return false
+	}
+	m := re.FindStringSubmatch(actual)
+	if m == nil {
+		return false
+	}
+	i := 0
+	for g, name := range re.SubexpNames() {
+		if i >= len(tols) || name != tolGroup(i) {
+			continue
+		}
+		v, err := strconv.ParseFloat(m[g], 64)
+		if err != nil || math.Abs(v-tols[i].value) > tols[i].delta {
+			return false
+		}
+		i++
+	}
+	return true
+}
+
+// regexpLinePrefix introduces a line of the expected output which
+// is matched as a regular expression.
+const regexpLinePrefix = "re:"
+
+// mergePlaceholders returns the actual output, where the lines which
+// match a line of the expected output with placeholders are replaced
+// by the expected line, and the runs of lines matched by ... are
+// replaced by ... itself. The lines are aligned so as to match as
+// many expected lines as possible.
+func mergePlaceholders(expected, actual string) string {
+	exp := strings.SplitAfter(expected, "\n")
+	act := strings.SplitAfter(actual, "\n")
+	lineMatches := func(i, j int) bool {
+		e, a := exp[i], act[j]
+		if strings.HasSuffix(e, "\n") != strings.HasSuffix(a, "\n") {
+			return false
+		}
+		return matchPlaceholders(strings.TrimSuffix(e, "\n"), strings.TrimSuffix(a, "\n"))
+	}
+	isWildcard := func(i int) bool { return strings.TrimSuffix(exp[i], "\n") == "..." }
+
+	// best[i][j] is the largest number of expected lines which can
+	// be matched by aligning exp[i:] with act[j:].
+	best := make([][]int, len(exp)+1)
+	for i := range best {
+		best[i] = make([]int, len(act)+1)
+	}
+	for i := len(exp) - 1; i >= 0; i-- {
+		for j := len(act); j >= 0; j-- {
+			b := best[i+1][j]
+			if j < len(act) {
+				// Either act[j] is not matched, or it is covered by
+				// a wildcard which stays active.
+				b = max(b, best[i][j+1])
+				if !isWildcard(i) && lineMatches(i, j) {
+					b = max(b, best[i+1][j+1]+1)
+				}
+			}
+			best[i][j] = b
+		}
+	}
+
+	var buf strings.Builder
+	i, j := 0, 0
+	for i < len(exp) || j < len(act) {
+		switch {
+		case i < len(exp) && isWildcard(i):
+			// Emit the wildcard once, then consume the actual lines
+			// it covers in the best alignment.
+			buf.WriteString(exp[i])
+			for j < len(act) && best[i][j+1] == best[i][j] {
+				j++
+			}
+			i++
+		case i < len(exp) && j < len(act) && lineMatches(i, j) && best[i][j] == best[i+1][j+1]+1:
+			buf.WriteString(exp[i])
+			i++
+			j++
+		case j < len(act) && (i == len(exp) || best[i][j] == best[i][j+1]):
+			buf.WriteString(act[j])
+			j++
+		default:
+			i++
+		}
+	}
+	return buf.String()
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// tolerance is a numeric placeholder of the form [[~value ±delta]].
+type tolerance struct {
+	value, delta float64
+}
+
+// toleranceRe matches the contents of a numeric placeholder.
+var toleranceRe = regexp.MustCompile(`^~\s*([-+]?[0-9]*\.?[0-9]+)\s*(?:±|\+-|\+/-)\s*([0-9]*\.?[0-9]+)$`)
+
+// numberRe matches the numbers compared to numeric placeholders.
+const numberRe = `[-+]?[0-9]*\.?[0-9]+`
+
+// tolGroup is the name of the capture group for the i-th
+// numeric placeholder.
+func tolGroup(i int) string {
+	return fmt.Sprintf("tol%d", i)
+}
+
+// parseTolerance parses the contents of a numeric placeholder.
+func parseTolerance(s string) (tolerance, bool) {
+	m := toleranceRe.FindStringSubmatch(s)
+	if m == nil {
+		return tolerance{}, false
+	}
+	v, err1 := strconv.ParseFloat(m[1], 64)
+	dv, err2 := strconv.ParseFloat(m[2], 64)
+	if err1 != nil || err2 != nil {
+		return tolerance{}, false
+	}
+	return tolerance{value: v, delta: dv}, true
+}
+
+// placeholderRegexp compiles the expected output into a regular
+// expression which matches the entire actual output. The numbers
+// matched by the numeric placeholders are captured in the groups
+// named by tolGroup, and must be checked against the returned
+// tolerances.
+func placeholderRegexp(expected string) (*regexp.Regexp, []tolerance, error) {
+	var tols []tolerance
+	var buf strings.Builder
+	buf.WriteString(`\A`)
+	for _, line := range strings.SplitAfter(expected, "\n") {
+		body := strings.TrimSuffix(line, "\n")
+		nl := len(body) < len(line)
+		if body == "..." {
+			buf.WriteString(`(?:[^\n]*\n)*`)
+			if !nl {
+				buf.WriteString(`[^\n]*`)
+			}
+			continue
+		}
+		if strings.HasPrefix(body, regexpLinePrefix) {
+			// The line is anchored already; ^ and $ are allowed.
+			buf.WriteString(`(?m:` + strings.TrimPrefix(body, regexpLinePrefix) + `)`)
+			if nl {
+				buf.WriteString(`\n`)
+			}
+			continue
+		}
+		for {
+			start := strings.Index(body, "[[")
+			if start < 0 {
+				break
+			}
+			end := strings.Index(body[start+2:], "]]")
+			if end < 0 {
+				break
+			}
+			buf.WriteString(regexp.QuoteMeta(body[:start]))
+			ph := body[start+2 : start+2+end]
+			if tol, ok := parseTolerance(ph); ok {
+				buf.WriteString(`(?P<` + tolGroup(len(tols)) + `>` + numberRe + `)`)
+				tols = append(tols, tol)
+			} else {
+				buf.WriteString(`(?:` + ph + `)`)
+			}
+			body = body[start+2+end+2:]
+		}
+		buf.WriteString(regexp.QuoteMeta(body))
+		if nl {
+			buf.WriteString(`\n`)
+		}
+	}
+	buf.WriteString(`\z`)
+	re, err := regexp.Compile(buf.String())
+	return re, tols, err
+}
+
+// outputMatches returns true if the output of a directive matches
+// the expected output. Like datadriven, a newline is added to a
+// non-empty output which does not end with one, since the expected
+// output in test files always ends with a newline.
+func outputMatches(output, expected string) bool {
+	return normalizeNewline(output) == expected
+}
+
+// normalizeNewline adds a newline at the end of a non-empty output
+// which does not end with one, like datadriven does before comparing
+// the output with the expected output.
+func normalizeNewline(output string) string {
+	if output != "" && !strings.HasSuffix(output, "\n") {
+		output += "\n"
+	}
+	return output
+}
@@ -0,0 +1,163 @@
+package catwalk
+
+import (
+	"testing"
+
+	"github.com/cockroachdb/datadriven"
+)
+
+func TestMatchPlaceholders(t *testing.T) {
+	testData := []struct {
+		expected string
+		actual   string
+		match    bool
+	}{
+		{"took [[\\d+]]ms\n", "took 123ms\n", true},
+		{"took [[\\d+]]ms\n", "took abcms\n", false},
+		{"a [[x|y]] b [[z*]]\n", "a y b zzz\n", true},
+		{"first\n...\nlast\n", "first\nlast\n", true},
+		{"first\n...\nlast\n", "first\nsecond\nthird\nlast\n", true},
+		{"first\n...\nlast\n", "first\nsecond\n", false},
+		{"first\n...", "first\nsecond\nthird", true},
+		{"a.b\n", "axb\n", false},
+		{"progress: [[~42 ±2]]%\n", "progress: 43%\n", true},
+		{"progress: [[~42 ±2]]%\n", "progress: 45%\n", false},
+		{"size [[~1.5 +- 0.1]]MB, [[\\d+]] files, [[~10 ±0]]s\n", "size 1.45MB, 3 files, 10s\n", true},
+		{"size [[~1.5 +- 0.1]]MB, [[\\d+]] files, [[~10 ±0]]s\n", "size 1.45MB, 3 files, 11s\n", false},
+		{"[[~-3 ±1]]\n", "-2.5\n", true},
+		{"[[(]]\n", "(\n", false},
+		{"no placeholders\n", "no placeholders\n", true},
+		{"no placeholders\n", "other\n", false},
+		{"re:^at [0-9:]+$\nsize [[\\d]]\n", "at 12:34\nsize 3\n", true},
+		{"re:at [0-9:]+\n", "at 12:34 pm\n", false},
+		{"re:(\n", "(\n", false},
+	}
+	for _, tc := range testData {
+		if actual := matchPlaceholders(tc.expected, tc.actual); actual != tc.match {
+			t.Errorf("%q vs %q: expected %v, got %v", tc.expected, tc.actual, tc.match, actual)
+		}
+	}
+}
+
+func TestPlaceholders(t *testing.T) {
+	const test = `
+run
+----
+TEA PRINT: {MODEL [[[A-Z]+]]}
+...
+
+run
+----
+-- view:
+re:MODEL \w+🛇
+
+set placeholders=off
+----
+placeholders: off
+
+run
+----
+-- view:
+MODEL VIEW🛇
+`
+	RunModelFromString(t, test, emptyModel{}, WithPlaceholders())
+}
+
+func TestIgnoreLines(t *testing.T) {
+	const test = `
+run observe=(view,gostruct)
+----
+TEA PRINT: {MODEL INIT}
+-- view:
+this line is ignored
+VALUE: 0🛇
+
+run ignore_lines=(^--.*$) observe=(view,gostruct)
+----
+VALUE: 0🛇
+catwalk.intModel(0)
+
+set ignore_lines=^BANNER
+----
+ignore_lines: ^BANNER
+
+run observe=gostruct
+----
+BANNER v1.2.3
+-- gostruct:
+catwalk.intModel(0)
+`
+	RunModelFromString(t, test, intModel(0), WithIgnoreLines("^(TEA PRINT|this line|-- gostruct|catwalk)"))
+}
+
+func TestExpectAnyOf(t *testing.T) {
+	const test = `
+run trace=on
+type a
+expect_any_of "C:\\dir" "VALUE: 1"
+----
+-- trace: calling Init
+-- trace: before "type a"
+-- trace: after "type"
+-- view:
+VALUE: 0🛇
+-- trace: before "expect_any_of \"C:\\\\dir\" \"VALUE: 1\""
+-- trace: processing 1 messages
+-- trace: msg tea.KeyMsg: a
+-- trace: view matches alternative 2: "VALUE: 1"
+-- trace: after "expect_any_of"
+-- view:
+VALUE: 1🛇
+-- trace: before finish
+-- view:
+VALUE: 1🛇
+-- trace: at end
+-- view:
+VALUE: 1🛇
+`
+	RunModelFromString(t, test, intModel(0))
+
+	testData := []struct {
+		input, expected string
+	}{
+		{`expect_any_of "/dir" "C:\\dir"`, `test:1: the view contains none of the alternatives:
+  "/dir"
+  "C:\\dir"
+view:
+VALUE: 0`},
+		{`expect_any_of`, `test:1: syntax: expect_any_of "<fragment>"...`},
+		{`expect_any_of /dir`, `test:1: expect_any_of: expected quoted string, got "/dir"`},
+		{`expect_any_of "/dir`, `test:1: expect_any_of: unterminated quoted string: "/dir`},
+	}
+	for _, tc := range testData {
+		d := NewDriver(intModel(0))
+		fatal := expectFatal(t, d, &datadriven.TestData{Pos: "test:1", Cmd: "run", Input: tc.input})
+		d.Close(t)
+		if fatal != tc.expected {
+			t.Errorf("%q: expected:\n%s\ngot:\n%s", tc.input, tc.expected, fatal)
+		}
+	}
+}
+
+func TestMergePlaceholders(t *testing.T) {
+	testData := []struct {
+		expected string
+		actual   string
+		merged   string
+	}{
+		// The lines matching their placeholders keep their expected form.
+		{"took [[\\d+]]ms\nsize 3\n", "took 12ms\nsize 4\n", "took [[\\d+]]ms\nsize 4\n"},
+		{"re:at [0-9:]+\nsize 3\n", "at 12:34\nsize 4\n", "re:at [0-9:]+\nsize 4\n"},
+		// The lines which do not match are reported as-is.
+		{"took [[\\d+]]ms\n", "took abcms\n", "took abcms\n"},
+		// Extra and missing lines are aligned around the matching ones.
+		{"a [[x|y]]\nb\nc [[\\d]]\n", "a x\nc 1\nd\n", "a [[x|y]]\nc [[\\d]]\nd\n"},
+		// The lines covered by a wildcard are not reported.
+		{"first\n...\nlast [[\\d]]\n", "first\nx\ny\nlast 1\nmore\n", "first\n...\nlast [[\\d]]\nmore\n"},
+	}
+	for _, tc := range testData {
+		if actual := mergePlaceholders(tc.expected, tc.actual); actual != tc.merged {
+			t.Errorf("%q vs %q: expected %q, got %q", tc.expected, tc.actual, tc.merged, actual)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package catwalk
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// WithMemGrowthLimit tells the test driver to estimate the size of
+// the model after the observations of each run directive, and to
+// fail the test if it grows by more than the given number of bytes
+// from the first estimate.
+// This catches models that accumulate state as a script progresses,
+// e.g. in unbounded history slices. This can also be changed with
+// `set mem_growth_limit`.
+//
+// The size is estimated by walking the model's data structures;
+// see the memsize observer.
+func WithMemGrowthLimit(bytes int64) Option {
+	return func(d *driver) {
+		d.memGrowthLimit = bytes
+	}
+}
+
+// observeMemSize implements the memsize observer.
+func observeMemSize(buf io.Writer, m tea.Model) error {
+	_, err := fmt.Fprintf(buf, "approx size: %d bytes\n", estimateSize(m))
+	return err
+}
+
+// checkMemGrowth implements the check configured by
+// WithMemGrowthLimit.
+func (d *driver) checkMemGrowth(t TB) {
+	if d.memGrowthLimit <= 0 {
+		return
+	}
+	sz := estimateSize(d.m)
+	if !d.memBaselineSet {
+		d.memBaseline = sz
+		d.memBaselineSet = true
+	}
+	if growth := sz - d.memBaseline; growth > d.memGrowthLimit {
+		t.Fatalf("%s: the model grew by %d bytes (from %d to %d), over the limit of %d bytes",
+			d.pos, growth, d.memBaseline, sz, d.memGrowthLimit)
+	}
+}
+
+// estimateSize estimates the number of bytes retained by x,
+// e.g. a model.
+func estimateSize(x interface{}) int64 {
+	v := reflect.ValueOf(x)
+	if !v.IsValid() {
+		return 0
+	}
+	return int64(v.Type().Size()) + indirectSize(v, make(map[visit]bool))
+}
+
+// mapEntryOverhead is the approximate per-entry
+// overhead of Go maps.
+const mapEntryOverhead = 8
+
+// indirectSize estimates the number of bytes reachable from v,
+// excluding the size of v itself. Values reachable via multiple
+// pointers are only counted once. Funcs are not counted.
+func indirectSize(v reflect.Value, seen map[visit]bool) int64 {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return 0
+		}
+		k := visit{v.Pointer(), v.Type()}
+		if seen[k] {
+			return 0
+		}
+		seen[k] = true
+		return int64(v.Type().Elem().Size()) + indirectSize(v.Elem(), seen)
+
+	case reflect.Struct:
+		var sz int64
+		for i := 0; i < v.NumField(); i++ {
+			sz += indirectSize(v.Field(i), seen)
+		}
+		return sz
+
+	case reflect.Slice:
+		if v.IsNil() {
+			return 0
+		}
+		k := visit{v.Pointer(), v.Type()}
+		if seen[k] {
+			return 0
+		}
+		seen[k] = true
+		sz := int64(v.Cap()) * int64(v.Type().Elem().Size())
+		for i := 0; i < v.Len(); i++ {
+			sz += indirectSize(v.Index(i), seen)
+		}
+		return sz
+
+	case reflect.Array:
+		var sz int64
+		for i := 0; i < v.Len(); i++ {
+			sz += indirectSize(v.Index(i), seen)
+		}
+		return sz
+
+	case reflect.String:
+		return int64(v.Len())
+
+	case reflect.Map:
+		if v.IsNil() {
+			return 0
+		}
+		k := visit{v.Pointer(), v.Type()}
+		if seen[k] {
+			return 0
+		}
+		seen[k] = true
+		entrySize := int64(v.Type().Key().Size()+v.Type().Elem().Size()) + mapEntryOverhead
+		sz := int64(v.Len()) * entrySize
+		iter := v.MapRange()
+		for iter.Next() {
+			sz += indirectSize(iter.Key(), seen) + indirectSize(iter.Value(), seen)
+		}
+		return sz
+
+	case reflect.Interface:
+		if v.IsNil() {
+			return 0
+		}
+		e := v.Elem()
+		return int64(e.Type().Size()) + indirectSize(e, seen)
+
+	case reflect.Chan:
+		if v.IsNil() {
+			return 0
+		}
+		return int64(v.Cap()) * int64(v.Type().Elem().Size())
+
+	default:
+		return 0
+	}
+}
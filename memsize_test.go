@@ -0,0 +1,86 @@
+package catwalk
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"unsafe"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/cockroachdb/datadriven"
+)
+
+// historyModel accumulates the keys it receives.
+type historyModel struct {
+	keys []string
+}
+
+func (historyModel) Init() tea.Cmd { return nil }
+func (m historyModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if kmsg, ok := msg.(tea.KeyMsg); ok {
+		m.keys = append(m.keys, strings.Repeat(kmsg.String(), 100))
+	}
+	return m, nil
+}
+func (m historyModel) View() string { return "" }
+
+func TestModelSize(t *testing.T) {
+	type node struct {
+		next *node
+		data [10]int64
+	}
+	n := &node{}
+	n.next = n
+	shared := "hello"
+	type pair struct{ a, b string }
+	// The expected sizes are computed with unsafe.Sizeof, as the
+	// sizes of the headers depend on the platform.
+	testData := []struct {
+		v        interface{}
+		expected uintptr
+	}{
+		{int64(1), unsafe.Sizeof(int64(1))},
+		{"hello", unsafe.Sizeof("") + 5},
+		{[]int32{1, 2, 3}, unsafe.Sizeof([]int32(nil)) + 3*unsafe.Sizeof(int32(0))},
+		{pair{shared, shared}, unsafe.Sizeof(pair{}) + 2*5},
+		// Cycles are only counted once.
+		{n, unsafe.Sizeof(n) + unsafe.Sizeof(*n)},
+		{map[int32]int32{1: 2}, unsafe.Sizeof(map[int32]int32(nil)) + 2*unsafe.Sizeof(int32(0)) + mapEntryOverhead},
+	}
+	for _, tc := range testData {
+		if actual := estimateSize(tc.v); actual != int64(tc.expected) {
+			t.Errorf("%#v: expected %d, got %d", tc.v, tc.expected, actual)
+		}
+	}
+}
+
+func TestMemGrowthLimit(t *testing.T) {
+	if strconv.IntSize != 64 {
+		// The expected sizes below assume 64-bit headers.
+		t.Skip("test only valid on 64-bit platforms")
+	}
+	const test = `
+run observe=memsize
+----
+-- memsize:
+approx size: 24 bytes
+
+run observe=memsize
+type ab
+----
+-- memsize:
+approx size: 256 bytes
+`
+	RunModelFromString(t, test, historyModel{}, WithMemGrowthLimit(1000))
+
+	d := NewDriver(historyModel{}, WithMemGrowthLimit(150))
+	defer d.Close(t)
+	if fatal := expectFatal(t, d, &datadriven.TestData{Pos: "test:1", Cmd: "run", Input: "type a"}); fatal != "" {
+		t.Fatalf("unexpected error: %s", fatal)
+	}
+	fatal := expectFatal(t, d, &datadriven.TestData{Pos: "test:2", Cmd: "run", Input: "type ab"})
+	const expected = "test:2: the model grew by 248 bytes (from 140 to 388), over the limit of 150 bytes"
+	if fatal != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, fatal)
+	}
+}
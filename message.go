@@ -0,0 +1,48 @@
+package catwalk
+
+import (
+	"sort"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// MessageBuilder constructs an application-defined message from the
+// arguments of the msg input command. See WithMessageBuilder().
+type MessageBuilder func(args ...string) (tea.Msg, error)
+
+// WithMessageBuilder defines a message which can be sent to the
+// model with the msg input command, e.g. to simulate the result of
+// an asynchronous fetch or a backend event without writing an
+// Updater. The arguments of the command after the name are passed
+// to the builder.
+func WithMessageBuilder(name string, fn MessageBuilder) Option {
+	return func(d *driver) {
+		if d.msgBuilders == nil {
+			d.msgBuilders = make(map[string]MessageBuilder)
+		}
+		d.msgBuilders[name] = fn
+	}
+}
+
+// buildMsg implements the msg input command: the message is
+// constructed with the builder registered under the given name, and
+// queued for delivery to the model.
+func (d *driver) buildMsg(t TB, args ...string) {
+	if len(args) == 0 {
+		t.Fatalf("%s: syntax: msg <name> [<args>...]", d.pos)
+	}
+	fn, ok := d.msgBuilders[args[0]]
+	if !ok {
+		names := make([]string, 0, len(d.msgBuilders))
+		for name := range d.msgBuilders {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		t.Fatalf("%s: unknown message %q%s", d.pos, args[0], didYouMean(args[0], names))
+	}
+	msg, err := fn(args[1:]...)
+	if err != nil {
+		t.Fatalf("%s: msg %s: %v", d.pos, args[0], err)
+	}
+	d.addMsg(msg)
+}
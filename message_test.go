@@ -0,0 +1,73 @@
+package catwalk
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/cockroachdb/datadriven"
+)
+
+// fetchModel displays the items delivered by a fetchedMsg.
+type fetchModel struct{ items []string }
+
+type fetchedMsg struct{ items []string }
+
+func (m fetchModel) Init() tea.Cmd { return nil }
+
+func (m fetchModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if msg, ok := msg.(fetchedMsg); ok {
+		m.items = msg.items
+	}
+	return m, nil
+}
+
+func (m fetchModel) View() string { return "items: " + strings.Join(m.items, ", ") }
+
+var fetchedBuilder = WithMessageBuilder("fetched", func(args ...string) (tea.Msg, error) {
+	if len(args) == 0 {
+		return nil, errors.New("no items")
+	}
+	return fetchedMsg{items: args}, nil
+})
+
+func TestMessageBuilder(t *testing.T) {
+	const test = `
+run trace=on
+msg fetched apple banana
+----
+-- trace: calling Init
+-- trace: before "msg fetched apple banana"
+-- trace: after "msg"
+-- view:
+items: 🛇
+-- trace: before finish
+-- view:
+items: 🛇
+-- trace: processing 1 messages
+-- trace: msg catwalk.fetchedMsg{items:[]string{"apple", "banana"}}
+-- trace: at end
+-- view:
+items: apple, banana🛇
+`
+	RunModelFromString(t, test, fetchModel{}, fetchedBuilder)
+
+	for _, tc := range []struct {
+		input    string
+		expected string
+	}{
+		{"msg", "test:1: syntax: msg <name> [<args>...]"},
+		{"msg fetch x", "test:1: unknown message \"fetch\" (did you mean \"fetched\"?)"},
+		{"msg fetched", "test:1: msg fetched: no items"},
+	} {
+		t.Run(tc.input, func(t *testing.T) {
+			d := NewDriver(fetchModel{}, fetchedBuilder)
+			defer d.Close(t)
+			fatal := expectFatal(t, d, &datadriven.TestData{Pos: "test:1", Cmd: "run", Input: tc.input})
+			if fatal != tc.expected {
+				t.Errorf("expected:\n%s\ngot:\n%s", tc.expected, fatal)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package catwalk
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// mouseEventTypes maps the names accepted by ParseMouse to mouse
+// event types.
+var mouseEventTypes = map[string]tea.MouseEventType{
+	"left":       tea.MouseLeft,
+	"right":      tea.MouseRight,
+	"middle":     tea.MouseMiddle,
+	"release":    tea.MouseRelease,
+	"wheel_up":   tea.MouseWheelUp,
+	"wheel_down": tea.MouseWheelDown,
+	"motion":     tea.MouseMotion,
+}
+
+// ParseMouse returns the mouse message described by the given
+// string, of the form [ctrl+][alt+]<event>@<x>,<y>. The event is
+// one of left, right, middle, release, wheel_up, wheel_down or
+// motion. For example: "left@10,2" or "ctrl+wheel_down@0,0".
+//
+// The event names as printed by bubbletea, e.g. "wheel up", and with
+// dashes, e.g. "wheel-up", are also accepted.
+func ParseMouse(s string) (tea.MouseMsg, error) {
+	var ev tea.MouseEvent
+	desc := s
+	for {
+		if strings.HasPrefix(desc, "ctrl+") {
+			ev.Ctrl = true
+			desc = strings.TrimPrefix(desc, "ctrl+")
+		} else if strings.HasPrefix(desc, "alt+") {
+			ev.Alt = true
+			desc = strings.TrimPrefix(desc, "alt+")
+		} else {
+			break
+		}
+	}
+	at := strings.LastIndexByte(desc, '@')
+	if at < 0 {
+		return tea.MouseMsg{}, fmt.Errorf("invalid mouse event %q, expected [ctrl+][alt+]<event>@<x>,<y>", s)
+	}
+	evName := strings.NewReplacer(" ", "_", "-", "_").Replace(desc[:at])
+	typ, ok := mouseEventTypes[evName]
+	if !ok {
+		names := make([]string, 0, len(mouseEventTypes))
+		for name := range mouseEventTypes {
+			names = append(names, name)
+		}
+		return tea.MouseMsg{}, fmt.Errorf("unknown mouse event: %s%s", evName, didYouMean(evName, names))
+	}
+	ev.Type = typ
+	coords := strings.Split(desc[at+1:], ",")
+	if len(coords) != 2 {
+		return tea.MouseMsg{}, fmt.Errorf("invalid mouse coordinates in %q, expected <x>,<y>", s)
+	}
+	var err error
+	if ev.X, err = strconv.Atoi(strings.TrimSpace(coords[0])); err != nil {
+		return tea.MouseMsg{}, fmt.Errorf("invalid mouse coordinates in %q: %v", s, err)
+	}
+	if ev.Y, err = strconv.Atoi(strings.TrimSpace(coords[1])); err != nil {
+		return tea.MouseMsg{}, fmt.Errorf("invalid mouse coordinates in %q: %v", s, err)
+	}
+	return tea.MouseMsg(ev), nil
+}
+
+// mouseInput implements the mouse input command, which accepts
+// either <event> <x> <y> or the syntax of ParseMouse.
+func (d *driver) mouseInput(t TB, args ...string) {
+	var desc string
+	switch len(args) {
+	case 1:
+		desc = args[0]
+	case 3:
+		desc = fmt.Sprintf("%s@%s,%s", args[0], args[1], args[2])
+	default:
+		t.Fatalf("%s: syntax: mouse <event> <x> <y>", d.pos)
+	}
+	msg, err := ParseMouse(desc)
+	if err != nil {
+		t.Fatalf("%s: %v", d.pos, err)
+	}
+	d.addMsg(msg)
+}
+
+// mouseString returns the canonical description of the mouse event,
+// as accepted by ParseMouse.
+func mouseString(ev tea.MouseEvent) string {
+	return fmt.Sprintf("%s@%d,%d", strings.Replace(ev.String(), " ", "_", -1), ev.X, ev.Y)
+}
@@ -0,0 +1,78 @@
+package catwalk
+
+import (
+	"fmt"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestParseMouse(t *testing.T) {
+	testData := []struct {
+		input    string
+		expected string
+	}{
+		{"left@10,2", `tea.MouseMsg{X:10, Y:2, Type:1, Alt:false, Ctrl:false}`},
+		{"ctrl+alt+wheel_down@0,5", `tea.MouseMsg{X:0, Y:5, Type:6, Alt:true, Ctrl:true}`},
+		{"wheel up@1,1", `tea.MouseMsg{X:1, Y:1, Type:5, Alt:false, Ctrl:false}`},
+		{"wheel-down@1,1", `tea.MouseMsg{X:1, Y:1, Type:6, Alt:false, Ctrl:false}`},
+		{"motion@3, 4", `tea.MouseMsg{X:3, Y:4, Type:7, Alt:false, Ctrl:false}`},
+		{"left", `error: invalid mouse event "left", expected [ctrl+][alt+]<event>@<x>,<y>`},
+		{"lfet@1,1", `error: unknown mouse event: lfet (did you mean "left"?)`},
+		{"left@1", `error: invalid mouse coordinates in "left@1", expected <x>,<y>`},
+		{"left@a,1", `error: invalid mouse coordinates in "left@a,1": strconv.Atoi: parsing "a": invalid syntax`},
+	}
+	for _, tc := range testData {
+		var actual string
+		if msg, err := ParseMouse(tc.input); err != nil {
+			actual = fmt.Sprintf("error: %v", err)
+		} else {
+			actual = fmt.Sprintf("%#v", msg)
+		}
+		if actual != tc.expected {
+			t.Errorf("%s: expected:\n%s\ngot:\n%s", tc.input, tc.expected, actual)
+		}
+	}
+	// The string representation of mouse events by bubbletea
+	// can be parsed back.
+	ev := tea.MouseEvent{X: 1, Y: 2, Type: tea.MouseWheelUp, Ctrl: true}
+	if msg, err := ParseMouse(fmt.Sprintf("%s@%d,%d", ev, ev.X, ev.Y)); err != nil || tea.MouseEvent(msg) != ev {
+		t.Errorf("round trip failed: %v, %v", msg, err)
+	}
+}
+
+func TestMouseInput(t *testing.T) {
+	const test = `
+run trace=on
+mouse left 10 5
+mouse ctrl+wheel-up 3 7
+mouse motion@4,4
+----
+-- trace: calling Init
+-- trace: before "mouse left 10 5"
+-- trace: after "mouse"
+-- view:
+VALUE: 0🛇
+-- trace: before "mouse ctrl+wheel-up 3 7"
+-- trace: processing 1 messages
+-- trace: msg tea.MouseMsg: left@10,5
+-- trace: after "mouse"
+-- view:
+VALUE: 1🛇
+-- trace: before "mouse motion@4,4"
+-- trace: processing 1 messages
+-- trace: msg tea.MouseMsg: ctrl+wheel_up@3,7
+-- trace: after "mouse"
+-- view:
+VALUE: 2🛇
+-- trace: before finish
+-- view:
+VALUE: 2🛇
+-- trace: processing 1 messages
+-- trace: msg tea.MouseMsg: motion@4,4
+-- trace: at end
+-- view:
+VALUE: 3🛇
+`
+	RunModelFromString(t, test, intModel(0))
+}
@@ -0,0 +1,175 @@
+package catwalk
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Normalizer canonicalizes the lines of a region of the view before
+// it is compared to the expected output. See WithNormalizer() and
+// the normalize input command.
+type Normalizer func(lines []string) []string
+
+// WithNormalizer defines a normalizer which can be applied to a
+// region of the view with the normalize input command, in addition
+// to the predefined "sort".
+func WithNormalizer(name string, fn Normalizer) Option {
+	return func(d *driver) {
+		if d.normalizers == nil {
+			d.normalizers = make(map[string]Normalizer)
+		}
+		d.normalizers[name] = fn
+	}
+}
+
+// WithOutputNormalizer tells the test driver to replace the matches
+// of the given regular expression by the replacement in the output
+// of all the observers, before it is compared to the expected
+// output. The replacement can refer to the groups of the regular
+// expression, as in regexp.ReplaceAllString. This makes it possible
+// to scrub e.g. absolute paths or wall-clock times from the view
+// without changing the model. When multiple normalizers are
+// specified, they are applied in the order of the options. This can
+// also be changed with `set normalize`.
+//
+// Using an invalid regular expression is a programming error and
+// panics.
+func WithOutputNormalizer(pattern, replacement string) Option {
+	return func(d *driver) {
+		d.outputNormalizers = append(d.outputNormalizers,
+			outputNormalizer{re: regexp.MustCompile(pattern), repl: replacement})
+	}
+}
+
+// outputNormalizer is a rewrite of the observer output.
+// See WithOutputNormalizer().
+type outputNormalizer struct {
+	re   *regexp.Regexp
+	repl string
+}
+
+// outputNormalizerSep separates the regular expression from the
+// replacement in the value of the normalize setting.
+const outputNormalizerSep = "=>"
+
+func (n outputNormalizer) String() string {
+	return n.re.String() + outputNormalizerSep + n.repl
+}
+
+// parseOutputNormalizers parses the value of the normalize setting:
+// a comma-separated list of <regexp>=><replacement>.
+func parseOutputNormalizers(val string) ([]outputNormalizer, error) {
+	if val == "" {
+		return nil, nil
+	}
+	var res []outputNormalizer
+	for _, item := range strings.Split(val, ",") {
+		parts := strings.SplitN(item, outputNormalizerSep, 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("expected <regexp>%s<replacement>, got %q", outputNormalizerSep, item)
+		}
+		re, err := regexp.Compile(parts[0])
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, outputNormalizer{re: re, repl: parts[1]})
+	}
+	return res, nil
+}
+
+// normalizeOutput applies the output normalizers to the output of
+// an observer.
+func (d *driver) normalizeOutput(out string) string {
+	for _, n := range d.outputNormalizers {
+		out = n.re.ReplaceAllString(out, n.repl)
+	}
+	return out
+}
+
+// builtinNormalizers are the normalizers available without
+// WithNormalizer.
+var builtinNormalizers = map[string]Normalizer{
+	"sort": func(lines []string) []string {
+		sort.Strings(lines)
+		return lines
+	},
+}
+
+// normalizedRegion is a range of lines of the view declared with the
+// normalize input command.
+type normalizedRegion struct {
+	lineRange
+	name string
+	fn   Normalizer
+}
+
+// normalizeRegion implements the normalize input command: the given
+// range of lines of the view is canonicalized with the given
+// normalizer in the rest of the test, before the view is observed.
+// normalize off removes all the normalizations.
+func (d *driver) normalizeRegion(t TB, trace bool, args ...string) {
+	if len(args) == 1 && args[0] == "off" {
+		d.trace(trace, "removing %d normalizations", len(d.normalized))
+		d.normalized = nil
+		d.obsCache = nil
+		return
+	}
+	if len(args) != 2 {
+		t.Fatalf("%s: syntax: normalize <normalizer> <start>:<end>", d.pos)
+	}
+	fn, ok := d.normalizers[args[0]]
+	if !ok {
+		fn, ok = builtinNormalizers[args[0]]
+	}
+	if !ok {
+		var names []string
+		for name := range builtinNormalizers {
+			names = append(names, name)
+		}
+		for name := range d.normalizers {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		t.Fatalf("%s: unknown normalizer %q%s", d.pos, args[0], didYouMean(args[0], names))
+	}
+	r, err := parseRegion(args[1])
+	if err != nil {
+		t.Fatalf("%s: normalize: %v", d.pos, err)
+	}
+	d.trace(trace, "normalizing lines %s with %s", r, args[0])
+	d.normalized = append(d.normalized, normalizedRegion{lineRange: r, name: args[0], fn: fn})
+	d.obsCache = nil
+}
+
+// normalizeView applies the normalizations to the given view. The
+// regions are clipped to the lines of the view.
+func (d *driver) normalizeView(view string) string {
+	lines := strings.Split(view, "\n")
+	for _, r := range d.normalized {
+		start, end := r.start, r.end
+		if end > len(lines) {
+			end = len(lines)
+		}
+		if start >= end {
+			continue
+		}
+		region := r.fn(append([]string(nil), lines[start:end]...))
+		lines = append(append(lines[:start:start], region...), lines[end:]...)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// normalizedModel wraps the model to apply the normalizations to its
+// view when it is observed.
+type normalizedModel struct {
+	tea.Model
+	d *driver
+}
+
+func (m normalizedModel) View() string {
+	return m.d.normalizeView(m.Model.View())
+}
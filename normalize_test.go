@@ -0,0 +1,100 @@
+package catwalk
+
+import (
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/cockroachdb/datadriven"
+)
+
+// stackModel displays the keys typed, the most recent first.
+type stackModel []string
+
+func (m stackModel) Init() tea.Cmd { return nil }
+
+func (m stackModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if k, ok := msg.(tea.KeyMsg); ok {
+		m = append(stackModel{k.String()}, m...)
+	}
+	return m, nil
+}
+
+func (m stackModel) View() string {
+	return "ITEMS\n" + strings.Join(m, "\n") + "\nEND"
+}
+
+func TestNormalize(t *testing.T) {
+	RunModel(t, "testdata/normalize", stackModel{}, WithNormalizer("upper", func(lines []string) []string {
+		for i := range lines {
+			lines[i] = strings.ToUpper(lines[i])
+		}
+		return lines
+	}))
+}
+
+func TestNormalizeErrors(t *testing.T) {
+	testData := []struct {
+		input, expected string
+	}{
+		{"normalize sort", `test:1: syntax: normalize <normalizer> <start>:<end>`},
+		{"normalize sotr 1:2", `test:1: unknown normalizer "sotr" (did you mean "sort"?)`},
+		{"normalize sort 2:1", `test:1: normalize: invalid region "2:1", expected <start>:<end>`},
+	}
+	for _, tc := range testData {
+		d := NewDriver(stackModel{})
+		fatal := expectFatal(t, d, &datadriven.TestData{Pos: "test:1", Cmd: "run", Input: tc.input})
+		d.Close(t)
+		if fatal != tc.expected {
+			t.Errorf("%q: expected:\n%s\ngot:\n%s", tc.input, tc.expected, fatal)
+		}
+	}
+}
+
+// savedModel displays a path and a time which vary across runs.
+type savedModel struct{}
+
+func (savedModel) Init() tea.Cmd                         { return nil }
+func (m savedModel) Update(tea.Msg) (tea.Model, tea.Cmd) { return m, nil }
+func (savedModel) View() string                          { return "saved /home/alice/notes.txt at 12:34:56" }
+
+func TestOutputNormalizer(t *testing.T) {
+	const test = `
+run observe=(view,gostruct)
+----
+-- view:
+saved ~/notes.txt at 12:34:56🛇
+-- gostruct:
+catwalk.savedModel{}
+
+set normalize=(/home/\w+=>~,\d\d:\d\d:\d\d=>HH:MM:SS)
+----
+normalize: /home/\w+=>~,\d\d:\d\d:\d\d=>HH:MM:SS
+
+run
+----
+-- view:
+saved ~/notes.txt at HH:MM:SS🛇
+
+# The reset restores the normalizers configured with
+# WithOutputNormalizer.
+reset normalize
+----
+ok
+
+run
+----
+-- view:
+saved ~/notes.txt at 12:34:56🛇
+
+set normalize=
+----
+normalize: 
+
+run
+----
+-- view:
+saved /home/alice/notes.txt at 12:34:56🛇
+`
+	RunModelFromString(t, test, savedModel{}, WithOutputNormalizer(`/home/(\w+)`, "~"))
+}
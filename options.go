@@ -1,6 +1,14 @@
 package catwalk
 
-import tea "github.com/charmbracelet/bubbletea"
+import (
+	"fmt"
+	"math/rand"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
 
 // WithAutoInitDisabled tells the test driver to not automatically
 // initialize the model (via the Init method) upon first use.
@@ -20,15 +28,367 @@ func WithWindowSize(width, height int) Option {
 	}
 }
 
+// WithMaxIterations sets the maximum number of messages and
+// commands processed during a single run directive. When the limit
+// is reached, the test fails with the recent history of messages
+// and commands, which helps diagnose infinite feedback loops. The
+// value 0 disables the limit. This can also be changed with
+// `set max_iterations`.
+func WithMaxIterations(n int) Option {
+	return func(d *driver) {
+		d.maxIterations = n
+	}
+}
+
+// WithAltScreen tells the test driver that the model starts
+// in the alternate screen buffer, like tea.WithAltScreen does
+// for a tea.Program. This is reflected in the "screen" observer.
+func WithAltScreen() Option {
+	return func(d *driver) {
+		d.altScreen = true
+	}
+}
+
+// WithAltScreenResize tells the test driver to deliver a
+// tea.WindowSizeMsg with the last known window size every time the
+// model enters or exits the alternate screen buffer, like real
+// terminals do. This avoids tests passing for models which only
+// work if they receive this message. This can also be changed with
+// `set alt_screen_resize`.
+func WithAltScreenResize() Option {
+	return func(d *driver) {
+		d.altScreenResize = true
+	}
+}
+
+// setAltScreen records a transition to or from the alternate screen
+// buffer.
+func (d *driver) setAltScreen(alt bool) {
+	changed := d.altScreen != alt
+	d.altScreen = alt
+	if changed && d.altScreenResize && d.sizeKnown {
+		d.setOrigin("alt screen transition")
+		d.addMsg(tea.WindowSizeMsg{Width: d.width, Height: d.height})
+	}
+}
+
+// WithErrorMsg tells the test driver how to convert the argument of
+// the "senderr" input command to a tea.Msg. This is useful when the
+// model expects errors wrapped in an application-specific message
+// type.
+//
+// By default, the error is delivered to the model as-is.
+func WithErrorMsg(fn func(error) tea.Msg) Option {
+	return func(d *driver) {
+		d.errMsg = fn
+	}
+}
+
+// WithAdaptiveCmdTimeout tells the test driver to keep waiting for
+// commands which do not complete within cmd_timeout, up to the given
+// hard cap. The trace reports how long such slow commands actually
+// took. This
+// reduces flakiness without making all the tests slower. This can
+// also be changed with `set cmd_timeout_max`.
+func WithAdaptiveCmdTimeout(max time.Duration) Option {
+	return func(d *driver) {
+		d.cmdTimeoutMax = max
+	}
+}
+
+// WithSyncCmds tells the test driver to run commands synchronously
+// on the test goroutine, waiting for each of them to complete
+// without a timeout. This removes the flakiness caused by
+// computational commands which occasionally take longer than
+// cmd_timeout, e.g. on slow CI machines.
+//
+// Commands which never complete, e.g. those waiting for external
+// events, block the test in this mode; use WithCmdStub to simulate
+// them. This can also be changed with `set sync_cmds`.
+func WithSyncCmds() Option {
+	return func(d *driver) {
+		d.syncCmds = true
+	}
+}
+
+// WithKeyCoalescing tells the test driver to deliver the text typed
+// by the type, enter and fill input commands as a single tea.KeyMsg
+// with multiple runes, like terminals which coalesce rapid keystrokes
+// do, instead of one message per character. This makes it possible
+// to check that the model handles both delivery patterns. This can
+// also be changed with `set coalesce_keys`.
+func WithKeyCoalescing() Option {
+	return func(d *driver) {
+		d.coalesceKeys = true
+	}
+}
+
+// WithCmdStub tells the test driver to simulate the commands
+// implemented by the function with the given name, instead of
+// running them. This makes it possible to exercise loading states
+// and timeouts in the model without real delays.
+//
+// The name is matched against the fully qualified name of the
+// function (as reported by runtime.FuncForPC), or any suffix of it
+// starting after a period or slash. For example, the command
+// returned by fetchData in package example.com/app can be stubbed
+// using "example.com/app.fetchData", "app.fetchData" or "fetchData".
+// Anonymous functions are named like "(*model).Update.func1".
+func WithCmdStub(name string, stub CmdStub) Option {
+	return func(d *driver) {
+		if d.cmdStubs == nil {
+			d.cmdStubs = make(map[string]CmdStub)
+		}
+		d.cmdStubs[name] = stub
+	}
+}
+
+// WithConcurrentCmds tells the test driver to run all the queued
+// commands concurrently, as bubbletea does for batched commands,
+// instead of one after another.
+//
+// The resulting messages are delivered to the model in an order
+// determined by a random source initialized with the given seed.
+// This helps catch assumptions about the ordering of command results
+// that would not hold in production, while keeping tests
+// reproducible.
+func WithConcurrentCmds(seed int64) Option {
+	return func(d *driver) {
+		d.concurrentCmds = true
+		d.rng = rand.New(rand.NewSource(seed))
+	}
+}
+
+// WithVirtualClock tells the test driver to defer the commands
+// produced by tea.Tick and tea.Every until the virtual time, as
+// advanced by the advance input command, reaches their deadline,
+// instead of waiting for them in real time. This makes models using
+// spinners, timers and other periodic updates deterministic and fast
+// to test. This can also be changed with `set virtual_clock`.
+//
+// The deadline of a tea.Every command is aligned on the virtual
+// time, starting from 0 at the beginning of the test. The time
+// passed to the tick callbacks is the virtual time, counted from
+// midnight UTC on January 1st, 2000.
+//
+// The virtual clock relies on the implementation of tea.Tick and
+// tea.Every, which is verified when the package is initialized. With
+// a version of bubbletea where the verification fails, this option
+// panics.
+func WithVirtualClock() Option {
+	return func(d *driver) {
+		if err := errTickLayout(); err != nil {
+			panic(fmt.Sprintf("catwalk: the virtual clock is not supported: %v", err))
+		}
+		d.virtualClock = true
+	}
+}
+
+// WithProgramConformance tells the test driver to expand the
+// commands nested in a tea.Sequence like a bubbletea program does,
+// instead of running each command of the sequence, including the
+// batches and sequences it returns, to completion before the next
+// one. In a program, the nested batches and sequences run
+// asynchronously with respect to the enclosing sequence; the test
+// driver runs them after the enclosing sequence completes, which is
+// one of the possible orders. This can also be changed with
+// `set program_conformance`.
+func WithProgramConformance() Option {
+	return func(d *driver) {
+		d.programConformance = true
+	}
+}
+
 // WithObserver tells the test driver to support an additional
 // observer with the given function.
 //
 // For example, after WithObserver("hello", myObserver)
 // The function myObserver() will be called every time
 // a test specifies `observe=hello` in the run directive.
+//
+// Registering the same observer name twice with WithObserver is a
+// programming error and panics, reporting both registration sites.
+// Use WithObserverOverride to replace an observer deliberately.
 func WithObserver(what string, obs Observer) Option {
+	site := callerSite()
+	return func(d *driver) {
+		d.addObserver(what, obs, site)
+	}
+}
+
+// builtinObserverSite is the registration site recorded for the
+// built-in observers.
+const builtinObserverSite = "built-in"
+
+// recordBuiltinObservers records the built-in observers in
+// observerSites, so that they can only be replaced with
+// WithObserverOverride.
+func (d *driver) recordBuiltinObservers() {
+	if d.observerSites == nil {
+		d.observerSites = make(map[string]string)
+	}
+	for name := range d.observers {
+		d.observerSites[name] = builtinObserverSite
+	}
+	for _, name := range builtinObservers {
+		d.observerSites[name] = builtinObserverSite
+	}
+}
+
+// addObserver registers obs under the given name, and panics if
+// an observer was already registered under that name.
+func (d *driver) addObserver(what string, obs Observer, site string) {
+	if prev, ok := d.observerSites[what]; ok {
+		if prev == builtinObserverSite {
+			panic(fmt.Sprintf("catwalk: observer %q registered at %s is a built-in observer; use WithObserverOverride() to replace it",
+				what, site))
+		}
+		panic(fmt.Sprintf("catwalk: observer %q registered twice, at %s and %s; use WithObserverOverride() to replace it",
+			what, prev, site))
+	}
+	d.registerObserver(what, obs, site)
+}
+
+// WithObserverOverride is like WithObserver, but replaces any
+// observer registered under the same name by an earlier option,
+// including the built-in observers.
+func WithObserverOverride(what string, obs Observer) Option {
+	site := callerSite()
+	return func(d *driver) {
+		d.registerObserver(what, obs, site)
+	}
+}
+
+// registerObserver registers obs under the given name, recording
+// the registration site.
+func (d *driver) registerObserver(what string, obs Observer, site string) {
+	if d.observerSites == nil {
+		d.observerSites = make(map[string]string)
+	}
+	d.observerSites[what] = site
+	d.observers[what] = obs
+}
+
+// callerSite returns the source position of the caller of the
+// function calling callerSite, for use in error messages.
+func callerSite() string {
+	_, file, line, ok := runtime.Caller(2)
+	if !ok {
+		return "<unknown>"
+	}
+	return fmt.Sprintf("%s:%d", filepath.Base(file), line)
+}
+
+// WithObserverCaching tells the test driver to reuse the output of
+// the given observers, including built-in observers like "view", as
+// long as the model does not change. This avoids re-rendering when
+// an unchanged model is observed repeatedly, e.g. with tracing
+// enabled, which matters for models whose View() is expensive.
+//
+// The observers must only depend on the state of the model.
+func WithObserverCaching(what ...string) Option {
 	return func(d *driver) {
-		d.observers[what] = obs
+		if d.cachedObservers == nil {
+			d.cachedObservers = make(map[string]bool)
+		}
+		for _, name := range what {
+			d.cachedObservers[name] = true
+		}
+	}
+}
+
+// WithObserverFilter attaches a transformation to the output of the
+// given observer, for example to strip timestamps from the view
+// while keeping the output of the other observers verbatim. The
+// filter applies to the observer output without its header. When
+// multiple filters are attached to the same observer, they are
+// applied in the order of the options.
+//
+// The filters of an observer also apply when it is used on a
+// sub-model with observe=<observer>@<name>.
+func WithObserverFilter(what string, filter func(string) string) Option {
+	return func(d *driver) {
+		if d.observerFilters == nil {
+			d.observerFilters = make(map[string][]func(string) string)
+		}
+		d.observerFilters[what] = append(d.observerFilters[what], filter)
+	}
+}
+
+// WithObserveHeader changes the header printed before each
+// observation in the test output. The first occurrence of "%s" in
+// the format is replaced by the name of the observer; the format is
+// otherwise printed as-is, so it can contain other % characters. The
+// default is "-- %s:". If the format is empty, no header is printed.
+func WithObserveHeader(format string) Option {
+	return func(d *driver) {
+		d.observeHeader = format
+	}
+}
+
+// WithObserveSeparator tells the test driver to print the
+// given separator line between consecutive observations.
+func WithObserveSeparator(sep string) Option {
+	return func(d *driver) {
+		d.observeSeparator = sep
+	}
+}
+
+// WithViewMarkers changes the markers used by the view observer to
+// make the structure of the view visible: the newline marker is
+// printed at the end of every line, and the EOF marker at the end
+// of the view if it does not end with a newline.
+// The defaults are "␤" and "🛇".
+func WithViewMarkers(newline, eof string) Option {
+	return func(d *driver) {
+		d.newlineMarker = newline
+		d.eofMarker = eof
+	}
+}
+
+// WithMarkerLabels overrides the labels used to report special
+// messages in the test output. The map is keyed by the default
+// label, for example "TEA PRINT" or "TEA QUIT", and the value is
+// the label to use instead. This makes it possible to keep
+// expected output stable across catwalk upgrades, or to match
+// in-house tooling.
+//
+// It is possible to use multiple WithMarkerLabels options; later
+// options override earlier ones for the same label. Using an
+// unknown default label is a programming error and panics.
+func WithMarkerLabels(labels map[string]string) Option {
+	return func(d *driver) {
+		if d.markerLabels == nil {
+			d.markerLabels = make(map[string]string, len(labels))
+		}
+		for def, l := range labels {
+			if !markerLabels[def] {
+				panic(fmt.Sprintf("catwalk: unknown marker label %q", def))
+			}
+			d.markerLabels[def] = l
+		}
+	}
+}
+
+// WithPrintsHidden tells the test driver to not report the messages
+// printed with tea.Println / tea.Printf in the test output.
+func WithPrintsHidden() Option {
+	return func(d *driver) {
+		d.showPrints = false
+	}
+}
+
+// WithListener registers a function which is notified of the events
+// in the lifecycle of the test driver: directives, calls to Init,
+// messages delivered, commands started and finished, and
+// observations. This can be used e.g. to collect metrics or to
+// integrate with tracing tools.
+//
+// It is possible to use multiple WithListener options; the
+// listeners are notified in the order they were registered.
+func WithListener(l Listener) Option {
+	return func(d *driver) {
+		d.listeners = append(d.listeners, l)
 	}
 }
 
@@ -37,10 +397,88 @@ func WithObserver(what string, obs Observer) Option {
 // chain them automatically (using ChainUpdaters).
 func WithUpdater(upd Updater) Option {
 	return func(d *driver) {
-		d.upd = ChainUpdaters(d.upd, upd)
+		d.updaters = append(d.updaters, namedUpdater{upd: upd})
+	}
+}
+
+// WithNamedUpdater adds the specified model updater to the test
+// under the given name. If an updater was already registered with
+// the same name by an earlier option, it is replaced in-place,
+// i.e. the new updater keeps the position of the one it replaces
+// in the chain.
+//
+// This makes it possible to share a common set of options across
+// tests and specialize it per test.
+func WithNamedUpdater(name string, upd Updater) Option {
+	return func(d *driver) {
+		for i := range d.updaters {
+			if d.updaters[i].name == name {
+				d.updaters[i].upd = upd
+				return
+			}
+		}
+		d.updaters = append(d.updaters, namedUpdater{name: name, upd: upd})
+	}
+}
+
+// WithoutUpdater removes the updater registered with
+// WithNamedUpdater under the given name by an earlier option.
+// It has no effect if there is no updater with that name.
+func WithoutUpdater(name string) Option {
+	return func(d *driver) {
+		for i := range d.updaters {
+			if d.updaters[i].name == name {
+				d.updaters = append(d.updaters[:i:i], d.updaters[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// WithUpdaterCommands declares the names of the input commands
+// supported by the updaters. The test driver uses them to suggest
+// close matches when an unknown input command is used.
+//
+// Declaring the same command twice, or a command which is already
+// built into the test driver (and thus never reaches the updaters),
+// is a programming error and panics, reporting the registration
+// sites.
+func WithUpdaterCommands(cmds ...string) Option {
+	site := callerSite()
+	return func(d *driver) {
+		d.addUpdaterCommands(site, cmds...)
 	}
 }
 
+// addUpdaterCommands declares the input commands supported by the
+// updaters, and panics if a command is declared twice or is built
+// into the test driver.
+func (d *driver) addUpdaterCommands(site string, cmds ...string) {
+	if d.updaterCmdSites == nil {
+		d.updaterCmdSites = make(map[string]string)
+	}
+	for _, cmd := range cmds {
+		for _, b := range builtinCommands {
+			if cmd == b {
+				panic(fmt.Sprintf("catwalk: command %q declared at %s is a built-in command and cannot be handled by updaters",
+					cmd, site))
+			}
+		}
+		if prev, ok := d.updaterCmdSites[cmd]; ok {
+			panic(fmt.Sprintf("catwalk: command %q declared twice, at %s and %s", cmd, prev, site))
+		}
+		d.updaterCmdSites[cmd] = site
+	}
+	d.updaterCmds = append(d.updaterCmds, cmds...)
+}
+
+// namedUpdater is an updater registered with WithUpdater or
+// WithNamedUpdater. The name is empty for the former.
+type namedUpdater struct {
+	name string
+	upd  Updater
+}
+
 // ChainUpdaters chains the specified updaters into a resulting updater
 // that supports all the commands in the chain. Test input commands
 // are passed to each updater in turn until the first updater
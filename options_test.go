@@ -1,9 +1,16 @@
 package catwalk
 
 import (
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
 	"testing"
+	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/cockroachdb/datadriven"
 )
 
 // TestDisableAutoInit checks the WithAutoInitDisabled configuration option.
@@ -16,6 +23,103 @@ func TestInitWindowSize(t *testing.T) {
 	RunModel(t, "testdata/window_size", emptyModel{}, WithWindowSize(80, 25))
 }
 
+func TestAltScreen(t *testing.T) {
+	const test = `
+run observe=screen
+----
+TEA PRINT: {MODEL INIT}
+-- screen:
+alt screen
+
+run observe=screen
+type A
+----
+TEA EXIT ALT
+-- screen:
+inline
+`
+	RunModelFromString(t, test, emptyModel{}, WithAltScreen())
+}
+
+func TestAltScreenResize(t *testing.T) {
+	const test = `
+run observe=(cmds,view)
+type a
+----
+TEA PRINT: {MODEL INIT}
+TEA WINDOW SIZE: {80 25}
+TEA PRINT: {MODEL UPDATE}
+TEA ENTER ALT
+TEA WINDOW SIZE: {80 25}
+-- cmds:
+command queue sz: 1
+-- view:
+MODEL VIEW🛇
+
+# Entering the alternate screen again is not a transition.
+run observe=cmds
+resize 100 30
+type aA
+----
+TEA WINDOW SIZE: {100 30}
+TEA PRINT: {MODEL UPDATE}
+TEA PRINT: {MODEL UPDATE}
+TEA ENTER ALT
+TEA EXIT ALT
+TEA WINDOW SIZE: {100 30}
+-- cmds:
+command queue sz: 1
+`
+	RunModelFromString(t, test, emptyModel{}, WithWindowSize(80, 25), WithAltScreenResize())
+}
+
+func TestObserverFilter(t *testing.T) {
+	const test = `
+run observe=(view,gostruct)
+type abc
+----
+-- view:
+VALUE: #🛇
+-- gostruct:
+catwalk.intModel(3)
+`
+	digits := regexp.MustCompile(`[0-9]+`)
+	RunModelFromString(t, test, intModel(0),
+		WithObserverFilter("view", func(s string) string { return digits.ReplaceAllString(s, "N") }),
+		WithObserverFilter("view", func(s string) string { return strings.Replace(s, "N", "#", -1) }))
+}
+
+// TestObserverCaching checks that cached observers are only called
+// again after the model changes.
+func TestObserverCaching(t *testing.T) {
+	const test = `
+run trace=on observe=renders
+type a
+----
+-- trace: calling Init
+-- trace: before "type a"
+-- trace: after "type"
+-- renders:
+render 1: VALUE: 0
+-- trace: before finish
+-- renders:
+render 1: VALUE: 0
+-- trace: processing 1 messages
+-- trace: msg tea.KeyMsg: a
+-- trace: at end
+-- renders:
+render 2: VALUE: 1
+`
+	renders := 0
+	RunModelFromString(t, test, intModel(0),
+		WithObserver("renders", func(out io.Writer, m tea.Model) error {
+			renders++
+			_, err := fmt.Fprintf(out, "render %d: %s\n", renders, m.View())
+			return err
+		}),
+		WithObserverCaching("renders"))
+}
+
 func TestChainUpdaters(t *testing.T) {
 	upd1 := func(_ tea.Model, cmd string, _ ...string) (bool, tea.Model, tea.Cmd, error) {
 		if cmd == "hello" {
@@ -49,6 +153,59 @@ func TestChainUpdaters(t *testing.T) {
 	}
 }
 
+func TestNamedUpdaters(t *testing.T) {
+	named := func(name string) Updater {
+		return func(m tea.Model, cmd string, _ ...string) (bool, tea.Model, tea.Cmd, error) {
+			if cmd == "who" {
+				return true, intModel(len(name)), nil, nil
+			}
+			if cmd == name {
+				return true, m, nil, nil
+			}
+			return false, nil, nil, nil
+		}
+	}
+	check := func(opts []Option, cmd string, expectedSupported bool, expectedModel tea.Model) {
+		t.Helper()
+		d := NewDriver(nil, opts...).(*driver)
+		if len(d.updaters) == 0 {
+			if expectedSupported {
+				t.Errorf("%s: no updater defined", cmd)
+			}
+			return
+		}
+		s, m, _ := d.dispatchUpdaters(t, cmd)
+		if s != expectedSupported || (s && m != expectedModel) {
+			t.Errorf("%s: expected %v/%v, got %v/%v", cmd, expectedSupported, expectedModel, s, m)
+		}
+	}
+
+	common := []Option{
+		WithNamedUpdater("a", named("a")),
+		WithNamedUpdater("bb", named("bb")),
+	}
+	check(common, "who", true, intModel(1))
+	check(common, "bb", true, nil)
+
+	// Replacing an updater keeps its position in the chain.
+	replaced := append(common[:2:2], WithNamedUpdater("a", named("ccc")))
+	check(replaced, "who", true, intModel(3))
+	check(replaced, "a", false, nil)
+	check(replaced, "ccc", true, nil)
+
+	// Removing an updater lets the next one in the chain handle
+	// the command.
+	removed := append(common[:2:2], WithoutUpdater("a"))
+	check(removed, "who", true, intModel(2))
+	check(removed, "a", false, nil)
+
+	// Removing all updaters.
+	check(append(removed, WithoutUpdater("bb")), "bb", false, nil)
+
+	// Removing a non-existent updater is a no-op.
+	check(append(common[:2:2], WithoutUpdater("x")), "who", true, intModel(1))
+}
+
 func TestChainComplexUpdaters(t *testing.T) {
 	hm := &helpModelR{}
 	upd1 := KeyMapUpdater("hello", SimpleKeyMapApplier(&hm.KeyMap))
@@ -71,3 +228,1087 @@ C-c break 🛇`
 
 	RunModelFromString(t, test, hm, WithUpdater(upd1), WithUpdater(upd2), WithUpdater(upd3))
 }
+
+// TestErrorMsg checks the senderr command and the WithErrorMsg option.
+func TestErrorMsg(t *testing.T) {
+	const test = `
+run
+senderr "boom"
+----
+-- view:
+error: boom🛇
+`
+	RunModelFromString(t, test, errModel{})
+
+	const wrapped = `
+run
+senderr "boom\nbang"
+----
+-- view:
+wrapped error: boom␤
+bang🛇
+`
+	RunModelFromString(t, wrapped, errModel{},
+		WithErrorMsg(func(err error) tea.Msg { return wrappedErr{err} }))
+}
+
+type wrappedErr struct{ err error }
+
+type errModel struct{ view string }
+
+func (errModel) Init() tea.Cmd { return nil }
+func (m errModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case error:
+		m.view = "error: " + msg.Error()
+	case wrappedErr:
+		m.view = "wrapped error: " + msg.err.Error()
+	}
+	return m, nil
+}
+func (m errModel) View() string { return m.view }
+
+// TestCmdStub checks the WithCmdStub option.
+func TestCmdStub(t *testing.T) {
+	const test = `
+run
+type f
+----
+TEA PRINT: {stubbed}
+-- view:
+MODEL VIEW🛇
+`
+	RunModelFromString(t, test, stubModel{},
+		WithCmdStub("fetchData", CmdStub{Delay: time.Millisecond, Msg: tea.Println("stubbed")()}))
+
+	const timeout = `
+run trace=on
+type f
+----
+-- trace: calling Init
+-- trace: before "type f"
+-- trace: after "type"
+-- view:
+MODEL VIEW🛇
+-- trace: before finish
+-- view:
+MODEL VIEW🛇
+-- trace: processing 1 messages
+-- trace: msg tea.KeyMsg: f
+-- trace: processing 1 cmds
+-- trace: timeout waiting for command
+-- trace: translated cmd: <nil>
+-- trace: at end
+-- view:
+MODEL VIEW🛇
+`
+	RunModelFromString(t, timeout, stubModel{},
+		WithCmdStub("catwalk.fetchData", CmdStub{Delay: time.Hour}))
+}
+
+// TestSyncCmds checks that the WithSyncCmds option waits for slow
+// commands instead of timing out.
+func TestSyncCmds(t *testing.T) {
+	const test = `
+run
+type w
+----
+TEA PRINT: {MODEL INIT}
+TEA PRINT: {DELAYED HELLO}
+-- view:
+MODEL VIEW🛇
+
+set sync_cmds=off
+----
+sync_cmds: off
+
+run
+type w
+----
+-- view:
+MODEL VIEW🛇
+`
+	RunModelFromString(t, test, emptyModel{}, WithSyncCmds())
+
+	// Stubs are not subject to the timeout either.
+	const stub = `
+run
+type f
+----
+TEA PRINT: {stubbed}
+-- view:
+MODEL VIEW🛇
+`
+	RunModelFromString(t, stub, stubModel{}, WithSyncCmds(),
+		WithCmdStub("fetchData", CmdStub{Delay: time.Hour, Msg: tea.Println("stubbed")()}))
+}
+
+// TestKeyCoalescing checks that the WithKeyCoalescing option delivers
+// typed text as a single key message.
+func TestKeyCoalescing(t *testing.T) {
+	const test = `
+run trace=on
+enter ab c
+----
+-- trace: calling Init
+-- trace: before "enter ab c"
+-- trace: after "enter"
+-- view:
+VALUE: 0🛇
+-- trace: before finish
+-- view:
+VALUE: 0🛇
+-- trace: processing 2 messages
+-- trace: msg tea.KeyMsg: ab c
+-- trace: msg tea.KeyMsg: enter
+-- trace: at end
+-- view:
+VALUE: 2🛇
+
+set coalesce_keys=off
+----
+coalesce_keys: off
+
+run
+type ab
+----
+-- view:
+VALUE: 4🛇
+`
+	RunModelFromString(t, test, intModel(0), WithKeyCoalescing())
+}
+
+// TestAdaptiveCmdTimeout checks that the WithAdaptiveCmdTimeout
+// option waits for slow commands up to the hard cap.
+func TestAdaptiveCmdTimeout(t *testing.T) {
+	const test = `
+run trace=on
+type f
+----
+-- trace: calling Init
+-- trace: before "type f"
+-- trace: after "type"
+-- view:
+MODEL VIEW🛇
+-- trace: before finish
+-- view:
+MODEL VIEW🛇
+-- trace: processing 1 messages
+-- trace: msg tea.KeyMsg: f
+-- trace: processing 1 cmds
+-- trace: cmd github.com/knz/catwalk.fetchData took 50ms, beyond cmd_timeout
+-- trace: translated cmd: tea.printLineMessage
+-- trace: processing 1 messages
+-- trace: msg tea.printLineMessage{messageBody:"stubbed"}
+TEA PRINT: {stubbed}
+-- trace: at end
+-- view:
+MODEL VIEW🛇
+`
+	RunModelFromString(t, test, stubModel{}, WithAdaptiveCmdTimeout(time.Second),
+		WithCmdStub("fetchData", CmdStub{Delay: 50 * time.Millisecond, Msg: tea.Println("stubbed")()}))
+
+	// Beyond the hard cap, the command times out.
+	const timeout = `
+run
+type f
+----
+-- view:
+MODEL VIEW🛇
+`
+	RunModelFromString(t, timeout, stubModel{}, WithAdaptiveCmdTimeout(40*time.Millisecond),
+		WithCmdStub("fetchData", CmdStub{Delay: 50 * time.Millisecond, Msg: tea.Println("stubbed")()}))
+
+	// Real commands are waited for too.
+	const real = `
+set cmd_timeout_max=10s
+----
+cmd_timeout_max: 10s
+
+run
+type w
+----
+TEA PRINT: {MODEL INIT}
+TEA PRINT: {DELAYED HELLO}
+-- view:
+MODEL VIEW🛇
+`
+	RunModelFromString(t, real, emptyModel{})
+}
+
+type stubModel struct{}
+
+func (stubModel) Init() tea.Cmd                       { return nil }
+func (stubModel) Update(tea.Msg) (tea.Model, tea.Cmd) { return stubModel{}, fetchData }
+func (stubModel) View() string                        { return "MODEL VIEW" }
+
+func fetchData() tea.Msg {
+	time.Sleep(time.Hour)
+	return nil
+}
+
+// TestCmdStats checks the cmd_stats option.
+func TestCmdStats(t *testing.T) {
+	const test = `
+set cmd_stats=on
+----
+cmd_stats: on
+
+run trace=on
+type ff
+----
+-- trace: calling Init
+-- trace: before "type ff"
+-- trace: after "type"
+-- view:
+MODEL VIEW🛇
+-- trace: before finish
+-- view:
+MODEL VIEW🛇
+-- trace: processing 2 messages
+-- trace: msg tea.KeyMsg: f
+-- trace: msg tea.KeyMsg: f
+-- trace: processing 2 cmds
+-- trace: cmd github.com/knz/catwalk.fetchData took 3ms
+-- trace: translated cmd: <nil>
+-- trace: cmd github.com/knz/catwalk.fetchData took 3ms
+-- trace: translated cmd: <nil>
+-- trace: cmd stats: 2 executed, 0 timed out, total 6ms, slowest 3ms (github.com/knz/catwalk.fetchData)
+-- trace: at end
+-- view:
+MODEL VIEW🛇
+
+reset cmd_stats
+----
+ok
+
+run trace=on
+type f
+----
+-- trace: before "type f"
+-- trace: after "type"
+-- view:
+MODEL VIEW🛇
+-- trace: before finish
+-- view:
+MODEL VIEW🛇
+-- trace: processing 1 messages
+-- trace: msg tea.KeyMsg: f
+-- trace: processing 1 cmds
+-- trace: translated cmd: <nil>
+-- trace: at end
+-- view:
+MODEL VIEW🛇
+`
+	RunModelFromString(t, test, stubModel{},
+		WithCmdStub("fetchData", CmdStub{Delay: 3 * time.Millisecond}))
+}
+
+// TestConcurrentCmds checks that the WithConcurrentCmds option
+// delivers the results of concurrent commands in a seeded order.
+func TestConcurrentCmds(t *testing.T) {
+	const test = `
+run
+----
+TEA PRINT: {b}
+TEA PRINT: {c}
+TEA PRINT: {d}
+TEA PRINT: {a}
+-- view:
+MODEL VIEW🛇
+`
+	RunModelFromString(t, test, batchModel{}, WithConcurrentCmds(2))
+}
+
+type batchModel struct{}
+
+func (batchModel) Init() tea.Cmd {
+	return tea.Batch(tea.Println("a"), tea.Println("b"), tea.Println("c"), tea.Println("d"))
+}
+func (batchModel) Update(tea.Msg) (tea.Model, tea.Cmd) { return batchModel{}, nil }
+func (batchModel) View() string                        { return "MODEL VIEW" }
+
+// TestObserveHeader checks the WithObserveHeader and
+// WithObserveSeparator options.
+func TestObserveHeader(t *testing.T) {
+	const test = `
+run observe=(view,debug)
+----
+=== BEGIN view
+VALUE: 'ႜ'🛇
+=====
+=== BEGIN debug
+DEBUG SAYS HI
+`
+	RunModelFromString(t, test, &structModel{x: 4252}, WithAutoInitDisabled(),
+		WithObserveHeader("=== BEGIN %s"), WithObserveSeparator("====="))
+
+	const noHeader = `
+run
+----
+VALUE: 'ႜ'🛇
+`
+	RunModelFromString(t, noHeader, &structModel{x: 4252}, WithAutoInitDisabled(),
+		WithObserveHeader(""))
+
+	// Other % characters in the header are printed as-is.
+	const percent = `
+run
+----
+## 100% view %d
+VALUE: 'ႜ'🛇
+`
+	RunModelFromString(t, percent, &structModel{x: 4252}, WithAutoInitDisabled(),
+		WithObserveHeader("## 100% %s %d"))
+}
+
+// TestMarkers checks the options that control the markers
+// and printed messages in the test output.
+func TestMarkers(t *testing.T) {
+	RunModel(t, "testdata/markers", helpModel{})
+
+	const test = `
+run
+type a
+----
+-- view:
+VALUE: 1
+  .
+`
+	RunModelFromString(t, test, helpModel{},
+		WithViewMarkers("", "."), WithPrintsHidden())
+
+	const labels = `
+run observe=screen
+type bq
+----
+PRINTED: {MODEL INIT}
+PRINTED: {MODEL UPDATE}
+QUIT
+-- screen:
+alt screen
+`
+	RunModelFromString(t, labels, emptyModel{},
+		WithMarkerLabels(map[string]string{"TEA PRINT": "PRINTED"}),
+		WithMarkerLabels(map[string]string{"TEA QUIT": "QUIT"}), WithAltScreen())
+}
+
+// TestSettings checks the listing of the available settings.
+func TestSettings(t *testing.T) {
+	RunModel(t, "testdata/settings", emptyModel{}, WithViewMarkers("$", "."))
+}
+
+// senderModel starts a background goroutine which sends messages
+// to the model when it receives the key "g".
+type senderModel struct {
+	send     func(tea.Msg)
+	received []string
+}
+
+type bgMsg string
+
+func (m *senderModel) SetSender(send func(tea.Msg)) { m.send = send }
+func (m *senderModel) Init() tea.Cmd                { return nil }
+func (m *senderModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		if msg.String() == "g" {
+			go func() {
+				m.send(bgMsg("hello"))
+				m.send(bgMsg("world"))
+			}()
+		}
+	case bgMsg:
+		m.received = append(m.received, string(msg))
+	}
+	return m, nil
+}
+func (m *senderModel) View() string { return fmt.Sprintf("received: %v", m.received) }
+
+func TestExternalSender(t *testing.T) {
+	const test = `
+run
+type g
+wait_msgs 1
+----
+-- view:
+received: [hello]🛇
+
+run
+wait_msgs 1
+----
+-- view:
+received: [hello world]🛇
+`
+	t.Run("interface", func(t *testing.T) {
+		RunModelFromString(t, test, &senderModel{}, WithExternalSender(nil))
+	})
+	t.Run("injection", func(t *testing.T) {
+		m := &senderModel{}
+		RunModelFromString(t, test, m, WithExternalSender(func(send func(tea.Msg)) {
+			m.send = send
+		}))
+	})
+}
+
+// streamModel subscribes to a stream of messages when it receives
+// the key "s".
+type streamModel struct {
+	ch       chan tea.Msg
+	received []string
+}
+
+func (m *streamModel) Init() tea.Cmd { return nil }
+func (m *streamModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		if msg.String() == "s" {
+			return m, Subscribe(m.ch)
+		}
+	case bgMsg:
+		m.received = append(m.received, string(msg))
+	}
+	return m, nil
+}
+func (m *streamModel) View() string { return fmt.Sprintf("received: %v", m.received) }
+
+func TestSubscription(t *testing.T) {
+	const test = `
+run observe=(cmds,view)
+type s
+pump 2
+----
+-- cmds:
+command queue sz: 0
+active subscriptions: 1
+-- view:
+received: [a b]🛇
+
+run trace=on observe=(cmds,view)
+pump 1
+----
+-- trace: before "pump 1"
+-- trace: pumped msg catwalk.bgMsg
+-- trace: after "pump"
+-- cmds:
+command queue sz: 0
+active subscriptions: 1
+-- view:
+received: [a b]🛇
+-- trace: before finish
+-- cmds:
+command queue sz: 0
+active subscriptions: 1
+-- view:
+received: [a b]🛇
+-- trace: processing 1 messages
+-- trace: msg "c"
+-- trace: at end
+-- cmds:
+command queue sz: 0
+active subscriptions: 1
+-- view:
+received: [a b c]🛇
+`
+	ch := make(chan tea.Msg, 3)
+	for _, s := range []string{"a", "b", "c"} {
+		ch <- bgMsg(s)
+	}
+	close(ch)
+	RunModelFromString(t, test, &streamModel{ch: ch})
+}
+
+// loopModel produces a command which reproduces itself endlessly
+// when it receives the key "l".
+type loopModel struct{}
+
+func (loopModel) Init() tea.Cmd { return nil }
+func (m loopModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if kmsg, ok := msg.(tea.KeyMsg); ok && kmsg.String() == "l" {
+		return m, loop
+	}
+	return m, nil
+}
+func (loopModel) View() string { return "" }
+
+func loop() tea.Msg { return tea.Batch(loop, tea.Println("again"))() }
+
+func TestMaxIterations(t *testing.T) {
+	d := NewDriver(loopModel{}, WithMaxIterations(20))
+	defer d.Close(t)
+	fatal := expectFatal(t, d, &datadriven.TestData{Pos: "test:1", Cmd: "run", Input: "type l\ntype l"})
+	const expected = "test:1: more than 20 messages and commands processed, possible infinite loop; recent history:\n"
+	const expectedHist = "\n  cmd: github.com/knz/catwalk.loop -> tea.batchMsg\n"
+	if !strings.HasPrefix(fatal, expected) || !strings.Contains(fatal, expectedHist) {
+		t.Errorf("expected:\n%s...%s\ngot:\n%s", expected, expectedHist, fatal)
+	}
+}
+
+// bigBatchModel returns a large batch of trivial commands when it
+// receives the key "b".
+type bigBatchModel struct{ received int }
+
+type batchItemMsg struct{}
+
+func (bigBatchModel) Init() tea.Cmd { return nil }
+func (m bigBatchModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		if msg.String() == "b" {
+			cmds := make([]tea.Cmd, 200)
+			for i := range cmds {
+				cmds[i] = func() tea.Msg { return batchItemMsg{} }
+			}
+			return m, tea.Batch(cmds...)
+		}
+	case batchItemMsg:
+		m.received++
+	}
+	return m, nil
+}
+func (m bigBatchModel) View() string { return fmt.Sprintf("received: %d", m.received) }
+
+// TestMaxIterationsBatch checks that the commands of a large batch
+// are counted once each towards the iteration limit.
+func TestMaxIterationsBatch(t *testing.T) {
+	RunModelFromString(t, `
+run
+type b
+----
+-- view:
+received: 200🛇
+`, bigBatchModel{}, WithMaxIterations(500))
+}
+
+// fatalTB is a TB which records the fatal error and aborts
+// the test directive with a panic.
+type fatalTB struct {
+	TB
+	fatal string
+}
+
+var errFatal = errors.New("fatal")
+
+func (f *fatalTB) Fatalf(format string, args ...interface{}) {
+	f.fatal = fmt.Sprintf(format, args...)
+	panic(errFatal)
+}
+
+// expectFatal runs the test directive and returns the fatal error
+// it reports, or "" if there is none.
+func expectFatal(t TB, d Driver, td *datadriven.TestData) string {
+	return catchFatal(t, func(t TB) { d.RunOneTest(t, td) })
+}
+
+// catchFatal calls fn with a TB which aborts fn upon a fatal error,
+// and returns the error, or "" if there is none.
+func catchFatal(t TB, fn func(t TB)) (fatal string) {
+	ft := &fatalTB{TB: t}
+	defer func() {
+		if r := recover(); r != nil && r != errFatal {
+			panic(r)
+		}
+		fatal = ft.fatal
+	}()
+	fn(ft)
+	return ""
+}
+
+// impureModel has a View() method which depends on external state.
+type impureModel struct {
+	counter *int
+	step    int
+}
+
+func (impureModel) Init() tea.Cmd { return nil }
+func (m impureModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	return m, nil
+}
+func (m impureModel) View() string {
+	*m.counter += m.step
+	return fmt.Sprintf("counter: %d", *m.counter)
+}
+
+func TestViewPurityCheck(t *testing.T) {
+	runTest := func(m impureModel, input string, between func()) string {
+		d := NewDriver(m, WithViewPurityCheck())
+		defer d.Close(t)
+		return catchFatal(t, func(t TB) {
+			d.RunOneTest(t, &datadriven.TestData{Pos: "test:1", Cmd: "run", Input: input})
+			between()
+			d.RunOneTest(t, &datadriven.TestData{Pos: "test:2", Cmd: "run", Input: input})
+		})
+	}
+
+	var c int
+	noop := func() {}
+	incr := func() { c++ }
+
+	// A pure view passes.
+	if err := runTest(impureModel{counter: &c}, "type a", noop); err != "" {
+		t.Errorf("unexpected error: %s", err)
+	}
+	// A view which changes after an update passes.
+	if err := runTest(impureModel{counter: &c}, "type a", incr); err != "" {
+		t.Errorf("unexpected error: %s", err)
+	}
+	// A view which mutates state fails.
+	c = 0
+	const expected1 = "test:1: View() is not pure: consecutive calls returned different results:\ncounter: 2\n--- vs ---\ncounter: 3"
+	if err := runTest(impureModel{counter: &c, step: 1}, "", noop); err != expected1 {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected1, err)
+	}
+	// A view which changes without an update fails.
+	c = 0
+	const expected2 = "test:2: View() changed without an update to the model:\ncounter: 0\n--- vs ---\ncounter: 1"
+	if err := runTest(impureModel{counter: &c}, "", incr); err != expected2 {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected2, err)
+	}
+}
+
+// mutatingModel is a by-value model whose Update method
+// mutates the shared map when it receives the key "m".
+type mutatingModel struct {
+	counts map[string]int
+	keys   []string
+}
+
+func (mutatingModel) Init() tea.Cmd { return nil }
+func (m mutatingModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if kmsg, ok := msg.(tea.KeyMsg); ok {
+		k := kmsg.String()
+		if k == "m" {
+			m.counts[k]++
+			return m, nil
+		}
+		// Correct: copy before modifying.
+		newCounts := make(map[string]int, len(m.counts)+1)
+		for k, v := range m.counts {
+			newCounts[k] = v
+		}
+		newCounts[k]++
+		m.counts = newCounts
+		m.keys = append(m.keys[:len(m.keys):len(m.keys)], k)
+	}
+	return m, nil
+}
+func (m mutatingModel) View() string { return fmt.Sprintf("%v %v", m.counts, m.keys) }
+
+func TestUpdateImmutabilityCheck(t *testing.T) {
+	runTest := func(m tea.Model, input string) string {
+		d := NewDriver(m, WithUpdateImmutabilityCheck())
+		defer d.Close(t)
+		return expectFatal(t, d, &datadriven.TestData{Pos: "test:1", Cmd: "run", Input: input})
+	}
+
+	if err := runTest(mutatingModel{counts: map[string]int{}}, "type ab"); err != "" {
+		t.Errorf("unexpected error: %s", err)
+	}
+	const expected = "test:1: Update(tea.KeyMsg) mutated the original model:\ncounts[\"m\"]: (missing) != '\\x01'"
+	if err := runTest(mutatingModel{counts: map[string]int{}}, "type am"); err != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, err)
+	}
+	// Models passed by reference are not checked.
+	if err := runTest(&senderModel{}, "type a"); err != "" {
+		t.Errorf("unexpected error: %s", err)
+	}
+	// Complex models can be copied.
+	if err := runTest(helpModel{}, "type a"); err != "" {
+		t.Errorf("unexpected error: %s", err)
+	}
+}
+
+// rendezvousModel has an Update() method which waits for a
+// concurrent call to View().
+type rendezvousModel struct{ viewed chan struct{} }
+
+func (rendezvousModel) Init() tea.Cmd { return nil }
+func (m rendezvousModel) Update(tea.Msg) (tea.Model, tea.Cmd) {
+	select {
+	case <-m.viewed:
+		return m, nil
+	case <-time.After(10 * time.Second):
+		panic("View() was not called concurrently")
+	}
+}
+func (m rendezvousModel) View() string {
+	select {
+	case m.viewed <- struct{}{}:
+	default:
+	}
+	return "OK"
+}
+
+func TestConcurrentView(t *testing.T) {
+	m := rendezvousModel{viewed: make(chan struct{})}
+	d := NewDriver(m, WithConcurrentView())
+	defer d.Close(t)
+	out := d.RunOneTest(t, &datadriven.TestData{Pos: "test:1", Cmd: "run", Input: "type ab"})
+	if expected := "-- view:\nOK🛇\n"; out != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, out)
+	}
+}
+
+func TestRegistrationCollisions(t *testing.T) {
+	newDriver := func(opts ...Option) (err string) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Sprint(r)
+			}
+		}()
+		NewDriver(emptyModel{}, opts...)
+		return ""
+	}
+
+	testData := []struct {
+		opts     []Option
+		expected string
+	}{
+		{[]Option{WithObserver("hello", observeDebug), WithObserver("world", observeDebug)}, ``},
+		{[]Option{WithObserver("hello", observeDebug), WithObserver("hello", observeDebug)},
+			`^catwalk: observer "hello" registered twice, at options_test.go:\d+ and options_test.go:\d+; use WithObserverOverride\(\) to replace it$`},
+		{[]Option{WithObserver("hello", observeDebug), WithObserverOverride("hello", observeDebug)}, ``},
+		{[]Option{WithObserverOverride("view", observeDebug)}, ``},
+		{[]Option{WithObserver("view", observeDebug)},
+			`^catwalk: observer "view" registered at options_test.go:\d+ is a built-in observer; use WithObserverOverride\(\) to replace it$`},
+		{[]Option{WithObserver("screen", observeDebug)},
+			`^catwalk: observer "screen" registered at options_test.go:\d+ is a built-in observer; use WithObserverOverride\(\) to replace it$`},
+		{[]Option{WithUpdaterCommands("double"), WithUpdaterCommands("triple")}, ``},
+		{[]Option{WithUpdaterCommands("double"), WithUpdaterCommands("double")},
+			`^catwalk: command "double" declared twice, at options_test.go:\d+ and options_test.go:\d+$`},
+		{[]Option{WithUpdaterCommands("type")},
+			`^catwalk: command "type" declared at options_test.go:\d+ is a built-in command and cannot be handled by updaters$`},
+		{[]Option{WithRunArg("record", nil), WithRunArg("replay", nil)}, ``},
+		{[]Option{WithPlugin(parityPlugin{}), WithUpdaterCommands("double")},
+			`^catwalk: command "double" declared twice, at options_test.go:\d+ \(plugin "parity"\) and options_test.go:\d+$`},
+		{[]Option{WithObserver("parity", observeDebug), WithPlugin(parityPlugin{})},
+			`^catwalk: observer "parity" registered twice, at options_test.go:\d+ and options_test.go:\d+ \(plugin "parity"\); use WithObserverOverride\(\) to replace it$`},
+		{[]Option{WithRunArg("record", nil), WithRunArg("record", nil)},
+			`^catwalk: run argument "record" declared twice, at options_test.go:\d+ and options_test.go:\d+$`},
+		{[]Option{WithRunArg("trace", nil)},
+			`^catwalk: run argument "trace" declared at options_test.go:\d+ is a built-in argument$`},
+	}
+	for i, tc := range testData {
+		err := newDriver(tc.opts...)
+		if tc.expected == "" {
+			if err != "" {
+				t.Errorf("%d: unexpected error: %s", i, err)
+			}
+			continue
+		}
+		if !regexp.MustCompile(tc.expected).MatchString(err) {
+			t.Errorf("%d: expected:\n%s\ngot:\n%s", i, tc.expected, err)
+		}
+	}
+}
+
+// TestObserverOverrideBuiltin checks that WithObserverOverride
+// replaces the built-in observers.
+func TestObserverOverrideBuiltin(t *testing.T) {
+	fixed := func(s string) Observer {
+		return func(out io.Writer, _ tea.Model) error {
+			_, err := io.WriteString(out, s+"\n")
+			return err
+		}
+	}
+	RunModelFromString(t, `
+run observe=(view,screen,help)
+----
+-- view:
+custom view
+-- screen:
+custom screen
+-- help:
+custom help
+`, intModel(0),
+		WithObserverOverride("view", fixed("custom view")),
+		WithObserverOverride("screen", fixed("custom screen")),
+		WithObserverOverride("help", fixed("custom help")))
+}
+
+// slowModel has a View() method which takes the given time.
+type slowModel struct{ delay time.Duration }
+
+func (slowModel) Init() tea.Cmd                         { return nil }
+func (m slowModel) Update(tea.Msg) (tea.Model, tea.Cmd) { return m, nil }
+func (m slowModel) View() string                        { time.Sleep(m.delay); return "SLOW" }
+
+func TestMaxOutputSize(t *testing.T) {
+	runTest := func(limit int) string {
+		d := NewDriver(intModel(0), WithMaxOutputSize(limit))
+		defer d.Close(t)
+		return expectFatal(t, d, &datadriven.TestData{Pos: "test:1", Cmd: "run", Input: "type a"})
+	}
+
+	if err := runTest(100); err != "" {
+		t.Errorf("unexpected error: %s", err)
+	}
+	const expected = "test:1: the output (22 bytes) exceeds the limit of 10 bytes; it starts with:\n-- view:\nVALUE: 1🛇\n"
+	if err := runTest(10); err != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, err)
+	}
+}
+
+func TestViewBudget(t *testing.T) {
+	runTest := func(m tea.Model, opt Option) (fatal, logged string) {
+		lt := &logTB{TB: t}
+		d := NewDriver(m, opt)
+		defer d.Close(t)
+		fatal = expectFatal(lt, d, &datadriven.TestData{Pos: "test:1", Cmd: "run"})
+		return fatal, strings.Join(lt.logs, "\n")
+	}
+
+	if fatal, _ := runTest(slowModel{}, WithViewBudget(time.Second)); fatal != "" {
+		t.Errorf("unexpected error: %s", fatal)
+	}
+	if fatal, _ := runTest(slowModel{delay: 10 * time.Millisecond}, WithViewBudget(time.Millisecond)); !strings.HasPrefix(fatal, "test:1: View() took ") {
+		t.Errorf("expected budget error, got: %q", fatal)
+	}
+	fatal, logged := runTest(slowModel{delay: 10 * time.Millisecond}, WithViewBudgetWarning(time.Millisecond))
+	if fatal != "" {
+		t.Errorf("unexpected error: %s", fatal)
+	}
+	if !strings.Contains(logged, "test:1: warning: View() took ") {
+		t.Errorf("expected budget warning, got: %q", logged)
+	}
+}
+
+// stepModel counts up to a limit, one command round-trip at a time.
+type stepModel struct{ n, limit int }
+
+type stepMsg struct{}
+
+func (stepModel) Init() tea.Cmd { return nil }
+func (m stepModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg.(type) {
+	case tea.KeyMsg:
+		m.n = 0
+	case stepMsg:
+		m.n++
+	}
+	if m.n >= m.limit {
+		return m, nil
+	}
+	return m, func() tea.Msg { return stepMsg{} }
+}
+func (m stepModel) View() string { return fmt.Sprintf("step %d", m.n) }
+
+func TestWaitFor(t *testing.T) {
+	// Note: the view in the output reflects the messages and commands
+	// processed at the end of the run directive, after wait_for.
+	const test = `
+run
+type a
+wait_for "step [2-9]"
+----
+-- view:
+step 4🛇
+`
+	RunModelFromString(t, test, stepModel{limit: 5})
+
+	const testTimeout = `
+run
+type a
+wait_for "step 5" timeout=100ms
+----
+-- view:
+step 5🛇
+`
+	RunModelFromString(t, testTimeout, stepModel{limit: 5})
+
+	d := NewDriver(stepModel{limit: 3})
+	defer d.Close(t)
+	fatal := expectFatal(t, d, &datadriven.TestData{Pos: "test:1", Cmd: "run", Input: "type a\nwait_for \"step 4\" timeout=10ms"})
+	const expected = "test:1: timeout after 10ms waiting for the view to match \"step 4\"; last view:\nstep 3"
+	if fatal != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, fatal)
+	}
+}
+
+// TestWaitForBusy checks that wait_for times out when the model keeps
+// producing commands, even without a limit on the iterations.
+func TestWaitForBusy(t *testing.T) {
+	d := NewDriver(pingModel{}, WithMaxIterations(0))
+	defer d.Close(t)
+	fatal := expectFatal(t, d, &datadriven.TestData{Pos: "test:1", Cmd: "run", Input: "type a\nwait_for \"never\" timeout=20ms"})
+	const expected = "test:1: timeout after 20ms waiting for the view to match \"never\"; last view:\n"
+	if fatal != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, fatal)
+	}
+}
+
+// TestWaitForVirtualClock checks that wait_for advances the virtual
+// clock when there is nothing else to process.
+func TestWaitForVirtualClock(t *testing.T) {
+	RunModelFromString(t, `
+run
+wait_for "ticks: 3"
+----
+-- view:
+ticks: 3, every: 0, last: 00:00:00.300🛇
+`, tickModel{}, WithVirtualClock())
+}
+
+// loadModel loads its data in several steps, each of which takes
+// longer than the cmd_timeout used in the tests.
+type loadModel struct {
+	loaded int
+	// delay is how long each step takes, 20ms by default.
+	delay time.Duration
+}
+
+type loadedMsg struct{}
+
+func (m loadModel) Init() tea.Cmd { return m.load }
+
+func (m loadModel) load() tea.Msg {
+	delay := m.delay
+	if delay == 0 {
+		delay = 20 * time.Millisecond
+	}
+	time.Sleep(delay)
+	return loadedMsg{}
+}
+
+func (m loadModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if _, ok := msg.(loadedMsg); ok {
+		m.loaded++
+		if m.loaded < 3 {
+			return m, m.load
+		}
+	}
+	return m, nil
+}
+
+func (m loadModel) View() string { return fmt.Sprintf("loaded %d/3", m.loaded) }
+
+func TestWaitInit(t *testing.T) {
+	const test = `
+set cmd_timeout=5ms
+----
+cmd_timeout: 5ms
+
+run
+wait_init
+----
+-- view:
+loaded 3/3🛇
+`
+	RunModelFromString(t, test, loadModel{})
+
+	const testNoWait = `
+set cmd_timeout=5ms
+----
+cmd_timeout: 5ms
+
+run
+----
+-- view:
+loaded 0/3🛇
+`
+	RunModelFromString(t, testNoWait, loadModel{})
+
+	// Note: the view in the output reflects the messages and commands
+	// processed at the end of the run directive, after wait_init.
+	const testPattern = `
+set cmd_timeout=5ms
+----
+cmd_timeout: 5ms
+
+run
+wait_init "loaded 2"
+----
+-- view:
+loaded 3/3🛇
+`
+	RunModelFromString(t, testPattern, loadModel{})
+
+	d := NewDriver(loadModel{})
+	defer d.Close(t)
+	fatal := expectFatal(t, d, &datadriven.TestData{Pos: "test:1", Cmd: "run", Input: "wait_init timeout=30ms"})
+	const expected = "test:1: timeout after 30ms waiting for the initialization to settle"
+	if fatal != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, fatal)
+	}
+}
+
+// TestWaitInitDeadline checks that the commands run by wait_init
+// are bounded by the remaining time, and that a command abandoned at
+// the deadline fails the test.
+func TestWaitInitDeadline(t *testing.T) {
+	d := NewDriver(loadModel{delay: 150 * time.Millisecond})
+	defer d.Close(t)
+	start := time.Now()
+	fatal := expectFatal(t, d, &datadriven.TestData{Pos: "test:1", Cmd: "run", Input: "wait_init timeout=200ms"})
+	if elapsed := time.Since(start); elapsed > 275*time.Millisecond {
+		t.Errorf("wait_init took %s, expected about 200ms", elapsed)
+	}
+	const expected = "test:1: timeout after 200ms waiting for the initialization to settle"
+	if fatal != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, fatal)
+	}
+}
+
+// TestVirtualTime checks the after and advance input commands.
+func TestVirtualTime(t *testing.T) {
+	RunModel(t, "testdata/clock", intModel(0))
+}
+
+// tickModel counts the ticks of a tea.Tick loop, and starts a
+// tea.Every loop upon any key.
+type tickModel struct {
+	ticks, every int
+	last         time.Time
+}
+
+type tickMsg time.Time
+type everyMsg time.Time
+
+func (m tickModel) Init() tea.Cmd {
+	return tea.Tick(100*time.Millisecond, func(t time.Time) tea.Msg { return tickMsg(t) })
+}
+
+func (m tickModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tickMsg:
+		m.ticks++
+		m.last = time.Time(msg)
+		return m, m.Init()
+	case everyMsg:
+		m.every++
+		m.last = time.Time(msg)
+		return m, nil
+	case tea.KeyMsg:
+		return m, tea.Every(time.Second, func(t time.Time) tea.Msg { return everyMsg(t) })
+	}
+	return m, nil
+}
+
+func (m tickModel) View() string {
+	return fmt.Sprintf("ticks: %d, every: %d, last: %s", m.ticks, m.every, m.last.Format("15:04:05.000"))
+}
+
+// TestVirtualClock checks that the tick commands follow the virtual
+// time with WithVirtualClock.
+func TestVirtualClock(t *testing.T) {
+	RunModel(t, "testdata/ticks", tickModel{}, WithVirtualClock())
+}
+
+// TestTickLayout checks that the virtual clock supports the version
+// of bubbletea in use.
+func TestTickLayout(t *testing.T) {
+	if err := checkTickLayout(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestProcessing checks the input commands that control the
+// processing of messages and commands.
+func TestProcessing(t *testing.T) {
+	RunModel(t, "testdata/processing", emptyModel{}, WithAutoInitDisabled())
+}
+
+// TestBreakpoints checks the break_on input command.
+func TestBreakpoints(t *testing.T) {
+	RunModel(t, "testdata/breakpoints", intModel(0))
+}
@@ -0,0 +1,111 @@
+package catwalk
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/kr/pretty"
+)
+
+// pathObserverPrefix is the prefix of the observers which show a
+// value inside the model, e.g. path:viewport.YOffset.
+const pathObserverPrefix = "path:"
+
+// parsePathObserver recognizes the path observer, of the form
+// path:<field>.<field>...
+func parsePathObserver(name string) (path string, isPath bool) {
+	if !strings.HasPrefix(name, pathObserverPrefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(name, pathObserverPrefix), true
+}
+
+// observePath implements the path observer: it shows the value at
+// the given path inside the model, resolved via reflection. The path
+// is a dot-separated list of field names, unexported fields
+// included, and of indexes in slices and arrays. Pointers and
+// interfaces are followed transparently.
+func observePath(buf io.Writer, m tea.Model, path string) error {
+	if m == nil {
+		return fmt.Errorf("model is nil")
+	}
+	// Start from an addressable copy of the model, so that the
+	// unexported fields can be copied out below.
+	v := reflect.New(reflect.TypeOf(m)).Elem()
+	v.Set(reflect.ValueOf(m))
+	var prefix string
+	for _, name := range strings.Split(path, ".") {
+		if name == "" {
+			return fmt.Errorf("invalid path %q", path)
+		}
+		var err error
+		if v, err = pathStep(v, prefix, name); err != nil {
+			return err
+		}
+		prefix += "." + name
+	}
+	_, err := fmt.Fprintf(buf, "%s\n", formatPathValue(exportValue(v)))
+	return err
+}
+
+// formatPathValue formats the value for the path observer. Strings
+// are quoted, so that leading and trailing spaces are visible;
+// composite values are formatted like in the gostruct observer.
+func formatPathValue(val interface{}) string {
+	switch v := reflect.ValueOf(val); v.Kind() {
+	case reflect.String:
+		return strconv.Quote(v.String())
+	case reflect.Struct, reflect.Slice, reflect.Array, reflect.Map, reflect.Ptr, reflect.Interface:
+		return pretty.Sprint(val)
+	default:
+		return fmt.Sprint(val)
+	}
+}
+
+// pathStep returns the field or element called name of v, which is
+// at the given path inside the model. The result is addressable.
+func pathStep(v reflect.Value, prefix, name string) (reflect.Value, error) {
+	where := "model"
+	if prefix != "" {
+		where = strings.TrimPrefix(prefix, ".")
+	}
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return v, fmt.Errorf("%s is nil", where)
+		}
+		if v.Kind() == reflect.Ptr {
+			v = v.Elem()
+			continue
+		}
+		// The value in an interface is not addressable; make an
+		// addressable copy.
+		e := exportValue(v)
+		c := reflect.New(reflect.TypeOf(e)).Elem()
+		c.Set(reflect.ValueOf(e))
+		v = c
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		f := v.FieldByName(name)
+		if !f.IsValid() {
+			var names []string
+			for i := 0; i < v.NumField(); i++ {
+				names = append(names, v.Type().Field(i).Name)
+			}
+			return v, fmt.Errorf("%s (type %s) has no field %q%s", where, v.Type(), name, didYouMean(name, names))
+		}
+		return f, nil
+	case reflect.Slice, reflect.Array:
+		i, err := strconv.Atoi(name)
+		if err != nil || i < 0 || i >= v.Len() {
+			return v, fmt.Errorf("%s (type %s) has no element %q, length is %d", where, v.Type(), name, v.Len())
+		}
+		return v.Index(i), nil
+	default:
+		return v, fmt.Errorf("%s (type %s) has no field %q", where, v.Type(), name)
+	}
+}
@@ -0,0 +1,70 @@
+package catwalk
+
+import (
+	"testing"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/cockroachdb/datadriven"
+)
+
+// pathModel contains values at various depths.
+type pathModel struct {
+	scrollModel
+	KeyMap struct{ Up key.Binding }
+	Items  []string
+	next   *pathModel
+}
+
+func (m pathModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+	m.vp, cmd = m.vp.Update(msg)
+	return m, cmd
+}
+
+func TestPathObserver(t *testing.T) {
+	m := pathModel{
+		scrollModel: newScrollModel(),
+		Items:       []string{"a", "b"},
+		next:        &pathModel{Items: []string{"c"}},
+	}
+	m.KeyMap.Up = key.NewBinding(key.WithKeys("up", "k"))
+
+	RunModelFromString(t, `
+run observe=(path:vp.YOffset,path:Items.1,path:next.Items)
+----
+-- path:vp.YOffset:
+0
+-- path:Items.1:
+"b"
+-- path:next.Items:
+[]string{"c"}
+
+run observe=(path:scrollModel.vp.YOffset,path:KeyMap.Up.keys)
+key down
+----
+-- path:scrollModel.vp.YOffset:
+1
+-- path:KeyMap.Up.keys:
+[]string{"up", "k"}
+`, m)
+
+	d := NewDriver(m)
+	defer d.Close(t)
+	for _, tc := range []struct {
+		path   string
+		expErr string
+	}{
+		{"Itmes", `model (type catwalk.pathModel) has no field "Itmes" (did you mean "Items"?)`},
+		{"Items.2", `Items (type []string) has no element "2", length is 2`},
+		{"vp.YOffset.x", `vp.YOffset (type int) has no field "x"`},
+		{"next.next.Items", `next.next is nil`},
+		{"vp..YOffset", `invalid path "vp..YOffset"`},
+	} {
+		fatal := expectFatal(t, d, &datadriven.TestData{Pos: "test:1", Cmd: "run",
+			CmdArgs: []datadriven.CmdArg{{Key: "observe", Vals: []string{"path:" + tc.path}}}})
+		if exp := `test:1: observing "path:` + tc.path + `": ` + tc.expErr; fatal != exp {
+			t.Errorf("expected %q, got %q", exp, fatal)
+		}
+	}
+}
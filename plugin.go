@@ -0,0 +1,109 @@
+package catwalk
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Plugin bundles the test support for a family of components, e.g.
+// the components of a widget library, so that it can be shipped as
+// a package and activated in a test with WithPlugin().
+//
+// Plugins which need other options, e.g. WithRunArg or
+// WithMessageBuilder, can also implement PluginOptions.
+type Plugin interface {
+	// Name identifies the plugin. Its updaters are registered under
+	// this name, as with WithNamedUpdater.
+	Name() string
+	// Updaters returns the updaters of the plugin. They are chained
+	// in the order given.
+	Updaters() []Updater
+	// Commands returns the names of the input commands supported by
+	// the updaters, as with WithUpdaterCommands.
+	Commands() []string
+	// Observers returns the observers of the plugin, by name, as
+	// with WithObserver.
+	Observers() map[string]Observer
+	// CmdStubs returns the simulated commands, by function name, as
+	// with WithCmdStub.
+	CmdStubs() map[string]CmdStub
+}
+
+// PluginOptions can be implemented by a Plugin to apply additional
+// options when it is activated with WithPlugin().
+type PluginOptions interface {
+	// Options returns the options to apply after the registrations
+	// of the plugin.
+	Options() []Option
+}
+
+// WithPlugin activates the given plugin in the test: it registers
+// its updaters, input commands, observers and command stubs, then
+// applies its options if it implements PluginOptions.
+//
+// The registrations follow the same rules as the corresponding
+// options. In particular, an observer or command which is already
+// registered is a programming error and panics, reporting the
+// registration sites. The updaters of the plugin can be removed
+// with WithoutUpdater, using the name of the plugin.
+func WithPlugin(p Plugin) Option {
+	site := fmt.Sprintf("%s (plugin %q)", callerSite(), p.Name())
+	return func(d *driver) {
+		if upds := p.Updaters(); len(upds) > 0 {
+			WithNamedUpdater(p.Name(), ChainUpdaters(upds...))(d)
+		}
+		d.addUpdaterCommands(site, p.Commands()...)
+		// Register the observers in a deterministic order, so that
+		// collisions are reported consistently.
+		obs := p.Observers()
+		names := make([]string, 0, len(obs))
+		for name := range obs {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			d.addObserver(name, obs[name], site)
+		}
+		for name, stub := range p.CmdStubs() {
+			WithCmdStub(name, stub)(d)
+		}
+		if po, ok := p.(PluginOptions); ok {
+			for _, opt := range po.Options() {
+				opt(d)
+			}
+		}
+	}
+}
+
+// SimplePlugin is a helper to define a Plugin from its
+// registrations, without defining a new type.
+type SimplePlugin struct {
+	PluginName      string
+	PluginUpdaters  []Updater
+	PluginCommands  []string
+	PluginObservers map[string]Observer
+	PluginCmdStubs  map[string]CmdStub
+	// PluginOptions are additional options, see PluginOptions.
+	PluginOptions []Option
+}
+
+var _ Plugin = (*SimplePlugin)(nil)
+var _ PluginOptions = (*SimplePlugin)(nil)
+
+// Name implements the Plugin interface.
+func (p *SimplePlugin) Name() string { return p.PluginName }
+
+// Updaters implements the Plugin interface.
+func (p *SimplePlugin) Updaters() []Updater { return p.PluginUpdaters }
+
+// Commands implements the Plugin interface.
+func (p *SimplePlugin) Commands() []string { return p.PluginCommands }
+
+// Observers implements the Plugin interface.
+func (p *SimplePlugin) Observers() map[string]Observer { return p.PluginObservers }
+
+// CmdStubs implements the Plugin interface.
+func (p *SimplePlugin) CmdStubs() map[string]CmdStub { return p.PluginCmdStubs }
+
+// Options implements the PluginOptions interface.
+func (p *SimplePlugin) Options() []Option { return p.PluginOptions }
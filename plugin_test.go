@@ -0,0 +1,71 @@
+package catwalk
+
+import (
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// parityPlugin is a plugin for intModel.
+type parityPlugin struct{}
+
+func (parityPlugin) Name() string                 { return "parity" }
+func (parityPlugin) Updaters() []Updater          { return []Updater{updater} }
+func (parityPlugin) Commands() []string           { return []string{"double", "noopcmd"} }
+func (parityPlugin) CmdStubs() map[string]CmdStub { return nil }
+func (parityPlugin) Observers() map[string]Observer {
+	return map[string]Observer{
+		"parity": func(out io.Writer, m tea.Model) error {
+			_, err := fmt.Fprintf(out, "even: %v\n", m.(intModel)%2 == 0)
+			return err
+		},
+	}
+}
+
+func TestPlugin(t *testing.T) {
+	RunModelFromString(t, `
+run observe=(view,parity)
+type a
+double
+----
+TEA PRINT: {TEST UPDATE CALLED WITH double []}
+-- view:
+VALUE: 2🛇
+-- parity:
+even: true
+`, intModel(0), WithPlugin(parityPlugin{}))
+
+	// The updaters are registered under the name of the plugin.
+	RunModelFromString(t, `
+run observe=parity
+type a
+----
+-- parity:
+even: false
+
+run target=parity
+double
+----
+TEA PRINT: {TEST UPDATE CALLED WITH double []}
+-- view:
+VALUE: 2🛇
+`, intModel(0), WithPlugin(parityPlugin{}))
+
+	// The options of the plugin are applied; the run argument
+	// without a handler is accepted and ignored.
+	RunModelFromString(t, `
+run label=fetch
+type f
+----
+TEA PRINT: {stubbed}
+-- view:
+MODEL VIEW🛇
+`, stubModel{}, WithPlugin(&SimplePlugin{
+		PluginName:     "stubs",
+		PluginCmdStubs: map[string]CmdStub{"fetchData": {Delay: time.Millisecond, Msg: tea.Println("stubbed")()}},
+		PluginOptions:  []Option{WithRunArg("label", nil)},
+	}))
+}
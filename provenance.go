@@ -0,0 +1,46 @@
+package catwalk
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// WithMsgProvenance tells the test driver to annotate each message
+// delivered to the model in the trace output with its origin: the
+// input command, Init(), or the chain of commands and Update() call
+// which produced it. This helps debug long cascades of tea.Batch
+// and tea.Sequence expansions. This can also be changed with
+// `set trace_provenance`.
+func WithMsgProvenance() Option {
+	return func(d *driver) {
+		d.msgProvenance = true
+	}
+}
+
+// setOrigin sets the origin of the commands and messages queued
+// from this point, if message provenance is enabled.
+func (d *driver) setOrigin(format string, args ...interface{}) {
+	if !d.msgProvenance {
+		return
+	}
+	d.origin = fmt.Sprintf(format, args...)
+}
+
+// cmdOriginOf returns the origin of the messages produced by cmd,
+// given the origin of cmd itself.
+func (d *driver) cmdOriginOf(cmd tea.Cmd, origin string) string {
+	if !d.msgProvenance {
+		return ""
+	}
+	name := cmdName(cmd)
+	// Strip the package path, which is usually redundant.
+	if idx := strings.LastIndexByte(name, '/'); idx >= 0 {
+		name = name[idx+1:]
+	}
+	if origin == "" {
+		return name
+	}
+	return name + " <- " + origin
+}
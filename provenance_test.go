@@ -0,0 +1,57 @@
+package catwalk
+
+import "testing"
+
+func TestMsgProvenance(t *testing.T) {
+	const test = `
+run trace=on
+type a
+----
+-- trace: calling Init
+-- trace: before "type a"
+-- trace: processing 1 messages
+-- trace: msg tea.WindowSizeMsg{Width:80, Height:25} (from WithWindowSize())
+TEA WINDOW SIZE: {80 25}
+-- trace: processing 1 cmds
+-- trace: expanded 2 commands
+-- trace: translated cmd: tea.printLineMessage
+-- trace: running sequence of 2 commands
+-- trace: translated cmd: tea.hideCursorMsg
+-- trace: processing 1 messages
+-- trace: msg tea.hideCursorMsg{} (from bubbletea.HideCursor <- bubbletea.Sequence.func1 <- bubbletea.Batch.func1 <- Update(tea.WindowSizeMsg))
+TEA HIDE CURSOR
+-- trace: translated cmd: tea.quitMsg
+-- trace: processing 1 messages
+-- trace: msg tea.quitMsg{} (from bubbletea.Quit <- bubbletea.Sequence.func1 <- bubbletea.Batch.func1 <- Update(tea.WindowSizeMsg))
+TEA QUIT
+-- trace: after "type"
+-- view:
+BATCH🛇
+-- trace: before finish
+-- view:
+BATCH🛇
+-- trace: processing 2 messages
+-- trace: msg tea.KeyMsg: a (from input "type a")
+-- trace: msg tea.printLineMessage{messageBody:"hello"} (from bubbletea.Println.func1 <- bubbletea.Batch.func1 <- Update(tea.WindowSizeMsg))
+TEA PRINT: {hello}
+-- trace: processing 1 cmds
+-- trace: expanded 2 commands
+-- trace: translated cmd: tea.printLineMessage
+-- trace: running sequence of 2 commands
+-- trace: translated cmd: tea.hideCursorMsg
+-- trace: processing 1 messages
+-- trace: msg tea.hideCursorMsg{} (from bubbletea.HideCursor <- bubbletea.Sequence.func1 <- bubbletea.Batch.func1 <- Update(tea.KeyMsg))
+TEA HIDE CURSOR
+-- trace: translated cmd: tea.quitMsg
+-- trace: processing 1 messages
+-- trace: msg tea.quitMsg{} (from bubbletea.Quit <- bubbletea.Sequence.func1 <- bubbletea.Batch.func1 <- Update(tea.KeyMsg))
+TEA QUIT
+-- trace: processing 1 messages
+-- trace: msg tea.printLineMessage{messageBody:"hello"} (from bubbletea.Println.func1 <- bubbletea.Batch.func1 <- Update(tea.KeyMsg))
+TEA PRINT: {hello}
+-- trace: at end
+-- view:
+BATCH🛇
+`
+	RunModelFromString(t, test, cmdBatchModel{}, WithMsgProvenance(), WithWindowSize(80, 25))
+}
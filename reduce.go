@@ -0,0 +1,263 @@
+package catwalk
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/cockroachdb/datadriven"
+)
+
+// ReduceScript finds a minimal subset of the input commands of a
+// failing test script which still reproduces its failure, using delta
+// debugging, and returns the reduced script. This helps turn a long
+// scenario which starts failing, e.g. after a dependency upgrade, into
+// a short reproduction.
+//
+// The script uses the datadriven file format. Each candidate is run
+// with a fresh model and driver created by the given factory.
+// The directives after the first failing directive are dropped, and
+// only the input commands are removed; the directives themselves are
+// preserved. The expected output of the directives before the failing
+// one is updated in the reduced script, so that the reduced script
+// only fails at the same directive as the original.
+//
+// A candidate reproduces the failure when the interesting function
+// returns true for its failure. If interesting is nil, the failure
+// must occur at the same directive as the original failure, with the
+// same error message (when the directive could not complete) or the
+// same observed output (when the output did not match).
+//
+// An error is returned if the script cannot be parsed or does not
+// fail.
+func ReduceScript(
+	t testing.TB, script string, f ModelFactory, interesting func(*Failure) bool,
+) (string, error) {
+	steps, err := parseScript(script)
+	if err != nil {
+		return "", err
+	}
+	idx, orig, _ := runReduceCandidate(t, steps, 0, f)
+	if orig == nil {
+		return "", errors.New("the script does not fail")
+	}
+	steps = steps[:idx+1]
+	if interesting == nil {
+		interesting = func(cur *Failure) bool {
+			return cur.Directive == orig.Directive &&
+				failureMessage(cur) == failureMessage(orig) &&
+				cur.Observed == orig.Observed
+		}
+	}
+
+	// The input commands are numbered across all the steps.
+	type line struct{ step, idx int }
+	var lines []line
+	for i := range steps {
+		for j := range steps[i].Input {
+			lines = append(lines, line{i, j})
+		}
+	}
+	// candidate returns the steps with only the given input lines.
+	candidate := func(keep []line) []Step {
+		res := make([]Step, len(steps))
+		for i := range steps {
+			res[i] = steps[i]
+			res[i].Input = nil
+		}
+		for _, l := range keep {
+			res[l.step].Input = append(res[l.step].Input, steps[l.step].Input[l.idx])
+		}
+		return res
+	}
+	// test returns true if the candidate reproduces the failure at
+	// the same directive.
+	test := func(keep []line) bool {
+		i, fail, _ := runReduceCandidate(t, candidate(keep), idx, f)
+		return fail != nil && i == idx && interesting(fail)
+	}
+
+	// Delta debugging: try to remove chunks of decreasing size.
+	for n := 2; len(lines) > 0; {
+		if n > len(lines) {
+			n = len(lines)
+		}
+		chunk := (len(lines) + n - 1) / n
+		reduced := false
+		for start := 0; start < len(lines); start += chunk {
+			end := start + chunk
+			if end > len(lines) {
+				end = len(lines)
+			}
+			rest := append(append([]line(nil), lines[:start]...), lines[end:]...)
+			if test(rest) {
+				lines = rest
+				if n > 2 {
+					n--
+				}
+				reduced = true
+				break
+			}
+		}
+		if !reduced {
+			if n == len(lines) {
+				break
+			}
+			n *= 2
+		}
+	}
+
+	// Update the expected output of the directives preceding the
+	// failing one.
+	res := candidate(lines)
+	_, _, outputs := runReduceCandidate(t, res, idx, f)
+	for i := 0; i < idx && i < len(outputs); i++ {
+		res[i].Expected = outputs[i]
+	}
+	return (&ScriptBuilder{steps: res}).String(), nil
+}
+
+// runReduceCandidate runs the given steps with a fresh model. The
+// output of the directives before the one at index last is not
+// checked. It returns the index of the first failing directive and
+// its failure, or nil if none fails, as well as the output of the
+// directives which completed.
+func runReduceCandidate(
+	t testing.TB, steps []Step, last int, f ModelFactory,
+) (idx int, fail *Failure, outputs []string) {
+	m, opts := f(t)
+	d := NewDriver(m, opts...)
+	rt := &reduceTB{}
+	defer func() {
+		defer rt.recover()
+		d.Close(rt)
+	}()
+
+	line := 1
+	for i := range steps {
+		st := &steps[i]
+		pos := fmt.Sprintf("<script>:%d", line)
+		var b strings.Builder
+		writeStep(&b, st)
+		line += strings.Count(b.String(), "\n") + 1
+
+		cmd, args, err := datadriven.ParseLine(st.Directive)
+		if err != nil {
+			t.Fatalf("%s: %v", pos, err)
+		}
+		td := &datadriven.TestData{
+			Pos:      pos,
+			Cmd:      cmd,
+			CmdArgs:  args,
+			Input:    strings.Join(st.Input, "\n"),
+			Expected: st.Expected,
+		}
+		var out string
+		func() {
+			defer rt.recover()
+			out = d.RunOneTest(rt, td)
+		}()
+		fail := d.(*driver).newFailure(td)
+		if rt.msg != "" {
+			fail.Message = rt.msg
+			return i, fail, outputs
+		}
+		out = normalizeNewline(out)
+		outputs = append(outputs, out)
+		if i >= last && out != st.Expected {
+			fail.Observed = out
+			return i, fail, outputs
+		}
+	}
+	return len(steps), nil, outputs
+}
+
+// failureMessage returns the message of the failure without the
+// position prefix, which changes as input lines are removed.
+func failureMessage(f *Failure) string {
+	return strings.TrimPrefix(f.Message, fmt.Sprintf("%s:%d: ", f.File, f.Line))
+}
+
+// reduceTB is the TB used to run the candidates of ReduceScript. It
+// records the first fatal error, and panics to abort the directive.
+// A panic in the model is recorded as a fatal error too.
+type reduceTB struct {
+	msg string
+}
+
+// errReduceAbort is the panic value used by reduceTB.
+var errReduceAbort = errors.New("reduce: abort")
+
+func (t *reduceTB) Fatal(args ...interface{}) {
+	t.fail(fmt.Sprint(args...))
+}
+
+func (t *reduceTB) Fatalf(format string, args ...interface{}) {
+	t.fail(fmt.Sprintf(format, args...))
+}
+
+func (t *reduceTB) Logf(string, ...interface{}) {}
+
+func (t *reduceTB) fail(msg string) {
+	if t.msg == "" {
+		t.msg = msg
+	}
+	panic(errReduceAbort)
+}
+
+// recover must be deferred directly.
+func (t *reduceTB) recover() {
+	if r := recover(); r != nil && r != errReduceAbort && t.msg == "" {
+		t.msg = fmt.Sprintf("panic: %v", r)
+	}
+}
+
+// parseScript parses a script in the datadriven file format.
+// Comments and blank lines between directives are not preserved.
+func parseScript(script string) ([]Step, error) {
+	lines := strings.Split(script, "\n")
+	var steps []Step
+	for i := 0; i < len(lines); {
+		l := strings.TrimSpace(lines[i])
+		if l == "" || strings.HasPrefix(l, "#") {
+			i++
+			continue
+		}
+		st := Step{Directive: l}
+		for i++; ; i++ {
+			if i >= len(lines) {
+				return nil, fmt.Errorf("line %d: directive %q has no ---- separator", i, st.Directive)
+			}
+			if lines[i] == "----" {
+				break
+			}
+			st.Input = append(st.Input, lines[i])
+		}
+		i++
+		var expected strings.Builder
+		if i < len(lines) && lines[i] == "----" {
+			// Double separator syntax: the expected output ends
+			// with two separators.
+			for i++; ; i++ {
+				if i >= len(lines) {
+					return nil, fmt.Errorf("line %d: directive %q has no closing ----", i, st.Directive)
+				}
+				if lines[i] == "----" && i+1 < len(lines) && lines[i+1] == "----" {
+					i += 2
+					break
+				}
+				expected.WriteString(lines[i])
+				expected.WriteByte('\n')
+			}
+		} else {
+			for ; i < len(lines) && lines[i] != ""; i++ {
+				expected.WriteString(lines[i])
+				expected.WriteByte('\n')
+			}
+		}
+		st.Expected = expected.String()
+		steps = append(steps, st)
+	}
+	return steps, nil
+}
@@ -0,0 +1,102 @@
+package catwalk
+
+import (
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// bugModel records the keys typed, and panics when b is typed after a.
+type bugModel string
+
+func (bugModel) Init() tea.Cmd { return nil }
+func (m bugModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if k, ok := msg.(tea.KeyMsg); ok && k.Type == tea.KeyRunes {
+		if string(k.Runes) == "b" && strings.Contains(string(m), "a") {
+			panic("boom")
+		}
+		m += bugModel(k.Runes)
+	}
+	return m, nil
+}
+func (m bugModel) View() string { return string(m) }
+
+func TestReduceScript(t *testing.T) {
+	factory := func(t testing.TB) (tea.Model, []Option) { return bugModel(""), nil }
+
+	t.Run("fatal", func(t *testing.T) {
+		const script = `
+run
+type x
+type a
+----
+-- view:
+xa🛇
+
+# Comments are dropped.
+run
+type y
+type zz
+key b
+type c
+----
+-- view:
+xayzzc🛇
+
+run
+type d
+----
+-- view:
+xayzzcd🛇
+`
+		res, err := ReduceScript(t, script, factory, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		const exp = `run
+type a
+----
+-- view:
+a🛇
+
+run
+key b
+----
+-- view:
+xayzzc🛇
+`
+		if res != exp {
+			t.Errorf("expected:\n%s\ngot:\n%s", exp, res)
+		}
+	})
+
+	t.Run("mismatch", func(t *testing.T) {
+		const script = `
+run
+type x
+type y
+type z
+----
+-- view:
+xz🛇
+`
+		res, err := ReduceScript(t, script, factory, func(f *Failure) bool {
+			return strings.Contains(f.Observed, "y")
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		const exp = "run\ntype y\n----\n-- view:\nxz🛇\n"
+		if res != exp {
+			t.Errorf("expected:\n%s\ngot:\n%s", exp, res)
+		}
+	})
+
+	t.Run("no failure", func(t *testing.T) {
+		_, err := ReduceScript(t, "run\ntype a\n----\n-- view:\na🛇\n", factory, nil)
+		if err == nil || err.Error() != "the script does not fail" {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+}
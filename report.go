@@ -0,0 +1,239 @@
+package catwalk
+
+import (
+	"html/template"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/cockroachdb/datadriven"
+)
+
+// WithHTMLReport tells the test driver to collect the observations
+// performed during the test into a browsable HTML report, to make
+// the review of UI changes practical for non-developers.
+//
+// The report is written to the given directory when the driver is
+// closed, as one page per test file. Each page shows the directives
+// in order, with the observations performed at every step (including
+// those triggered by tracing and breakpoints); when the output of a
+// directive does not match the expected output, the differences are
+// highlighted. An index.html page lists all the pages in the
+// directory.
+func WithHTMLReport(dir string) Option {
+	return func(d *driver) {
+		d.reportDir = dir
+	}
+}
+
+// reportDirective is one directive in the HTML report.
+type reportDirective struct {
+	Pos       string
+	Directive string
+	Input     string
+	Frames    []reportFrame
+	Failed    bool
+	Diff      []diffLine
+	Output    string
+}
+
+// reportFrame is one observation in the HTML report.
+type reportFrame struct {
+	Observer string
+	Output   string
+}
+
+// diffLine is one line in a line-based diff. Op is one of
+// " " (unchanged), "-" (expected only) or "+" (actual only).
+type diffLine struct {
+	Op   string
+	Text string
+}
+
+// recordReportFrame records an observation for the HTML report.
+func (d *driver) recordReportFrame(observer, output string) {
+	if d.reportDir == "" || d.reportCur == nil {
+		return
+	}
+	d.reportCur.Frames = append(d.reportCur.Frames, reportFrame{Observer: observer, Output: output})
+}
+
+// startReportDirective starts recording a directive for the HTML
+// report.
+func (d *driver) startReportDirective(td *datadriven.TestData) {
+	if d.reportDir == "" {
+		return
+	}
+	cmd := td.Cmd
+	for _, arg := range td.CmdArgs {
+		cmd += " " + arg.String()
+	}
+	d.reportCur = &reportDirective{Pos: td.Pos, Directive: cmd, Input: td.Input}
+}
+
+// finishReportDirective completes the recording of a directive
+// for the HTML report.
+func (d *driver) finishReportDirective(td *datadriven.TestData, output string) {
+	if d.reportCur == nil {
+		return
+	}
+	rd := d.reportCur
+	d.reportCur = nil
+	rd.Output = output
+	if !outputMatches(output, td.Expected) {
+		rd.Failed = true
+		rd.Diff = lineDiff(td.Expected, output)
+	}
+	d.report = append(d.report, *rd)
+}
+
+// writeReport writes the HTML report for the test file, and
+// updates the index.
+func (d *driver) writeReport(t TB) {
+	if d.reportDir == "" || len(d.report) == 0 {
+		return
+	}
+	if err := os.MkdirAll(d.reportDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	script := d.report[0].Pos
+	if idx := strings.LastIndexByte(script, ':'); idx >= 0 {
+		script = script[:idx]
+	}
+	var buf strings.Builder
+	if err := reportPageTemplate.Execute(&buf, struct {
+		Script     string
+		Directives []reportDirective
+	}{script, d.report}); err != nil {
+		t.Fatal(err)
+	}
+	page := reportFileName(script)
+	if err := ioutil.WriteFile(filepath.Join(d.reportDir, page), []byte(buf.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+	writeReportIndex(t, d.reportDir)
+}
+
+// writeReportIndex writes index.html, listing all the report pages
+// in the directory.
+func writeReportIndex(t TB, dir string) {
+	pages, err := filepath.Glob(filepath.Join(dir, "*.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, p := range pages {
+		if name := filepath.Base(p); name != "index.html" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	var buf strings.Builder
+	if err := reportIndexTemplate.Execute(&buf, names); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "index.html"), []byte(buf.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// reportFileName returns the name of the report page for the given
+// test file.
+func reportFileName(script string) string {
+	return sanitizeFileName(script) + ".html"
+}
+
+// sanitizeFileName replaces the characters that are not safe
+// to use in file names.
+func sanitizeFileName(s string) string {
+	return strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '.' || r == '-' {
+			return r
+		}
+		return '_'
+	}, s)
+}
+
+// lineDiff computes a line-based diff between a and b, using the
+// longest common subsequence of lines.
+func lineDiff(a, b string) []diffLine {
+	al := strings.Split(strings.TrimSuffix(a, "\n"), "\n")
+	bl := strings.Split(strings.TrimSuffix(b, "\n"), "\n")
+	// lcs[i][j] is the length of the LCS of al[i:] and bl[j:].
+	lcs := make([][]int, len(al)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(bl)+1)
+	}
+	for i := len(al) - 1; i >= 0; i-- {
+		for j := len(bl) - 1; j >= 0; j-- {
+			if al[i] == bl[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	var res []diffLine
+	i, j := 0, 0
+	for i < len(al) && j < len(bl) {
+		switch {
+		case al[i] == bl[j]:
+			res = append(res, diffLine{" ", al[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			res = append(res, diffLine{"-", al[i]})
+			i++
+		default:
+			res = append(res, diffLine{"+", bl[j]})
+			j++
+		}
+	}
+	for ; i < len(al); i++ {
+		res = append(res, diffLine{"-", al[i]})
+	}
+	for ; j < len(bl); j++ {
+		res = append(res, diffLine{"+", bl[j]})
+	}
+	return res
+}
+
+const reportStyle = `<style>
+body { font-family: sans-serif; margin: 2em; }
+pre { background: #f6f8fa; padding: 0.5em; margin: 0.2em 0; }
+.directive { border: 1px solid #ddd; margin-bottom: 1em; padding: 0.5em; }
+.failed { border-color: #d73a49; }
+.frame h4 { margin: 0.5em 0 0 0; font-size: 0.9em; color: #555; }
+.del { background: #ffeef0; }
+.add { background: #e6ffed; }
+</style>`
+
+var reportPageTemplate = template.Must(template.New("page").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>{{.Script}}</title>` + reportStyle + `</head>
+<body>
+<p><a href="index.html">index</a></p>
+<h1>{{.Script}}</h1>
+{{range .Directives}}<div class="directive{{if .Failed}} failed{{end}}">
+<h3>{{.Pos}}: {{.Directive}}{{if .Failed}} (FAILED){{end}}</h3>
+{{if .Input}}<pre>{{.Input}}</pre>{{end}}
+{{range .Frames}}<div class="frame"><h4>{{.Observer}}</h4><pre>{{.Output}}</pre></div>
+{{end}}{{if .Failed}}<h4>expected vs actual output</h4>
+<pre>{{range .Diff}}<span class="{{if eq .Op "-"}}del{{else if eq .Op "+"}}add{{end}}">{{.Op}} {{.Text}}</span>
+{{end}}</pre>{{else if not .Frames}}<pre>{{.Output}}</pre>{{end}}
+</div>
+{{end}}</body></html>
+`))
+
+var reportIndexTemplate = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>catwalk report</title>` + reportStyle + `</head>
+<body>
+<h1>catwalk report</h1>
+<ul>
+{{range .}}<li><a href="{{.}}">{{.}}</a></li>
+{{end}}</ul>
+</body></html>
+`))
@@ -0,0 +1,106 @@
+package catwalk
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cockroachdb/datadriven"
+)
+
+func TestHTMLReport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "catwalk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	d := NewDriver(intModel(0), WithHTMLReport(dir))
+	d.RunOneTest(t, &datadriven.TestData{Pos: "testdata/foo:1", Cmd: "run", Input: "type a",
+		Expected: "-- view:\nVALUE: 1🛇\n"})
+	d.RunOneTest(t, &datadriven.TestData{Pos: "testdata/foo:5", Cmd: "run", Input: "type <b>",
+		Expected: "-- view:\nVALUE: 1🛇\n"})
+	d.RunOneTest(t, &datadriven.TestData{Pos: "testdata/foo:9", Cmd: "set",
+		CmdArgs: []datadriven.CmdArg{{Key: "strict_updaters", Vals: []string{"on"}}}, Expected: "strict_updaters: on\n"})
+	d.Close(t)
+
+	page, err := ioutil.ReadFile(filepath.Join(dir, "testdata_foo.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		`<h3>testdata/foo:1: run</h3>`,
+		`<h3>testdata/foo:5: run (FAILED)</h3>`,
+		`<pre>type &lt;b&gt;</pre>`,
+		`<span class="del">- VALUE: 1🛇</span>`,
+		`<span class="add">&#43; VALUE: 4🛇</span>`,
+		`<h3>testdata/foo:9: set strict_updaters=on</h3>`,
+	} {
+		if !strings.Contains(string(page), expected) {
+			t.Errorf("expected %q in report page, got:\n%s", expected, page)
+		}
+	}
+	index, err := ioutil.ReadFile(filepath.Join(dir, "index.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(index), `<a href="testdata_foo.html">`) {
+		t.Errorf("expected link in index, got:\n%s", index)
+	}
+}
+
+func TestLineDiff(t *testing.T) {
+	diff := lineDiff("a\nb\nc\n", "a\nx\nc\nd\n")
+	var buf strings.Builder
+	for _, l := range diff {
+		buf.WriteString(l.Op + l.Text + "\n")
+	}
+	const expected = " a\n-b\n+x\n c\n+d\n"
+	if buf.String() != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, buf.String())
+	}
+}
+
+func TestFailureArtifacts(t *testing.T) {
+	dir, err := ioutil.TempDir("", "catwalk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	d := NewDriver(intModel(0), WithFailureArtifacts(dir))
+	defer d.Close(t)
+	lt := &logTB{TB: t}
+	d.RunOneTest(lt, &datadriven.TestData{Pos: "testdata/foo:1", Cmd: "run", Input: "type a",
+		Expected: "-- view:\nVALUE: 1🛇\n"})
+	d.RunOneTest(lt, &datadriven.TestData{Pos: "testdata/foo:5", Cmd: "run", Input: "type a",
+		Expected: "-- view:\nVALUE: 1🛇\n"})
+	// A passing set directive produces no artifacts.
+	d.RunOneTest(lt, &datadriven.TestData{Pos: "testdata/foo:9", Cmd: "set",
+		CmdArgs: []datadriven.CmdArg{{Key: "strict_updaters", Vals: []string{"on"}}}, Expected: "strict_updaters: on\n"})
+
+	files, err := filepath.Glob(filepath.Join(dir, "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range files {
+		files[i] = filepath.Base(files[i])
+	}
+	const expectedFiles = "testdata_foo_5.actual testdata_foo_5.diff testdata_foo_5.expected"
+	if actual := strings.Join(files, " "); actual != expectedFiles {
+		t.Errorf("expected files %s, got %s", expectedFiles, actual)
+	}
+	diff, err := ioutil.ReadFile(filepath.Join(dir, "testdata_foo_5.diff"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	const expectedDiff = "  -- view:\n- VALUE: 1🛇\n+ VALUE: 2🛇\n"
+	if string(diff) != expectedDiff {
+		t.Errorf("expected diff:\n%s\ngot:\n%s", expectedDiff, diff)
+	}
+	if len(lt.logs) != 1 || !strings.Contains(lt.logs[0], "failure artifacts written") {
+		t.Errorf("expected log message, got %q", lt.logs)
+	}
+}
@@ -0,0 +1,49 @@
+package catwalk
+
+import (
+	"flag"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/cockroachdb/datadriven"
+)
+
+// WithRewriteCheck tells RunModel to verify the test file after it
+// was rewritten with -rewrite: the file is immediately run again
+// with a fresh driver, without rewriting, in a sub-test named
+// "rewrite-check". The test fails if the new expected output does
+// not pass. This catches nondeterministic output at rewrite time
+// instead of on the next CI run.
+//
+// The model is restored to its state at the start of the test for
+// the verification, like with the scenario directive.
+//
+// The -rewrite flag is disabled during the verification, so the
+// tests using this option should not run in parallel with other
+// tests when rewriting.
+func WithRewriteCheck() Option {
+	return func(d *driver) {
+		d.rewriteCheck = true
+	}
+}
+
+// checkRewrite implements WithRewriteCheck.
+func checkRewrite(t *testing.T, path string, m tea.Model, opts []Option) {
+	t.Helper()
+	rw := flag.Lookup("rewrite").Value
+	if err := rw.Set("false"); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = rw.Set("true") }()
+
+	if !t.Run("rewrite-check", func(t *testing.T) {
+		d := NewDriver(m, opts...)
+		defer d.Close(t)
+		datadriven.RunTest(t, path, func(t *testing.T, td *datadriven.TestData) string {
+			t.Helper()
+			return runInScenario(t, d, td)
+		})
+	}) {
+		t.Errorf("%s: the rewritten expected output does not pass when run again; the output of the model may be nondeterministic", path)
+	}
+}
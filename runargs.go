@@ -0,0 +1,96 @@
+package catwalk
+
+import (
+	"fmt"
+
+	"github.com/cockroachdb/datadriven"
+)
+
+// RunArgHandler is the type of a function which handles a custom
+// argument of the run directive, registered with WithRunArg.
+//
+// It is called when a run directive uses the argument, after the
+// arguments have been checked and before the input commands are
+// applied, with the values of the argument: for example ["out.gif"]
+// for record_gif=out.gif, or nil for a bare record_gif. The driver is
+// provided to observe the model or post messages; to follow the
+// progress of the directive, combine the handler with WithListener.
+//
+// If the returned function is not nil, it is called when the
+// directive completes with its output, and its result becomes the
+// output of the directive. This makes it possible e.g. to save an
+// artifact at the end of the directive, or to annotate the output.
+// Errors should be reported with t.Fatalf.
+type RunArgHandler func(t TB, d Driver, vals []string) (finish func(output string) string)
+
+// WithRunArg registers a handler for a custom argument of the run
+// directive, e.g. run record_gif=out.gif. This makes it possible to
+// extend the run directive without changing the test driver.
+//
+// When a run directive uses several custom arguments, their
+// handlers are called in the order the arguments appear in the
+// directive, and the functions they return in the reverse order.
+//
+// A nil handler accepts the argument without doing anything, e.g. to
+// annotate the directives for another tool.
+//
+// Registering the same argument twice, or an argument which is
+// built into the run directive, is a programming error and panics,
+// reporting the registration sites.
+func WithRunArg(name string, h RunArgHandler) Option {
+	site := callerSite()
+	return func(d *driver) {
+		for _, b := range runArgs {
+			if name == b {
+				panic(fmt.Sprintf("catwalk: run argument %q declared at %s is a built-in argument", name, site))
+			}
+		}
+		for _, a := range d.runArgHandlers {
+			if a.name == name {
+				panic(fmt.Sprintf("catwalk: run argument %q declared twice, at %s and %s", name, a.site, site))
+			}
+		}
+		d.runArgHandlers = append(d.runArgHandlers, namedRunArg{name: name, site: site, handler: h})
+	}
+}
+
+// namedRunArg is a custom argument of the run directive registered
+// with WithRunArg.
+type namedRunArg struct {
+	name    string
+	site    string
+	handler RunArgHandler
+}
+
+// runArgNames returns the names of the arguments accepted by the
+// run directive.
+func (d *driver) runArgNames() []string {
+	names := append([]string(nil), runArgs...)
+	for _, a := range d.runArgHandlers {
+		names = append(names, a.name)
+	}
+	return names
+}
+
+// startRunArgs calls the handlers of the custom arguments used by
+// the run directive. It returns the function to apply to the output
+// of the directive when it completes.
+func (d *driver) startRunArgs(t TB, args []datadriven.CmdArg) (finish func(string) string) {
+	var finishers []func(string) string
+	for _, arg := range args {
+		for _, a := range d.runArgHandlers {
+			if a.name != arg.Key || a.handler == nil {
+				continue
+			}
+			if f := a.handler(t, d, arg.Vals); f != nil {
+				finishers = append(finishers, f)
+			}
+		}
+	}
+	return func(output string) string {
+		for i := len(finishers) - 1; i >= 0; i-- {
+			output = finishers[i](output)
+		}
+		return output
+	}
+}
@@ -0,0 +1,50 @@
+package catwalk
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/cockroachdb/datadriven"
+)
+
+func TestRunArg(t *testing.T) {
+	var frames []string
+	record := func(t TB, d Driver, vals []string) func(string) string {
+		frames = append(frames, d.Observe(t, "view"))
+		return func(output string) string {
+			frames = append(frames, d.Observe(t, "view"))
+			return output + fmt.Sprintf("-- recorded %d frames to %s\n", len(frames), strings.Join(vals, ","))
+		}
+	}
+	label := func(t TB, d Driver, vals []string) func(string) string {
+		return func(output string) string {
+			return "-- " + strings.Join(vals, " ") + "\n" + output
+		}
+	}
+	opts := []Option{WithRunArg("record", record), WithRunArg("label", label)}
+
+	RunModelFromString(t, `
+run
+type a
+----
+-- view:
+VALUE: 1🛇
+
+run record=out.gif label=(hello,world)
+type bc
+----
+-- hello world
+-- view:
+VALUE: 3🛇
+-- recorded 2 frames to out.gif
+`, intModel(0), opts...)
+
+	d := NewDriver(intModel(0), opts...)
+	defer d.Close(t)
+	fatal := expectFatal(t, d, &datadriven.TestData{Pos: "test:1", Cmd: "run",
+		CmdArgs: []datadriven.CmdArg{{Key: "recrod"}}})
+	if exp := `test:1: unknown argument "recrod" for run (did you mean "record"?)`; fatal != exp {
+		t.Errorf("expected %q, got %q", exp, fatal)
+	}
+}
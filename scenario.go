@@ -0,0 +1,121 @@
+package catwalk
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/cockroachdb/datadriven"
+)
+
+// modelSnapshot is a deep copy of the model taken when the test
+// driver is created, which is restored at the start of each scenario.
+type modelSnapshot struct {
+	m tea.Model
+	// val is a deep copy of the model, or of the value pointed to by
+	// m when the model is a pointer. In the latter case, it is
+	// restored in-place, so that the updaters which refer to the
+	// model keep working.
+	val reflect.Value
+}
+
+func takeModelSnapshot(m tea.Model) modelSnapshot {
+	s := modelSnapshot{m: m}
+	v := reflect.ValueOf(m)
+	switch {
+	case !v.IsValid():
+	case v.Kind() == reflect.Ptr:
+		if !v.IsNil() {
+			s.val = deepCopy(v.Elem(), make(map[visit]copied))
+		}
+	default:
+		s.val = deepCopy(v, make(map[visit]copied))
+	}
+	return s
+}
+
+// restore returns the model in its state at the time of the
+// snapshot. The snapshot is copied again, so that the changes made
+// in-place to the slices, maps, etc. of the restored model do not
+// leak into the next restoration.
+func (s modelSnapshot) restore() tea.Model {
+	if !s.val.IsValid() {
+		return s.m
+	}
+	c := deepCopy(s.val, make(map[visit]copied))
+	if v := reflect.ValueOf(s.m); v.Kind() == reflect.Ptr {
+		v.Elem().Set(c)
+		return s.m
+	}
+	return c.Interface().(tea.Model)
+}
+
+// startScenario implements the scenario directive: the model is
+// restored to its state at the start of the test file and the
+// driver's pending work is discarded, so that the directives that
+// follow, up to the next scenario, form an independent case. The
+// model is initialized again on the next run directive, and the
+// window size and screen mode are forgotten. The driver settings are
+// preserved.
+func (d *driver) startScenario(t TB, td *datadriven.TestData) string {
+	if len(td.CmdArgs) != 1 || len(td.CmdArgs[0].Vals) != 0 {
+		t.Fatalf("%s: syntax: scenario <name>", d.pos)
+	}
+	d.scenario = td.CmdArgs[0].Key
+	d.m = d.initialModel.restore()
+	d.setupIDGenerator()
+	d.modelUpdated()
+	d.msgs, d.msgOrigins = nil, nil
+	d.cmds, d.cmdOrigins = nil, nil
+	d.subscriptions = nil
+	d.startDone = false
+	d.initCmds = nil
+	d.deferProcessing = false
+	d.now, d.scheduled, d.ticks = 0, nil, nil
+	d.frozen, d.frozenViolation = nil, ""
+	d.normalized = nil
+	d.idSeq = 0
+	d.lastCmd = nil
+	d.altScreen = d.initialAltScreen
+	d.width, d.height, d.sizeKnown = 0, 0, false
+	d.memBaseline, d.memBaselineSet = 0, false
+	return ""
+}
+
+// runInScenario runs one directive of a test file. Within a scenario,
+// the directive runs in a subtest named after the scenario, so that
+// failures are reported per scenario. A mismatch of the output is
+// reported in the subtest, unless the test runs with -rewrite.
+func runInScenario(t *testing.T, d Driver, td *datadriven.TestData) string {
+	t.Helper()
+	dd, ok := d.(*driver)
+	if !ok || dd.scenario == "" || td.Cmd == "scenario" {
+		return d.RunOneTest(t, td)
+	}
+	var actual string
+	started, completed := false, false
+	t.Run(dd.scenario, func(t *testing.T) {
+		t.Helper()
+		started = true
+		actual = d.RunOneTest(t, td)
+		completed = true
+		if !outputMatches(actual, td.Expected) && !rewriting() {
+			t.Errorf("\n%s:\n %s\noutput didn't match expected:\nexpected:\n%s\nfound:\n%s",
+				td.Pos, strings.ReplaceAll(td.Input, "\n", "\n "), td.Expected, actual)
+		}
+	})
+	if !started {
+		// The scenario was filtered out with go test -run. Leave
+		// the expected output unchanged.
+		return td.Expected
+	}
+	if !completed {
+		// The directive was aborted with a fatal error.
+		t.FailNow()
+	}
+	if rewriting() {
+		return actual
+	}
+	return td.Expected
+}
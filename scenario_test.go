@@ -0,0 +1,177 @@
+package catwalk
+
+import (
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// counterModel is a model updated in-place.
+type counterModel struct{ n int }
+
+func (m *counterModel) Init() tea.Cmd { return nil }
+func (m *counterModel) Update(tea.Msg) (tea.Model, tea.Cmd) {
+	m.n++
+	return m, nil
+}
+func (m *counterModel) View() string { return "COUNT: " + strconv.Itoa(m.n) }
+
+func TestScenario(t *testing.T) {
+	const test = `
+run
+type ab
+----
+-- view:
+VALUE: 2🛇
+
+scenario first
+----
+
+run
+type a
+----
+-- view:
+VALUE: 1🛇
+
+run
+type a
+----
+-- view:
+VALUE: 2🛇
+
+# The model is restored at the start of each scenario.
+scenario second
+----
+
+set strict_updaters=on
+----
+strict_updaters: on
+
+run
+type abc
+----
+-- view:
+VALUE: 3🛇
+`
+	RunModelFromString(t, test, intModel(0))
+
+	t.Run("by-reference", func(t *testing.T) {
+		m := &counterModel{}
+		const test = `
+scenario first
+----
+
+run
+type ab
+----
+-- view:
+COUNT: 2🛇
+
+scenario second
+----
+
+run
+check
+type a
+----
+-- view:
+COUNT: 1🛇
+`
+		RunModelFromString(t, test, m, WithUpdater(func(tm tea.Model, cmd string, args ...string) (bool, tea.Model, tea.Cmd, error) {
+			// The updater refers to the original model, which is
+			// restored in-place.
+			if cmd != "check" || tm != tea.Model(m) {
+				return false, nil, nil, nil
+			}
+			return true, tm, nil, nil
+		}))
+	})
+}
+
+// TestScenarioRunFilter checks that the scenarios can be selected
+// with go test -run: the test binary is run again with a filter
+// which only selects the second scenario.
+func TestScenarioRunFilter(t *testing.T) {
+	if os.Getenv("CATWALK_SCENARIO_FILTER") != "" {
+		// The first scenario fails if it runs.
+		RunModelFromString(t, `
+scenario first
+----
+
+run
+type a
+----
+-- view:
+VALUE: 42🛇
+
+scenario second
+----
+
+run
+type ab
+----
+-- view:
+VALUE: 2🛇
+`, intModel(0))
+		return
+	}
+	cmd := exec.Command(os.Args[0], "-test.run=^TestScenarioRunFilter$/^second$", "-test.v")
+	cmd.Env = append(os.Environ(), "CATWALK_SCENARIO_FILTER=1")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("filtered test failed: %v\n%s", err, out)
+	}
+	if !strings.Contains(string(out), "--- PASS: TestScenarioRunFilter/second") ||
+		strings.Contains(string(out), "TestScenarioRunFilter/first") {
+		t.Errorf("expected only the second scenario to run, got:\n%s", out)
+	}
+}
+
+// sliceModel changes its items in-place, and enters the alternate
+// screen upon the key "!".
+type sliceModel struct{ Items []string }
+
+func (m *sliceModel) Init() tea.Cmd { return nil }
+func (m *sliceModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if k, ok := msg.(tea.KeyMsg); ok {
+		if k.String() == "!" {
+			return m, tea.EnterAltScreen
+		}
+		m.Items[0] = k.String()
+	}
+	return m, nil
+}
+func (m *sliceModel) View() string { return strings.Join(m.Items, ",") }
+
+// TestScenarioIsolation checks that the changes made in-place to the
+// model, and the screen mode, do not leak from one scenario into the
+// next.
+func TestScenarioIsolation(t *testing.T) {
+	RunModelFromString(t, `
+scenario first
+----
+
+run observe=(view,screen)
+type x!
+----
+TEA ENTER ALT
+-- view:
+x,b🛇
+-- screen:
+alt screen
+
+scenario second
+----
+
+run observe=(view,screen)
+----
+-- view:
+a,b🛇
+-- screen:
+inline
+`, &sliceModel{Items: []string{"a", "b"}})
+}
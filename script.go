@@ -0,0 +1,251 @@
+package catwalk
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/cockroachdb/datadriven"
+)
+
+// Step is one directive in a test script, together with its input
+// commands and expectations.
+type Step struct {
+	// Directive is the directive line, for example "run" or
+	// "run observe=(view,debug)".
+	Directive string
+	// Input contains the input commands, one per line.
+	Input []string
+	// Expected is the expected output of the directive. It is only
+	// checked when non-empty.
+	Expected string
+	// ViewContains lists strings that the view observed at the end
+	// of the directive must contain. These expectations cannot be
+	// represented in a datadriven file and are thus only checked
+	// when the script is executed directly.
+	ViewContains []string
+}
+
+// ScriptBuilder is a helper to construct test scripts from Go
+// code. Use Script() to create one.
+//
+// For example:
+//
+//	catwalk.Script().
+//	   Run().Key("down").ExpectViewContains("second line").
+//	   Run().Type("q").Expect("TEA QUIT\n-- view:\n...").
+//	   RunModel(t, myModel)
+//
+// The resulting script can be executed directly with RunModel, or
+// converted to a datadriven test file with String().
+type ScriptBuilder struct {
+	steps []Step
+}
+
+// Script creates a new ScriptBuilder.
+func Script() *ScriptBuilder {
+	return &ScriptBuilder{}
+}
+
+// Run starts a new "run" directive with the given directive
+// arguments, for example Run("observe=(view,gostruct)", "trace").
+func (s *ScriptBuilder) Run(args ...string) *ScriptBuilder {
+	return s.Directive("run", args...)
+}
+
+// Set adds a "set" directive.
+func (s *ScriptBuilder) Set(key, val string) *ScriptBuilder {
+	return s.Directive("set", key+"="+val)
+}
+
+// Reset adds a "reset" directive.
+func (s *ScriptBuilder) Reset(key string) *ScriptBuilder {
+	return s.Directive("reset", key)
+}
+
+// Directive starts a new step with an arbitrary directive.
+func (s *ScriptBuilder) Directive(directive string, args ...string) *ScriptBuilder {
+	line := strings.Join(append([]string{directive}, args...), " ")
+	s.steps = append(s.steps, Step{Directive: line})
+	return s
+}
+
+// Command adds an arbitrary input command to the current step.
+func (s *ScriptBuilder) Command(cmd string, args ...string) *ScriptBuilder {
+	s.cur().Input = append(s.cur().Input, strings.Join(append([]string{cmd}, args...), " "))
+	return s
+}
+
+// Type adds a "type" input command to the current step.
+func (s *ScriptBuilder) Type(text string) *ScriptBuilder {
+	return s.Command("type", text)
+}
+
+// Enter adds an "enter" input command to the current step.
+func (s *ScriptBuilder) Enter(text string) *ScriptBuilder {
+	return s.Command("enter", text)
+}
+
+// Key adds a "key" input command to the current step.
+func (s *ScriptBuilder) Key(keyName string) *ScriptBuilder {
+	return s.Command("key", keyName)
+}
+
+// Paste adds a "paste" input command to the current step.
+func (s *ScriptBuilder) Paste(text string) *ScriptBuilder {
+	return s.Command("paste", strconv.Quote(text))
+}
+
+// Resize adds a "resize" input command to the current step.
+func (s *ScriptBuilder) Resize(width, height int) *ScriptBuilder {
+	return s.Command("resize", strconv.Itoa(width), strconv.Itoa(height))
+}
+
+// Expect sets the expected output for the current step.
+func (s *ScriptBuilder) Expect(output string) *ScriptBuilder {
+	if output != "" && !strings.HasSuffix(output, "\n") {
+		output += "\n"
+	}
+	s.cur().Expected = output
+	return s
+}
+
+// ExpectViewContains adds an expectation that the view observed at
+// the end of the current step contains the given string.
+func (s *ScriptBuilder) ExpectViewContains(substr string) *ScriptBuilder {
+	s.cur().ViewContains = append(s.cur().ViewContains, substr)
+	return s
+}
+
+// Steps returns the steps defined so far.
+func (s *ScriptBuilder) Steps() []Step {
+	return s.steps
+}
+
+func (s *ScriptBuilder) cur() *Step {
+	if len(s.steps) == 0 {
+		panic("no directive defined yet: call Run() first")
+	}
+	return &s.steps[len(s.steps)-1]
+}
+
+// String converts the script to the datadriven file format.
+func (s *ScriptBuilder) String() string {
+	var buf strings.Builder
+	for i := range s.steps {
+		if i > 0 {
+			buf.WriteByte('\n')
+		}
+		writeStep(&buf, &s.steps[i])
+	}
+	return buf.String()
+}
+
+// ExportScripts converts tables of steps, e.g. from table-driven Go
+// tests, into datadriven test files in the given directory. Each
+// table is written to the file named after its key in the map. This
+// makes it possible to migrate programmatic tests to reviewable
+// script files incrementally.
+//
+// The ViewContains expectations cannot be represented in datadriven
+// files; they are preserved as comments before the corresponding
+// directive, for manual conversion. Steps without an expected output
+// can be completed by running the tests with -rewrite.
+func ExportScripts(dir string, tables map[string][]Step) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	names := make([]string, 0, len(tables))
+	for name := range tables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		var buf strings.Builder
+		for i := range tables[name] {
+			st := &tables[name][i]
+			if i > 0 {
+				buf.WriteByte('\n')
+			}
+			for _, substr := range st.ViewContains {
+				fmt.Fprintf(&buf, "# The view must contain %q.\n", substr)
+			}
+			writeStep(&buf, st)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(buf.String()), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeStep(buf *strings.Builder, st *Step) {
+	buf.WriteString(st.Directive)
+	buf.WriteByte('\n')
+	for _, in := range st.Input {
+		buf.WriteString(in)
+		buf.WriteByte('\n')
+	}
+	buf.WriteString("----\n")
+	if strings.Contains(st.Expected, "\n\n") {
+		// The expected output contains a blank line; we need to use
+		// the double separator syntax.
+		buf.WriteString("----\n")
+		buf.WriteString(st.Expected)
+		buf.WriteString("----\n----\n")
+	} else {
+		buf.WriteString(st.Expected)
+	}
+}
+
+// RunModel executes the script on the given model, using a fresh
+// driver initialized via NewDriver and the specified options.
+func (s *ScriptBuilder) RunModel(t *testing.T, m tea.Model, opts ...Option) {
+	t.Helper()
+	d := NewDriver(m, opts...)
+	defer d.Close(t)
+
+	line := 1
+	for i := range s.steps {
+		st := &s.steps[i]
+		pos := fmt.Sprintf("<script>:%d", line)
+		// Compute the position of the next step in the equivalent
+		// datadriven file, for error messages.
+		var b strings.Builder
+		writeStep(&b, st)
+		line += strings.Count(b.String(), "\n") + 1
+
+		cmd, args, err := datadriven.ParseLine(st.Directive)
+		if err != nil {
+			t.Fatalf("%s: %v", pos, err)
+		}
+		td := &datadriven.TestData{
+			Pos:      pos,
+			Cmd:      cmd,
+			CmdArgs:  args,
+			Input:    strings.Join(st.Input, "\n"),
+			Expected: st.Expected,
+		}
+		actual := d.RunOneTest(t, td)
+		if actual != "" && !strings.HasSuffix(actual, "\n") {
+			actual += "\n"
+		}
+		if st.Expected != "" && actual != st.Expected {
+			t.Fatalf("\n%s:\n %s\nexpected:\n%s\nfound:\n%s", pos, td.Input, st.Expected, actual)
+		}
+		if len(st.ViewContains) > 0 {
+			view := d.Observe(t, "view")
+			for _, substr := range st.ViewContains {
+				if !strings.Contains(view, substr) {
+					t.Fatalf("\n%s:\n %s\nexpected view to contain %q, found:\n%s", pos, td.Input, substr, view)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package catwalk
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// TestScriptBuilder checks that a script built in Go can be both
+// executed directly and converted to an equivalent test file.
+func TestScriptBuilder(t *testing.T) {
+	s := Script().
+		Run().Expect("-- view:\nVALUE: 0🛇").
+		Run().Type("ab").ExpectViewContains("VALUE: 2").
+		Run("observe=gostruct").Command("double").Resize(80, 25).
+		Expect("TEA PRINT: {TEST UPDATE CALLED WITH double []}\nTEA WINDOW SIZE: {80 25}\n-- gostruct:\ncatwalk.intModel(5)")
+
+	const expected = `run
+----
+-- view:
+VALUE: 0🛇
+
+run
+type ab
+----
+
+run observe=gostruct
+double
+resize 80 25
+----
+TEA PRINT: {TEST UPDATE CALLED WITH double []}
+TEA WINDOW SIZE: {80 25}
+-- gostruct:
+catwalk.intModel(5)
+`
+	if actual := s.String(); actual != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, actual)
+	}
+
+	s.RunModel(t, intModel(0), WithUpdater(updater))
+
+	// The generated script must have the same behavior when
+	// run as a datadriven file.
+	RunModelFromString(t, Script().
+		Run().Type("ab").Expect("-- view:\nVALUE: 2🛇").
+		Run().Paste("a\nb").Expect("-- view:\nVALUE: 3🛇").String(),
+		intModel(0))
+}
+
+// TestExportScripts checks that tables of steps can be converted
+// to test files which run like the steps.
+func TestExportScripts(t *testing.T) {
+	dir, err := ioutil.TempDir("", "catwalk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tables := map[string][]Step{
+		"typing": {
+			{Directive: "run", Input: []string{"type ab"}, Expected: "-- view:\nVALUE: 2🛇\n"},
+			{Directive: "run observe=gostruct", Input: []string{"double"}, ViewContains: []string{"VALUE: 4"},
+				Expected: "TEA PRINT: {TEST UPDATE CALLED WITH double []}\n-- gostruct:\ncatwalk.intModel(4)\n"},
+		},
+		"empty": {
+			{Directive: "run", Expected: "-- view:\nVALUE: 0🛇\n"},
+		},
+	}
+	if err := ExportScripts(dir, tables); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "typing"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	const expected = `run
+type ab
+----
+-- view:
+VALUE: 2🛇
+
+# The view must contain "VALUE: 4".
+run observe=gostruct
+double
+----
+TEA PRINT: {TEST UPDATE CALLED WITH double []}
+-- gostruct:
+catwalk.intModel(4)
+`
+	if string(data) != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, data)
+	}
+
+	Walk(t, dir, func(testing.TB) (tea.Model, []Option) {
+		return intModel(0), []Option{WithUpdater(updater)}
+	})
+}
@@ -0,0 +1,28 @@
+package catwalk
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func isViewport(typ reflect.Type) bool { return typ == viewportType }
+
+// observeScroll implements the scroll observer: it reports the
+// scroll position of the viewports inside the model, so that
+// scrolling can be checked numerically.
+func observeScroll(buf io.Writer, m tea.Model) error {
+	vps := findComponents(m, isViewport)
+	if len(vps) == 0 {
+		_, err := io.WriteString(buf, "no viewport\n")
+		return err
+	}
+	for _, c := range vps {
+		if _, err := fmt.Fprintf(buf, "%s: %s\n", c.path, summarizeViewport(c.val)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package catwalk
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// scrollModel wraps a viewport in an unexported field.
+type scrollModel struct {
+	vp viewport.Model
+}
+
+func newScrollModel() scrollModel {
+	m := scrollModel{vp: viewport.New(10, 3)}
+	m.vp.SetContent(strings.Repeat("line\n", 10))
+	return m
+}
+
+func (m scrollModel) Init() tea.Cmd { return nil }
+
+func (m scrollModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+	m.vp, cmd = m.vp.Update(msg)
+	return m, cmd
+}
+
+func (m scrollModel) View() string { return m.vp.View() }
+
+func TestScrollObserver(t *testing.T) {
+	RunModel(t, "testdata/scroll", newScrollModel())
+}
+
+func TestFindComponents(t *testing.T) {
+	type inner struct {
+		tea.Model
+		vp *viewport.Model
+	}
+	vp := viewport.New(5, 7)
+	m := struct {
+		tea.Model
+		Pager inner
+		self  *inner
+	}{
+		Model: newScrollModel(),
+		Pager: inner{Model: newScrollModel(), vp: &vp},
+	}
+	// The viewport pointed to by self.vp was already found.
+	m.self = &inner{Model: newScrollModel(), vp: &vp}
+
+	var paths []string
+	var heights []int
+	for _, c := range findComponents(m, isViewport) {
+		paths = append(paths, c.path)
+		heights = append(heights, c.val.(viewport.Model).Height)
+	}
+	expPaths := []string{"Model.vp", "Pager.Model.vp", "Pager.vp", "self.Model.vp"}
+	if !reflect.DeepEqual(paths, expPaths) {
+		t.Errorf("expected %v, got %v", expPaths, paths)
+	}
+	if expHeights := []int{3, 3, 7, 3}; !reflect.DeepEqual(heights, expHeights) {
+		t.Errorf("expected %v, got %v", expHeights, heights)
+	}
+}
@@ -0,0 +1,83 @@
+package catwalk
+
+import (
+	"strconv"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// SenderSetter can be implemented by models which send messages
+// from background goroutines, like they would do with
+// tea.Program.Send. See WithExternalSender().
+type SenderSetter interface {
+	SetSender(send func(tea.Msg))
+}
+
+// externalQueueSize is the number of externally sent messages
+// which can be queued before the senders block.
+const externalQueueSize = 100
+
+// WithExternalSender makes a send function available to the model,
+// which can be used in lieu of tea.Program.Send by background
+// goroutines. The messages sent this way are queued and only
+// delivered to the model by the `wait_msgs` input command, which
+// keeps tests deterministic.
+//
+// If setup is non-nil, it is called with the send function, so that
+// the test can inject it into the model (e.g. when the model is
+// constructed). Otherwise, the model must implement SenderSetter.
+func WithExternalSender(setup func(send func(tea.Msg))) Option {
+	return func(d *driver) {
+		d.externalMsgs = make(chan tea.Msg, externalQueueSize)
+		d.senderSetup = setup
+	}
+}
+
+// setupExternalSender hands the send function to the model.
+// It is called once all the options have been applied.
+func (d *driver) setupExternalSender() {
+	if d.externalMsgs == nil {
+		return
+	}
+	if d.senderSetup != nil {
+		d.senderSetup(d.sendExternal)
+		return
+	}
+	if s, ok := d.m.(SenderSetter); ok {
+		s.SetSender(d.sendExternal)
+	}
+}
+
+// sendExternal queues a message sent from outside of the driver.
+// It blocks if the queue is full, until the messages are
+// consumed by wait_msgs or the driver is closed.
+func (d *driver) sendExternal(msg tea.Msg) {
+	select {
+	case d.externalMsgs <- msg:
+	case <-d.ctx.Done():
+	}
+}
+
+// waitExternalMsgs implements the wait_msgs input command.
+func (d *driver) waitExternalMsgs(t TB, trace bool, args ...string) {
+	if len(args) != 1 {
+		t.Fatalf("%s: syntax: wait_msgs <count>", d.pos)
+	}
+	n, err := strconv.Atoi(args[0])
+	if err != nil || n < 0 {
+		t.Fatalf("%s: invalid message count: %q", d.pos, args[0])
+	}
+	if d.externalMsgs == nil {
+		t.Fatalf("%s: no external sender defined, did you call WithExternalSender()?", d.pos)
+	}
+	for i := 0; i < n; i++ {
+		select {
+		case msg := <-d.externalMsgs:
+			d.trace(trace, "received external msg %T", msg)
+			d.addMsg(msg)
+		case <-time.After(d.cmdTimeout):
+			t.Fatalf("%s: timeout after %s waiting for external message %d of %d", d.pos, d.cmdTimeout, i+1, n)
+		}
+	}
+}
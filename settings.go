@@ -0,0 +1,402 @@
+package catwalk
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/datadriven"
+)
+
+// setting is a configuration parameter of the test driver
+// which can be changed with the set and reset directives.
+type setting struct {
+	// help describes the parameter.
+	help string
+	// def is the default value.
+	def string
+	// get returns the current value.
+	get func(d *driver) string
+	// set changes the value.
+	set func(d *driver, val string) error
+	// save, if set, captures the current value and returns a
+	// function which restores it, for values which do not survive
+	// a round-trip through get and set.
+	save func(d *driver) func(d *driver)
+}
+
+var settings = map[string]setting{
+	"cmd_timeout": {
+		help: "how long to wait for a tea.Cmd to complete",
+		def:  defaultCmdTimeout.String(),
+		get:  func(d *driver) string { return d.cmdTimeout.String() },
+		set: func(d *driver, val string) error {
+			tm, err := time.ParseDuration(val)
+			if err != nil {
+				return err
+			}
+			d.cmdTimeout = tm
+			return nil
+		},
+	},
+	"cmd_timeout_max": {
+		help: "the hard cap up to which slow commands are waited for",
+		def:  "0s",
+		get:  func(d *driver) string { return d.cmdTimeoutMax.String() },
+		set: func(d *driver, val string) error {
+			tm, err := time.ParseDuration(val)
+			if err != nil {
+				return err
+			}
+			d.cmdTimeoutMax = tm
+			return nil
+		},
+	},
+	"sync_cmds": {
+		help: "whether to run commands to completion without a timeout",
+		def:  "off",
+		get:  func(d *driver) string { return fmtBool(d.syncCmds) },
+		set:  func(d *driver, val string) (err error) { d.syncCmds, err = parseBool(val); return err },
+	},
+	"coalesce_keys": {
+		help: "whether to deliver typed text as a single key message",
+		def:  "off",
+		get:  func(d *driver) string { return fmtBool(d.coalesceKeys) },
+		set:  func(d *driver, val string) (err error) { d.coalesceKeys, err = parseBool(val); return err },
+	},
+	"program_conformance": {
+		help: "whether to expand the commands nested in sequences like a bubbletea program",
+		def:  "off",
+		get:  func(d *driver) string { return fmtBool(d.programConformance) },
+		set:  func(d *driver, val string) (err error) { d.programConformance, err = parseBool(val); return err },
+	},
+	"virtual_clock": {
+		help: "whether to defer the tick commands until the virtual time reaches their deadline",
+		def:  "off",
+		get:  func(d *driver) string { return fmtBool(d.virtualClock) },
+		set: func(d *driver, val string) (err error) {
+			if d.virtualClock, err = parseBool(val); err != nil || !d.virtualClock {
+				return err
+			}
+			if err := errTickLayout(); err != nil {
+				d.virtualClock = false
+				return fmt.Errorf("the virtual clock is not supported: %v", err)
+			}
+			return nil
+		},
+	},
+	"alt_screen_resize": {
+		help: "whether to re-send the window size upon alternate screen transitions",
+		def:  "off",
+		get:  func(d *driver) string { return fmtBool(d.altScreenResize) },
+		set:  func(d *driver, val string) (err error) { d.altScreenResize, err = parseBool(val); return err },
+	},
+	"check_update": {
+		help: "whether to check that Update() does not mutate by-value models",
+		def:  "off",
+		get:  func(d *driver) string { return fmtBool(d.checkUpdate) },
+		set:  func(d *driver, val string) (err error) { d.checkUpdate, err = parseBool(val); return err },
+	},
+	"check_view": {
+		help: "whether to check that View() is pure after each observation",
+		def:  "off",
+		get:  func(d *driver) string { return fmtBool(d.checkView) },
+		set:  func(d *driver, val string) (err error) { d.checkView, err = parseBool(val); return err },
+	},
+	"concurrent_view": {
+		help: "whether to call View() concurrently with Update()",
+		def:  "off",
+		get:  func(d *driver) string { return fmtBool(d.concurrentView) },
+		set:  func(d *driver, val string) (err error) { d.concurrentView, err = parseBool(val); return err },
+	},
+	"cmd_stats": {
+		help: "whether to trace command execution statistics",
+		def:  "off",
+		get:  func(d *driver) string { return fmtBool(d.cmdStats) },
+		set:  func(d *driver, val string) (err error) { d.cmdStats, err = parseBool(val); return err },
+	},
+	"strict_updaters": {
+		help: "whether to fail when multiple updaters claim the same command",
+		def:  "off",
+		get:  func(d *driver) string { return fmtBool(d.strictUpdaters) },
+		set:  func(d *driver, val string) (err error) { d.strictUpdaters, err = parseBool(val); return err },
+	},
+	"trace_provenance": {
+		help: "whether to annotate the traced messages with their origin",
+		def:  "off",
+		get:  func(d *driver) string { return fmtBool(d.msgProvenance) },
+		set:  func(d *driver, val string) (err error) { d.msgProvenance, err = parseBool(val); return err },
+	},
+	"trace": {
+		help: "the default tracing mode for run directives (on, off, log)",
+		def:  "off",
+		get:  func(d *driver) string { return d.traceMode },
+		set: func(d *driver, val string) error {
+			if err := checkTraceMode(val); err != nil {
+				return err
+			}
+			d.traceMode = val
+			return nil
+		},
+	},
+	"observe": {
+		help: "the default observers for run directives",
+		def:  defaultObserve,
+		get:  func(d *driver) string { return strings.Join(d.observe, ",") },
+		set:  func(d *driver, val string) error { d.observe = strings.Split(val, ","); return nil },
+	},
+	"max_iterations": {
+		help: "the maximum number of messages and commands processed per run directive (0: unlimited)",
+		def:  strconv.Itoa(defaultMaxIterations),
+		get:  func(d *driver) string { return strconv.Itoa(d.maxIterations) },
+		set: func(d *driver, val string) error {
+			n, err := strconv.Atoi(val)
+			if err != nil {
+				return err
+			}
+			if n < 0 {
+				return fmt.Errorf("negative value: %d", n)
+			}
+			d.maxIterations = n
+			return nil
+		},
+	},
+	"view_budget": {
+		help: "the maximum time a call to View() may take (0: unlimited)",
+		def:  "0s",
+		get:  func(d *driver) string { return d.viewBudget.String() },
+		set: func(d *driver, val string) error {
+			tm, err := time.ParseDuration(val)
+			if err != nil {
+				return err
+			}
+			if tm < 0 {
+				return fmt.Errorf("negative value: %s", tm)
+			}
+			d.viewBudget = tm
+			return nil
+		},
+	},
+	"mem_growth_limit": {
+		help: "the maximum growth in bytes of the estimated size of the model (0: unlimited)",
+		def:  "0",
+		get:  func(d *driver) string { return strconv.FormatInt(d.memGrowthLimit, 10) },
+		set: func(d *driver, val string) error {
+			n, err := strconv.ParseInt(val, 10, 64)
+			if err != nil {
+				return err
+			}
+			if n < 0 {
+				return fmt.Errorf("negative value: %d", n)
+			}
+			d.memGrowthLimit = n
+			return nil
+		},
+	},
+	"newline_marker": {
+		help: "the marker printed at the end of each line in views",
+		def:  defaultNewlineMarker,
+		get:  func(d *driver) string { return d.newlineMarker },
+		set:  func(d *driver, val string) error { d.newlineMarker = val; return nil },
+	},
+	"eof_marker": {
+		help: "the marker printed at the end of views without a final newline",
+		def:  defaultEOFMarker,
+		get:  func(d *driver) string { return d.eofMarker },
+		set:  func(d *driver, val string) error { d.eofMarker = val; return nil },
+	},
+	"ignore_lines": {
+		help: "the regular expressions of the lines ignored when comparing the output",
+		def:  "",
+		get: func(d *driver) string {
+			patterns := make([]string, len(d.ignoreLines))
+			for i, re := range d.ignoreLines {
+				patterns[i] = re.String()
+			}
+			return strings.Join(patterns, ",")
+		},
+		set: func(d *driver, val string) (err error) {
+			var patterns []string
+			if val != "" {
+				patterns = strings.Split(val, ",")
+			}
+			d.ignoreLines, err = compilePatterns(patterns)
+			return err
+		},
+	},
+	"normalize": {
+		help: "the rewrites <regexp>=><replacement> applied to the observer output",
+		def:  "",
+		get: func(d *driver) string {
+			items := make([]string, len(d.outputNormalizers))
+			for i, n := range d.outputNormalizers {
+				items[i] = n.String()
+			}
+			return strings.Join(items, ",")
+		},
+		set: func(d *driver, val string) (err error) {
+			d.outputNormalizers, err = parseOutputNormalizers(val)
+			return err
+		},
+		save: func(d *driver) func(d *driver) {
+			saved := append([]outputNormalizer(nil), d.outputNormalizers...)
+			return func(d *driver) { d.outputNormalizers = append([]outputNormalizer(nil), saved...) }
+		},
+	},
+	"key_layout": {
+		help: "the keyboard layout used to resolve key @<action>",
+		def:  defaultKeyLayout,
+		get: func(d *driver) string {
+			if d.keyLayout == "" {
+				return defaultKeyLayout
+			}
+			return d.keyLayout
+		},
+		set: func(d *driver, val string) error { return d.setKeyLayout(val) },
+	},
+	"keyname_alias": {
+		help: "additional names for special keys, as a list of <alias>:<key>",
+		def:  "",
+		get:  func(d *driver) string { return d.fmtKeyAliases() },
+		set:  func(d *driver, val string) error { return d.setKeyAliases(val) },
+	},
+	"max_output_size": {
+		help: "the maximum size in bytes of the output of a run directive (0: unlimited)",
+		def:  "0",
+		get:  func(d *driver) string { return strconv.Itoa(d.maxOutputSize) },
+		set: func(d *driver, val string) (err error) {
+			d.maxOutputSize, err = strconv.Atoi(val)
+			return err
+		},
+	},
+	"truncate_width": {
+		help: "the display width beyond which observed lines are truncated (0: no limit)",
+		def:  "0",
+		get:  func(d *driver) string { return strconv.Itoa(d.truncateWidth) },
+		set: func(d *driver, val string) (err error) {
+			d.truncateWidth, err = strconv.Atoi(val)
+			return err
+		},
+	},
+	"placeholders": {
+		help: "whether to support [[re]] and ... placeholders in expected output",
+		def:  "off",
+		get:  func(d *driver) string { return fmtBool(d.placeholders) },
+		set:  func(d *driver, val string) (err error) { d.placeholders, err = parseBool(val); return err },
+	},
+	"prints": {
+		help: "whether to report tea.Println messages in the output",
+		def:  "on",
+		get:  func(d *driver) string { return fmtBool(d.showPrints) },
+		set:  func(d *driver, val string) (err error) { d.showPrints, err = parseBool(val); return err },
+	},
+}
+
+func (d *driver) handleSet(t TB, td *datadriven.TestData) string {
+	reset := td.Cmd == "reset"
+	if !reset && (len(td.CmdArgs) == 0 ||
+		(len(td.CmdArgs) == 1 && td.CmdArgs[0].Key == "help" && len(td.CmdArgs[0].Vals) == 0)) {
+		return d.listSettings()
+	}
+	if len(td.CmdArgs) != 1 ||
+		(!reset && len(td.CmdArgs[0].Vals) == 0) ||
+		(reset && len(td.CmdArgs[0].Vals) != 0) {
+		t.Fatalf("%s: invalid syntax", d.pos)
+	}
+	key := td.CmdArgs[0].Key
+	if strings.HasPrefix(key, varPrefix) {
+		return d.handleSetVar(t, key, reset, td.CmdArgs[0].Vals)
+	}
+	s, ok := settings[key]
+	if !ok {
+		t.Fatalf("%s: unknown option %q", d.pos, key)
+	}
+	if reset {
+		// Restore the value configured with the options.
+		if err := d.configured[key](d); err != nil {
+			t.Fatalf("%s: invalid %s value: %v", d.pos, key, err)
+		}
+		return "ok"
+	}
+	if err := s.set(d, strings.Join(td.CmdArgs[0].Vals, ",")); err != nil {
+		t.Fatalf("%s: invalid %s value: %v", d.pos, key, err)
+	}
+	return fmt.Sprintf("%s: %s", key, s.get(d))
+}
+
+// snapshotSettings captures the values of the settings configured
+// with the options, so that the reset directive restores them
+// instead of the defaults.
+func (d *driver) snapshotSettings() {
+	d.configured = make(map[string]func(d *driver) error, len(settings))
+	for key, s := range settings {
+		if s.save != nil {
+			restore := s.save(d)
+			d.configured[key] = func(d *driver) error { restore(d); return nil }
+			continue
+		}
+		val, set := s.get(d), s.set
+		d.configured[key] = func(d *driver) error { return set(d, val) }
+	}
+}
+
+// handleSetVar defines or removes a variable. See WithVariable().
+func (d *driver) handleSetVar(t TB, key string, reset bool, vals []string) string {
+	name := strings.TrimPrefix(key, varPrefix)
+	if !varNameRe.MatchString(name) {
+		t.Fatalf("%s: invalid variable name %q", d.pos, name)
+	}
+	if reset {
+		delete(d.vars, name)
+		return "ok"
+	}
+	d.setVar(name, strings.Join(vals, ","))
+	return fmt.Sprintf("%s: %s", key, d.vars[name])
+}
+
+// listSettings describes the available settings, with their
+// current and default values.
+func (d *driver) listSettings() string {
+	keys := make([]string, 0, len(settings))
+	for k := range settings {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var buf strings.Builder
+	for _, k := range keys {
+		s := settings[k]
+		fmt.Fprintf(&buf, "%s: %s (default %s)\n  %s\n", k, s.get(d), s.def, s.help)
+	}
+	return buf.String()
+}
+
+// parseBool is like strconv.ParseBool but also
+// accepts "on" and "off".
+func parseBool(val string) (bool, error) {
+	switch val {
+	case "on":
+		return true, nil
+	case "off":
+		return false, nil
+	}
+	return strconv.ParseBool(val)
+}
+
+func fmtBool(b bool) string {
+	if b {
+		return "on"
+	}
+	return "off"
+}
+
+// checkTraceMode checks that the argument is a valid value
+// for the trace option.
+func checkTraceMode(mode string) error {
+	switch mode {
+	case "on", "off", "log":
+		return nil
+	}
+	return fmt.Errorf("invalid trace mode %q, expected on, off or log", mode)
+}
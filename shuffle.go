@@ -0,0 +1,66 @@
+package catwalk
+
+import (
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/datadriven"
+)
+
+// independentPrefix marks the input lines which can be shuffled with
+// the shuffle argument of the run directive.
+const independentPrefix = "~"
+
+// shuffleInputs implements the shuffle argument of the run directive:
+// each run of consecutive input lines marked with the "~" prefix is
+// shuffled, so that hidden ordering dependencies between the inputs
+// are flushed out. The prefix is removed from the lines in any case.
+//
+// With shuffle=<seed>, the order is determined by the seed. With
+// shuffle alone, a random seed is chosen and reported in the test
+// log, so that a failure can be reproduced by pinning it.
+func (d *driver) shuffleInputs(t TB, trace bool, lines []string, args []datadriven.CmdArg) []string {
+	var rng *rand.Rand
+	for _, arg := range args {
+		if arg.Key != "shuffle" {
+			continue
+		}
+		var seed int64
+		if len(arg.Vals) > 0 {
+			var err error
+			if seed, err = strconv.ParseInt(arg.Vals[0], 10, 64); err != nil {
+				t.Fatalf("%s: invalid shuffle seed: %v", d.pos, err)
+			}
+		} else {
+			seed = time.Now().UnixNano()
+			t.Logf("%s: shuffling the independent inputs with seed %d; use shuffle=%d to reproduce", d.pos, seed, seed)
+		}
+		rng = rand.New(rand.NewSource(seed))
+	}
+
+	res := make([]string, len(lines))
+	groups := 0
+	for i := 0; i < len(lines); {
+		if !strings.HasPrefix(strings.TrimSpace(lines[i]), independentPrefix) {
+			res[i] = lines[i]
+			i++
+			continue
+		}
+		j := i
+		for ; j < len(lines) && strings.HasPrefix(strings.TrimSpace(lines[j]), independentPrefix); j++ {
+			res[j] = strings.TrimPrefix(strings.TrimSpace(lines[j]), independentPrefix)
+		}
+		if rng != nil && j-i > 1 {
+			group := res[i:j]
+			rng.Shuffle(len(group), func(a, b int) { group[a], group[b] = group[b], group[a] })
+			groups++
+		}
+		i = j
+	}
+	if groups > 0 {
+		d.trace(trace, "shuffled %d groups of independent inputs", groups)
+	}
+	return res
+}
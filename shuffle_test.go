@@ -0,0 +1,79 @@
+package catwalk
+
+import (
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// orderModel shows the keys it has received, in order.
+type orderModel struct{ keys string }
+
+func (m orderModel) Init() tea.Cmd { return nil }
+
+func (m orderModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if k, ok := msg.(tea.KeyMsg); ok {
+		m.keys += k.String()
+	}
+	return m, nil
+}
+
+func (m orderModel) View() string { return m.keys }
+
+func TestShuffleInputs(t *testing.T) {
+	RunModelFromString(t, `
+# Without shuffle, the independent inputs run in order.
+run
+~type a
+~type b
+~type c
+----
+-- view:
+abc🛇
+
+run shuffle=42 trace=on
+~type d
+~type e
+~type f
+type x
+~type g
+----
+-- trace: shuffled 1 groups of independent inputs
+-- trace: before "type f"
+-- trace: after "type"
+-- view:
+abc🛇
+-- trace: before "type d"
+-- trace: processing 1 messages
+-- trace: msg tea.KeyMsg: f
+-- trace: after "type"
+-- view:
+abcf🛇
+-- trace: before "type e"
+-- trace: processing 1 messages
+-- trace: msg tea.KeyMsg: d
+-- trace: after "type"
+-- view:
+abcfd🛇
+-- trace: before "type x"
+-- trace: processing 1 messages
+-- trace: msg tea.KeyMsg: e
+-- trace: after "type"
+-- view:
+abcfde🛇
+-- trace: before "type g"
+-- trace: processing 1 messages
+-- trace: msg tea.KeyMsg: x
+-- trace: after "type"
+-- view:
+abcfdex🛇
+-- trace: before finish
+-- view:
+abcfdex🛇
+-- trace: processing 1 messages
+-- trace: msg tea.KeyMsg: g
+-- trace: at end
+-- view:
+abcfdexg🛇
+`, orderModel{})
+}
@@ -0,0 +1,41 @@
+//go:build go1.21
+// +build go1.21
+
+package catwalk
+
+import (
+	"context"
+	"log/slog"
+)
+
+// WithLogger tells the test driver to send structured diagnostics
+// about its operation to the given logger: directives, calls to
+// Init, messages delivered, commands executed and command timeouts,
+// messages internal to bubbletea that the driver does not know how to
+// handle, and dispatches of input commands to the model updater(s).
+//
+// This is separate from the test output, so that CI logs can capture
+// what happened even when the expected output matches.
+//
+// Command timeouts and unknown messages are reported at level Warn,
+// everything else at level Debug.
+//
+// With versions of Go which do not provide log/slog, WithLogger
+// accepts a Logger instead.
+func WithLogger(logger *slog.Logger) Option {
+	return WithListener(func(ev Event) {
+		r, ok := describeEvent(ev)
+		if !ok {
+			return
+		}
+		level := slog.LevelDebug
+		if r.warn {
+			level = slog.LevelWarn
+		}
+		attrs := make([]slog.Attr, len(r.attrs))
+		for i, a := range r.attrs {
+			attrs[i] = slog.Any(a.key, a.val)
+		}
+		logger.LogAttrs(context.Background(), level, r.msg, attrs...)
+	})
+}
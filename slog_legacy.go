@@ -0,0 +1,60 @@
+//go:build !go1.21
+// +build !go1.21
+
+package catwalk
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Logger is the interface of the loggers accepted by WithLogger with
+// versions of Go which do not provide log/slog. It is implemented by
+// *log.Logger.
+type Logger interface {
+	Printf(format string, args ...interface{})
+}
+
+// WithLogger tells the test driver to send diagnostics about its
+// operation to the given logger: directives, calls to Init, messages
+// delivered, commands executed and command timeouts, messages
+// internal to bubbletea that the driver does not know how to handle,
+// and dispatches of input commands to the model updater(s).
+//
+// This is separate from the test output, so that CI logs can capture
+// what happened even when the expected output matches.
+//
+// The diagnostics are formatted like those of slog.TextHandler.
+// Command timeouts and unknown messages are reported at level WARN,
+// everything else at level DEBUG.
+//
+// With versions of Go which provide log/slog, WithLogger accepts a
+// *slog.Logger instead.
+func WithLogger(logger Logger) Option {
+	return WithListener(func(ev Event) {
+		r, ok := describeEvent(ev)
+		if !ok {
+			return
+		}
+		var buf strings.Builder
+		level := "DEBUG"
+		if r.warn {
+			level = "WARN"
+		}
+		fmt.Fprintf(&buf, "level=%s msg=%s", level, quoteLogValue(r.msg))
+		for _, a := range r.attrs {
+			fmt.Fprintf(&buf, " %s=%s", a.key, quoteLogValue(fmt.Sprint(a.val)))
+		}
+		logger.Printf("%s", buf.String())
+	})
+}
+
+// quoteLogValue quotes the value if it contains spaces or special
+// characters, like slog.TextHandler.
+func quoteLogValue(s string) string {
+	if s == "" || strings.ContainsAny(s, " =\"\n") {
+		return strconv.Quote(s)
+	}
+	return s
+}
@@ -0,0 +1,46 @@
+//go:build !go1.21
+// +build !go1.21
+
+package catwalk
+
+import (
+	"log"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestLogger checks that WithLogger reports the driver diagnostics.
+func TestLogger(t *testing.T) {
+	var buf strings.Builder
+	RunModelFromString(t, `
+run
+type w
+noopcmd
+----
+-- view:
+VALUE: 1🛇
+`, intModel(0), WithUpdater(updater), WithLogger(log.New(&buf, "", 0)),
+		WithCmdStub("Printf.func1", CmdStub{Delay: defaultCmdTimeout}))
+
+	const expected = `level=DEBUG msg=directive-start pos=<string>:2 directive=run
+level=DEBUG msg="calling Init" pos=<string>:2
+level=DEBUG msg="message delivered" pos=<string>:2 type=tea.KeyMsg
+level=DEBUG msg="command dispatched to updater" pos=<string>:2 cmd=noopcmd
+level=WARN msg="command timed out" pos=<string>:2 cmd=github.com/charmbracelet/bubbletea.Printf.func1 latency=LATENCY result=<nil>
+level=DEBUG msg=observation pos=<string>:2 observer=view
+level=DEBUG msg=directive-end pos=<string>:2 directive=run
+`
+	// The latency is not deterministic.
+	actual := buf.String()
+	if i := strings.Index(actual, "latency="); i >= 0 {
+		if j := strings.IndexByte(actual[i:], ' '); j >= 0 {
+			if _, err := time.ParseDuration(actual[i+len("latency=") : i+j]); err == nil {
+				actual = actual[:i] + "latency=LATENCY" + actual[i+j:]
+			}
+		}
+	}
+	if actual != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, actual)
+	}
+}
@@ -0,0 +1,82 @@
+//go:build go1.21
+// +build go1.21
+
+package catwalk
+
+import (
+	"log/slog"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// TestLogger checks that WithLogger reports the driver diagnostics.
+func TestLogger(t *testing.T) {
+	var buf strings.Builder
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			switch a.Key {
+			case slog.TimeKey, "latency":
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+
+	RunModelFromString(t, `
+run
+type w
+noopcmd
+----
+-- view:
+VALUE: 1🛇
+`, intModel(0), WithUpdater(updater), WithLogger(logger),
+		WithCmdStub("Printf.func1", CmdStub{Delay: defaultCmdTimeout}))
+
+	const expected = `level=DEBUG msg=directive-start pos=<string>:2 directive=run
+level=DEBUG msg="calling Init" pos=<string>:2
+level=DEBUG msg="message delivered" pos=<string>:2 type=tea.KeyMsg
+level=DEBUG msg="command dispatched to updater" pos=<string>:2 cmd=noopcmd
+level=WARN msg="command timed out" pos=<string>:2 cmd=github.com/charmbracelet/bubbletea.Printf.func1 result=<nil>
+level=DEBUG msg=observation pos=<string>:2 observer=view
+level=DEBUG msg=directive-end pos=<string>:2 directive=run
+`
+	if actual := buf.String(); actual != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, actual)
+	}
+}
+
+// TestLoggerUnknownMsg checks that WithLogger reports the messages
+// internal to bubbletea that the driver does not know how to handle.
+func TestLoggerUnknownMsg(t *testing.T) {
+	var buf strings.Builder
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+
+	RunModelFromString(t, `
+run
+msg clear
+----
+TEA PRINT: {MODEL INIT}
+TEA PRINT: {MODEL UPDATE}
+-- view:
+MODEL VIEW🛇
+`, emptyModel{}, WithLogger(logger),
+		WithMessageBuilder("clear", func(...string) (tea.Msg, error) {
+			return tea.ClearScrollArea(), nil
+		}))
+
+	const expected = `level=WARN msg="unknown message passed to the model" pos=<string>:2 type=tea.clearScrollAreaMsg
+`
+	if actual := buf.String(); actual != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, actual)
+	}
+}
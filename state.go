@@ -0,0 +1,104 @@
+package catwalk
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"reflect"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// StateCodec serializes model states for the dumpstate and
+// loadstate input commands.
+type StateCodec interface {
+	// Encode writes the serialized form of v to w.
+	Encode(w io.Writer, v interface{}) error
+	// Decode reads a serialized value from r into v, which is a
+	// pointer to a zero value of the model's type.
+	Decode(r io.Reader, v interface{}) error
+}
+
+// JSONCodec serializes model states using encoding/json. Only the
+// exported fields of the model are preserved. This is the default.
+var JSONCodec StateCodec = jsonCodec{}
+
+// GobCodec serializes model states using encoding/gob. Only the
+// exported fields of the model are preserved.
+var GobCodec StateCodec = gobCodec{}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Encode(w io.Writer, v interface{}) error {
+	e := json.NewEncoder(w)
+	e.SetIndent("", "  ")
+	return e.Encode(v)
+}
+
+func (jsonCodec) Decode(r io.Reader, v interface{}) error {
+	return json.NewDecoder(r).Decode(v)
+}
+
+type gobCodec struct{}
+
+func (gobCodec) Encode(w io.Writer, v interface{}) error { return gob.NewEncoder(w).Encode(v) }
+func (gobCodec) Decode(r io.Reader, v interface{}) error { return gob.NewDecoder(r).Decode(v) }
+
+// WithStateCodec changes the serialization used by the dumpstate
+// and loadstate input commands. The default is JSONCodec.
+func WithStateCodec(c StateCodec) Option {
+	return func(d *driver) {
+		d.stateCodec = c
+	}
+}
+
+// dumpState implements the dumpstate input command.
+func (d *driver) dumpState(path string) error {
+	var buf bytes.Buffer
+	if err := d.codec().Encode(&buf, d.m); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, buf.Bytes(), 0644)
+}
+
+// loadState implements the loadstate input command.
+func (d *driver) loadState(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	typ := reflect.TypeOf(d.m)
+	if typ == nil {
+		return fmt.Errorf("cannot load state into a nil model")
+	}
+	byRef := typ.Kind() == reflect.Ptr
+	if byRef {
+		typ = typ.Elem()
+	}
+	v := reflect.New(typ)
+	if err := d.codec().Decode(bytes.NewReader(data), v.Interface()); err != nil {
+		return err
+	}
+	if !byRef {
+		v = v.Elem()
+	}
+	m, ok := v.Interface().(tea.Model)
+	if !ok {
+		return fmt.Errorf("%s does not implement tea.Model", v.Type())
+	}
+	d.m = m
+	d.setupIDGenerator()
+	d.modelUpdated()
+	return nil
+}
+
+// codec returns the configured StateCodec.
+func (d *driver) codec() StateCodec {
+	if d.stateCodec == nil {
+		return JSONCodec
+	}
+	return d.stateCodec
+}
@@ -0,0 +1,96 @@
+package catwalk
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// listModel is a model whose state can be serialized.
+type listModel struct {
+	Items  []string
+	Cursor int
+}
+
+func (listModel) Init() tea.Cmd { return nil }
+func (m *listModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if k, ok := msg.(tea.KeyMsg); ok {
+		switch k.Type {
+		case tea.KeyRunes:
+			m.Items = append(m.Items, string(k.Runes))
+		case tea.KeyDown:
+			m.Cursor++
+		}
+	}
+	return m, nil
+}
+func (m *listModel) View() string {
+	return fmt.Sprintf("items: %s, cursor: %d", strings.Join(m.Items, ","), m.Cursor)
+}
+
+func TestDumpLoadState(t *testing.T) {
+	dir, err := ioutil.TempDir("", "catwalk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, c := range []struct {
+		name  string
+		codec StateCodec
+	}{{"json", JSONCodec}, {"gob", GobCodec}} {
+		t.Run(c.name, func(t *testing.T) {
+			path := filepath.Join(dir, "state."+c.name)
+
+			RunModelFromString(t, fmt.Sprintf(`
+run
+type abc
+key down
+dumpstate %s
+----
+-- view:
+items: a,b,c, cursor: 1🛇
+`, path), &listModel{}, WithStateCodec(c.codec))
+
+			RunModelFromString(t, fmt.Sprintf(`
+run
+loadstate %s
+----
+-- view:
+items: a,b,c, cursor: 1🛇
+
+run
+type d
+----
+-- view:
+items: a,b,c,d, cursor: 1🛇
+`, path), &listModel{}, WithStateCodec(c.codec))
+		})
+	}
+
+	// Models passed by value are supported too.
+	path := filepath.Join(dir, "int.json")
+	RunModelFromString(t, fmt.Sprintf(`
+run
+type abc
+dumpstate %s
+----
+-- view:
+VALUE: 3🛇
+`, path), intModel(0))
+	if data, err := ioutil.ReadFile(path); err != nil || string(data) != "3\n" {
+		t.Errorf("unexpected state: %q, %v", data, err)
+	}
+	RunModelFromString(t, fmt.Sprintf(`
+run
+loadstate %s
+----
+-- view:
+VALUE: 3🛇
+`, path), intModel(10))
+}
@@ -0,0 +1,76 @@
+package catwalk
+
+import (
+	"math/rand"
+	"reflect"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// resizeStorm implements the resize_storm input command: a burst of
+// random window sizes is delivered to the model, followed by a final
+// size. The view must then be the same as if the model had only
+// received the final size, which checks that the layout converges.
+//
+// The syntax is:
+//
+//	resize_storm <n> <minW> <maxW> <minH> <maxH> <seed> [<finalW> <finalH>]
+//
+// The final size defaults to the last window size delivered to the
+// model.
+func (d *driver) resizeStorm(t TB, trace bool, args ...string) {
+	if len(args) != 6 && len(args) != 8 {
+		t.Fatalf("%s: syntax: resize_storm <n> <minW> <maxW> <minH> <maxH> <seed> [<finalW> <finalH>]", d.pos)
+	}
+	n := d.getInt(t, args[0])
+	minW, maxW := d.getInt(t, args[1]), d.getInt(t, args[2])
+	minH, maxH := d.getInt(t, args[3]), d.getInt(t, args[4])
+	seed, err := strconv.ParseInt(args[5], 10, 64)
+	if err != nil {
+		t.Fatalf("%s: invalid seed: %v", d.pos, err)
+	}
+	if n < 1 || minW < 0 || minH < 0 || maxW < minW || maxH < minH {
+		t.Fatalf("%s: resize_storm: invalid parameters", d.pos)
+	}
+	final := tea.WindowSizeMsg{Width: d.width, Height: d.height}
+	if len(args) == 8 {
+		final = tea.WindowSizeMsg{Width: d.getInt(t, args[6]), Height: d.getInt(t, args[7])}
+	} else if !d.sizeKnown {
+		t.Fatalf("%s: resize_storm: no window size known yet, specify <finalW> <finalH>", d.pos)
+	}
+
+	// The reference model only receives the final size.
+	d.processTeaMsgs(trace)
+	var ref tea.Model
+	if v := reflect.ValueOf(d.m); v.IsValid() {
+		ref = deepCopy(v, make(map[visit]copied)).Interface().(tea.Model)
+	}
+
+	d.trace(trace, "resize storm: %d sizes, then %dx%d", n, final.Width, final.Height)
+	rng := rand.New(rand.NewSource(seed))
+	for i := 0; i < n; i++ {
+		d.addMsg(tea.WindowSizeMsg{
+			Width:  minW + rng.Intn(maxW-minW+1),
+			Height: minH + rng.Intn(maxH-minH+1),
+		})
+	}
+	d.addMsg(final)
+	d.processTeaMsgs(trace)
+	d.processTeaCmds(trace)
+	d.processTeaMsgs(trace)
+
+	if ref == nil {
+		return
+	}
+	ref, _ = ref.Update(final)
+	if expected, actual := ref.View(), d.m.View(); expected != actual {
+		var buf strings.Builder
+		for _, l := range lineDiff(expected, actual) {
+			buf.WriteString("\n" + l.Op + " " + l.Text)
+		}
+		t.Fatalf("%s: resize_storm: the view differs from the view after a single resize to %dx%d:%s",
+			d.pos, final.Width, final.Height, buf.String())
+	}
+}
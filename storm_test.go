@@ -0,0 +1,85 @@
+package catwalk
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/cockroachdb/datadriven"
+)
+
+// layoutModel renders a box which fills the window. When sticky is
+// set, the box never shrinks, which is a layout bug.
+type layoutModel struct {
+	sticky bool
+	w, h   int
+}
+
+func (m layoutModel) Init() tea.Cmd { return nil }
+
+func (m layoutModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if sz, ok := msg.(tea.WindowSizeMsg); ok {
+		if !m.sticky || sz.Width > m.w {
+			m.w = sz.Width
+		}
+		m.h = sz.Height
+	}
+	return m, nil
+}
+
+func (m layoutModel) View() string {
+	return fmt.Sprintf("%dx%d\n%s", m.w, m.h, strings.Repeat("#", m.w))
+}
+
+func TestResizeStorm(t *testing.T) {
+	RunModelFromString(t, `
+run trace=on
+resize 4 2
+resize_storm 3 1 10 1 10 42
+----
+-- trace: calling Init
+-- trace: before "resize 4 2"
+-- trace: after "resize"
+-- view:
+0x0␤
+-- trace: before "resize_storm 3 1 10 1 10 42"
+-- trace: processing 1 messages
+-- trace: msg tea.WindowSizeMsg{Width:4, Height:2}
+TEA WINDOW SIZE: {4 2}
+-- trace: resize storm: 3 sizes, then 4x2
+-- trace: processing 4 messages
+-- trace: msg tea.WindowSizeMsg{Width:6, Height:8}
+TEA WINDOW SIZE: {6 8}
+-- trace: msg tea.WindowSizeMsg{Width:9, Height:1}
+TEA WINDOW SIZE: {9 1}
+-- trace: msg tea.WindowSizeMsg{Width:4, Height:6}
+TEA WINDOW SIZE: {4 6}
+-- trace: msg tea.WindowSizeMsg{Width:4, Height:2}
+TEA WINDOW SIZE: {4 2}
+-- trace: after "resize_storm"
+-- view:
+4x2␤
+####🛇
+-- trace: before finish
+-- view:
+4x2␤
+####🛇
+-- trace: at end
+-- view:
+4x2␤
+####🛇
+`, layoutModel{})
+
+	d := NewDriver(layoutModel{sticky: true})
+	defer d.Close(t)
+	fatal := expectFatal(t, d, &datadriven.TestData{Pos: "test:1", Cmd: "run", Input: "resize_storm 3 1 10 1 10 42 4 2"})
+	exp := `test:1: resize_storm: the view differs from the view after a single resize to 4x2:
+- 4x2
+- ####
++ 9x2
++ #########`
+	if fatal != exp {
+		t.Errorf("expected:\n%s\ngot:\n%s", exp, fatal)
+	}
+}
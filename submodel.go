@@ -0,0 +1,146 @@
+package catwalk
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// SubModelAccessor extracts a child component from a composite model.
+// See WithSubModel().
+type SubModelAccessor func(m tea.Model) tea.Model
+
+// WithSubModel registers a child component of the model under the
+// given name, using a function that extracts it from the model.
+// This makes it possible to target the child in tests:
+//
+//   - observe=view@name (and similarly for the other observers
+//     registered with WithObserver) observes the child instead of
+//     the top-level model.
+//   - the input command "to name <cmd...>" delivers the messages
+//     produced by the input command directly to the child's Update
+//     method, bypassing the parent's.
+//
+//   - the input command prefix "name:", as in "name: <cmd...>", is
+//     equivalent to "to name <cmd...>".
+//
+// When the child is routed messages, the model returned by its
+// Update method is discarded unless a setter is registered with
+// WithSubModelSetter(); without a setter, the changes are thus
+// only visible if the child is implemented by reference, and
+// routing messages to a child implemented by value fails the test.
+func WithSubModel(name string, get SubModelAccessor) Option {
+	return func(d *driver) {
+		d.subModel(name).get = get
+	}
+}
+
+// SubModelSetter reassembles a composite model after its child
+// component was updated. It returns the new composite model.
+// See WithSubModelSetter().
+type SubModelSetter func(m, child tea.Model) tea.Model
+
+// WithSubModelSetter registers the function used to reassemble the
+// model after the messages routed to the child component registered
+// with WithSubModel() under the same name have been processed by the
+// child's Update method. This makes it possible to route messages to
+// children implemented by value.
+func WithSubModelSetter(name string, set SubModelSetter) Option {
+	return func(d *driver) {
+		d.subModel(name).set = set
+	}
+}
+
+// subModel is a child component registered with WithSubModel.
+type subModel struct {
+	name string
+	get  SubModelAccessor
+	set  SubModelSetter
+}
+
+// subModel returns the sub-model registered with the given name,
+// creating it if needed.
+func (d *driver) subModel(name string) *subModel {
+	if d.subModels == nil {
+		d.subModels = make(map[string]*subModel)
+	}
+	sub, ok := d.subModels[name]
+	if !ok {
+		sub = &subModel{name: name}
+		d.subModels[name] = sub
+	}
+	return sub
+}
+
+// getSubModel returns the sub-model registered with the given name.
+func (d *driver) getSubModel(t TB, name string) *subModel {
+	sub, ok := d.subModels[name]
+	if !ok || sub.get == nil {
+		names := make([]string, 0, len(d.subModels))
+		for n, s := range d.subModels {
+			if s.get != nil {
+				names = append(names, n)
+			}
+		}
+		sort.Strings(names)
+		if sugg := didYouMean(name, names); sugg != "" {
+			t.Fatalf("%s: unknown sub-model %q%s", d.pos, name, sugg)
+		}
+		t.Fatalf("%s: unknown sub-model %q, did you call WithSubModel()?", d.pos, name)
+	}
+	return sub
+}
+
+// splitSubModel splits an observer name of the form obs@name.
+func splitSubModel(what string) (obs, name string, ok bool) {
+	idx := strings.LastIndexByte(what, '@')
+	if idx < 0 {
+		return what, "", false
+	}
+	return what[:idx], what[idx+1:], true
+}
+
+// subModelPrefix returns the name of the sub-model if the input
+// command is a prefix of the form "name:".
+func subModelPrefix(cmd string) (name string, ok bool) {
+	if len(cmd) < 2 || !strings.HasSuffix(cmd, ":") {
+		return "", false
+	}
+	return cmd[:len(cmd)-1], true
+}
+
+// applyToSubModel implements the "to" input command: the messages
+// produced by the input command are delivered to the sub-model.
+func (d *driver) applyToSubModel(t TB, trace bool, args ...string) {
+	if len(args) < 2 {
+		t.Fatalf("%s: syntax: to <submodel> <cmd> <args...>", d.pos)
+	}
+	sub := d.getSubModel(t, args[0])
+	if sub.set == nil && reflect.ValueOf(sub.get(d.m)).Kind() != reflect.Ptr {
+		// The result of the child's Update would be lost.
+		t.Fatalf("%s: sub-model %q is implemented by value, did you call WithSubModelSetter()?", d.pos, sub.name)
+	}
+	d.trace(trace, "routing to sub-model %q", sub.name)
+	teaCmd := d.ApplyTextCommand(t, args[1], args[2:]...)
+	d.target = sub
+	d.processTeaMsgs(trace)
+	d.target = nil
+	d.addCmds(teaCmd)
+	d.processTeaCmds(trace)
+}
+
+// updateSubModel delivers a message to the sub-model targeted
+// by the "to" input command.
+func (d *driver) updateSubModel(msg tea.Msg) {
+	d.updateCalls++
+	child := d.target.get(d.m)
+	newChild, newCmd := d.callUpdate(d.m, func() (tea.Model, tea.Cmd) { return child.Update(msg) })
+	if d.target.set != nil {
+		d.m = d.target.set(d.m, newChild)
+	}
+	d.modelUpdated()
+	d.lastCmd = newCmd
+	d.addCmds(newCmd)
+}
@@ -0,0 +1,103 @@
+package catwalk
+
+import (
+	"fmt"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/cockroachdb/datadriven"
+)
+
+// compositeModel is a model containing a child component.
+type compositeModel struct {
+	n     int
+	child *structModel
+}
+
+func (compositeModel) Init() tea.Cmd { return nil }
+func (m compositeModel) Update(tea.Msg) (tea.Model, tea.Cmd) {
+	m.n++
+	return m, nil
+}
+func (m compositeModel) View() string { return fmt.Sprintf("parent: %d, child: %s", m.n, m.child.View()) }
+
+func TestSubModel(t *testing.T) {
+	const test = `
+run observe=(view,view@child,gostruct@child)
+type a
+----
+-- view:
+parent: 1, child: VALUE: '\x00'🛇
+-- view@child:
+VALUE: '\x00'🛇
+-- gostruct@child:
+&catwalk.structModel{}
+
+run observe=(view,view@child,counters)
+to child type ab
+----
+-- view:
+parent: 1, child: VALUE: '\x02'🛇
+-- view@child:
+VALUE: '\x02'🛇
+-- counters:
+update: 2
+view: 2
+`
+	RunModelFromString(t, test, compositeModel{child: &structModel{}},
+		WithSubModel("child", func(m tea.Model) tea.Model { return m.(compositeModel).child }))
+}
+
+// valueCompositeModel is a model containing a child
+// component implemented by value.
+type valueCompositeModel struct {
+	n     int
+	child intModel
+}
+
+func (valueCompositeModel) Init() tea.Cmd { return nil }
+func (m valueCompositeModel) Update(tea.Msg) (tea.Model, tea.Cmd) {
+	m.n++
+	return m, nil
+}
+func (m valueCompositeModel) View() string {
+	return fmt.Sprintf("parent: %d, child: %s", m.n, m.child.View())
+}
+
+func TestSubModelSetter(t *testing.T) {
+	const test = `
+run
+type a
+child: type bc
+----
+-- view:
+parent: 1, child: VALUE: 2🛇
+
+run
+to child key down
+----
+-- view:
+parent: 1, child: VALUE: 3🛇
+`
+	RunModelFromString(t, test, valueCompositeModel{},
+		WithSubModelSetter("child", func(m, child tea.Model) tea.Model {
+			vm := m.(valueCompositeModel)
+			vm.child = child.(intModel)
+			return vm
+		}),
+		WithSubModel("child", func(m tea.Model) tea.Model { return m.(valueCompositeModel).child }))
+}
+
+// TestSubModelByValue checks that routing messages to a sub-model
+// implemented by value without a setter fails the test, since the
+// result of its Update method would be lost.
+func TestSubModelByValue(t *testing.T) {
+	d := NewDriver(valueCompositeModel{},
+		WithSubModel("child", func(m tea.Model) tea.Model { return m.(valueCompositeModel).child }))
+	defer d.Close(t)
+	fatal := expectFatal(t, d, &datadriven.TestData{Pos: "test:1", Cmd: "run", Input: "to child type a"})
+	const expected = `test:1: sub-model "child" is implemented by value, did you call WithSubModelSetter()?`
+	if fatal != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, fatal)
+	}
+}
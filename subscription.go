@@ -0,0 +1,57 @@
+package catwalk
+
+import (
+	"strconv"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Subscription is a tea.Msg which the test driver recognizes as a
+// long-running source of messages, e.g. a log tail or an event
+// stream. When a tea.Cmd returns a Subscription, the driver does not
+// deliver it to the model; instead it registers the channel, and
+// the `pump` input command pulls messages from it.
+//
+// See also Subscribe().
+type Subscription <-chan tea.Msg
+
+// Subscribe returns a tea.Cmd which returns a Subscription for the
+// given channel.
+func Subscribe(ch <-chan tea.Msg) tea.Cmd {
+	return func() tea.Msg { return Subscription(ch) }
+}
+
+// pumpSubscriptions implements the pump input command. It pulls the
+// messages from the oldest subscription first; subscriptions whose
+// channel is closed are dropped.
+func (d *driver) pumpSubscriptions(t TB, trace bool, args ...string) {
+	if len(args) != 1 {
+		t.Fatalf("%s: syntax: pump <count>", d.pos)
+	}
+	n, err := strconv.Atoi(args[0])
+	if err != nil || n < 0 {
+		t.Fatalf("%s: invalid message count: %q", d.pos, args[0])
+	}
+	// Run the pending commands first, so that the subscriptions
+	// they produce are registered.
+	d.processTeaCmds(trace)
+	for i := 0; i < n; {
+		if len(d.subscriptions) == 0 {
+			t.Fatalf("%s: no active subscription to pump message %d of %d from", d.pos, i+1, n)
+		}
+		select {
+		case msg, ok := <-d.subscriptions[0]:
+			if !ok {
+				d.trace(trace, "subscription closed")
+				d.subscriptions = d.subscriptions[1:]
+				continue
+			}
+			d.trace(trace, "pumped msg %T", msg)
+			d.addMsg(msg)
+			i++
+		case <-time.After(d.cmdTimeout):
+			t.Fatalf("%s: timeout after %s waiting for subscription message %d of %d", d.pos, d.cmdTimeout, i+1, n)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package catwalk
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// didYouMean returns a suggestion to append to an error message
+// about the unknown name, listing the closest candidates. It
+// returns the empty string if no candidate is close enough.
+func didYouMean(name string, candidates []string) string {
+	matches := closestMatches(name, candidates)
+	if len(matches) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" (did you mean %s?)", strings.Join(quoteAll(matches), " or "))
+}
+
+// builtinObservers are the observers implemented
+// directly in the driver.
+var builtinObservers = []string{"msgs", "cmds", "initcmds", "counters", "screen", "help", "focus"}
+
+// observerNames returns the names of the supported observers.
+func (d *driver) observerNames() []string {
+	names := append([]string(nil), builtinObservers...)
+	for name := range d.observers {
+		names = append(names, name)
+	}
+	return names
+}
+
+// builtinCommands are the input commands implemented
+// directly in the driver.
+var builtinCommands = []string{
+	"resize", "key", "mouse", "type", "enter", "typefile", "paste", "senderr", "msg",
+	"with_timeout", "wait_msgs", "pump", "to", "wait_for", "wait_init",
+	"after", "advance", "process", "step", "defer_processing",
+	"break_on", "dumpstate", "loadstate", "expect_cmd", "expect_no_cmd",
+	"expect_any_of", "reset_ids", "fill", "freeze", "unfreeze",
+	"resize_storm", "normalize", "focus", "blurcomponent",
+}
+
+// commandNames returns the names of the supported input
+// commands.
+func (d *driver) commandNames() []string {
+	return append(append([]string(nil), builtinCommands...), d.updaterCmds...)
+}
+
+// keyNames returns the names of the special keys
+// supported by the key command.
+func keyNames() []string {
+	names := make([]string, 0, len(allKeys))
+	for name := range allKeys {
+		names = append(names, name)
+	}
+	return names
+}
+
+// keyNames returns the names of the special keys supported by the
+// key command, including the aliases configured for the driver.
+func (d *driver) keyNames() []string {
+	names := keyNames()
+	for alias := range d.keyAliases {
+		names = append(names, alias)
+	}
+	return names
+}
+
+// maxSuggestions is the maximum number of candidates
+// reported by didYouMean.
+const maxSuggestions = 3
+
+// closestMatches returns the candidates closest to name, provided
+// they are within a small edit distance.
+func closestMatches(name string, candidates []string) []string {
+	// Allow roughly one typo per 3 characters, and at least one.
+	maxDist := len(name)/3 + 1
+	type match struct {
+		cand string
+		dist int
+	}
+	var matches []match
+	seen := make(map[string]bool, len(candidates))
+	for _, c := range candidates {
+		if seen[c] || c == name {
+			continue
+		}
+		seen[c] = true
+		if d := editDistance(name, c); d <= maxDist {
+			matches = append(matches, match{c, d})
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].dist != matches[j].dist {
+			return matches[i].dist < matches[j].dist
+		}
+		return matches[i].cand < matches[j].cand
+	})
+	// Only report the closest candidates.
+	n := 0
+	for n < len(matches) && n < maxSuggestions && matches[n].dist == matches[0].dist {
+		n++
+	}
+	matches = matches[:n]
+	res := make([]string, len(matches))
+	for i, m := range matches {
+		res[i] = m.cand
+	}
+	return res
+}
+
+// editDistance computes the Damerau-Levenshtein distance (with
+// adjacent transpositions) between a and b.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	// d[i][j] is the distance between ra[:i] and rb[:j].
+	d := make([][]int, len(ra)+1)
+	for i := range d {
+		d[i] = make([]int, len(rb)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			d[i][j] = min3(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] && d[i-2][j-2]+1 < d[i][j] {
+				d[i][j] = d[i-2][j-2] + 1
+			}
+		}
+	}
+	return d[len(ra)][len(rb)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+func quoteAll(s []string) []string {
+	res := make([]string, len(s))
+	for i, v := range s {
+		res[i] = fmt.Sprintf("%q", v)
+	}
+	return res
+}
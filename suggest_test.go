@@ -0,0 +1,66 @@
+package catwalk
+
+import (
+	"testing"
+
+	"github.com/cockroachdb/datadriven"
+)
+
+func TestDidYouMean(t *testing.T) {
+	candidates := []string{"observe", "trace", "type", "enter", "key"}
+	testData := []struct {
+		name     string
+		expected string
+	}{
+		{"obsreve", ` (did you mean "observe"?)`},
+		{"tpye", ` (did you mean "type"?)`},
+		{"kye", ` (did you mean "key"?)`},
+		{"trac", ` (did you mean "trace"?)`},
+		{"xyzzy", ``},
+		{"observe", ``},
+	}
+	for _, tc := range testData {
+		if actual := didYouMean(tc.name, candidates); actual != tc.expected {
+			t.Errorf("%s: expected %q, got %q", tc.name, tc.expected, actual)
+		}
+	}
+}
+
+func TestRunArgsValidation(t *testing.T) {
+	d := NewDriver(emptyModel{})
+	defer d.Close(t)
+	fatal := expectFatal(t, d, &datadriven.TestData{Pos: "test:1", Cmd: "run",
+		CmdArgs: []datadriven.CmdArg{{Key: "obsreve", Vals: []string{"gostruct"}}}})
+	const expected = `test:1: unknown argument "obsreve" for run (did you mean "observe"?)`
+	if fatal != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, fatal)
+	}
+}
+
+func TestSuggestions(t *testing.T) {
+	runTest := func(input string, cmdArgs ...datadriven.CmdArg) string {
+		d := NewDriver(intModel(0), WithUpdater(updater), WithUpdaterCommands("double", "noopcmd"),
+			WithObserver("hello", observeGoStruct))
+		defer d.Close(t)
+		return expectFatal(&logTB{TB: t}, d, &datadriven.TestData{Pos: "test:1", Cmd: "run", Input: input, CmdArgs: cmdArgs})
+	}
+
+	testData := []struct {
+		input    string
+		cmdArgs  []datadriven.CmdArg
+		expected string
+	}{
+		{"tpye a", nil, `test:1: unknown command "tpye" (did you mean "type"?)`},
+		{"doubel", nil, `test:1: unknown command "doubel" (did you mean "double"?)`},
+		{"key entr", nil, `test:1: unknown key: entr (did you mean "enter"?)`},
+		{"", []datadriven.CmdArg{{Key: "observe", Vals: []string{"hallo"}}},
+			`test:1: unsupported observer "hallo" (did you mean "hello"?)`},
+		{"", []datadriven.CmdArg{{Key: "observe", Vals: []string{"xyzzy"}}},
+			`test:1: unsupported observer "xyzzy", did you call WithObserver()?`},
+	}
+	for _, tc := range testData {
+		if actual := runTest(tc.input, tc.cmdArgs...); actual != tc.expected {
+			t.Errorf("%s: expected:\n%s\ngot:\n%s", tc.input, tc.expected, actual)
+		}
+	}
+}
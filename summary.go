@@ -0,0 +1,81 @@
+package catwalk
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/datadriven"
+)
+
+// summaryEnvVar is the environment variable which configures the
+// path of the JSON summary written by Walk.
+const summaryEnvVar = "CATWALK_SUMMARY_FILE"
+
+// WalkSummary describes a run of Walk or WalkShard. It is written as
+// JSON to the file named by the environment variable
+// CATWALK_SUMMARY_FILE, if set, so that dashboards can track the
+// health of a test suite over time.
+type WalkSummary struct {
+	// Path is the directory passed to Walk.
+	Path string `json:"path"`
+	// ShardIndex and ShardTotal describe the shard that was run.
+	ShardIndex int `json:"shard_index"`
+	ShardTotal int `json:"shard_total"`
+	// Duration is the wall time spent running the test files.
+	Duration time.Duration `json:"duration_ns"`
+	// Files describes the test files, in the order they were run.
+	Files []FileSummary `json:"files"`
+}
+
+// FileSummary describes the run of one test file in a WalkSummary.
+type FileSummary struct {
+	// Path is the path to the test file.
+	Path string `json:"path"`
+	// Skipped is set when the file is outside of the shard.
+	Skipped bool `json:"skipped,omitempty"`
+	// Failed is set when the test for the file failed.
+	Failed bool `json:"failed"`
+	// Directives is the number of directives run.
+	Directives int `json:"directives"`
+	// Failures lists the positions of the directives whose output
+	// did not match the expected output, or which could not
+	// complete.
+	Failures []string `json:"failures,omitempty"`
+	// Duration is the wall time spent running the file.
+	Duration time.Duration `json:"duration_ns"`
+}
+
+// withFileSummary tells the test driver to account for the
+// directives it runs in the given summary.
+func withFileSummary(fs *FileSummary) Option {
+	return func(d *driver) {
+		d.fileSummary = fs
+	}
+}
+
+// recordSummary accounts for a directive in the file summary.
+func (d *driver) recordSummary(td *datadriven.TestData, output string) {
+	if d.fileSummary == nil {
+		return
+	}
+	d.fileSummary.Directives++
+	if !outputMatches(output, td.Expected) && !rewriting() {
+		d.fileSummary.Failures = append(d.fileSummary.Failures, td.Pos)
+	}
+}
+
+// writeWalkSummary writes the summary to the file named by
+// CATWALK_SUMMARY_FILE.
+func writeWalkSummary(t *testing.T, s *WalkSummary) {
+	path := os.Getenv(summaryEnvVar)
+	j, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, j, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
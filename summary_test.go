@@ -0,0 +1,49 @@
+package catwalk
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestWalkSummary(t *testing.T) {
+	dir, err := ioutil.TempDir("", "catwalk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	path := filepath.Join(dir, "summary.json")
+	if err := os.Setenv(summaryEnvVar, path); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.Unsetenv(summaryEnvVar) }()
+
+	t.Run("walk", func(t *testing.T) {
+		WalkShard(t, "testdata/walk", 1, 2, func(t testing.TB) (tea.Model, []Option) {
+			return intModel(0), []Option{WithUpdater(updater)}
+		})
+	})
+
+	j, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var s WalkSummary
+	if err := json.Unmarshal(j, &s); err != nil {
+		t.Fatal(err)
+	}
+	if s.Path != "testdata/walk" || s.ShardIndex != 1 || s.ShardTotal != 2 || len(s.Files) != 2 {
+		t.Fatalf("unexpected summary: %s", j)
+	}
+	if f := s.Files[0]; f.Path != "testdata/walk/first" || !f.Skipped || f.Directives != 0 {
+		t.Errorf("unexpected summary for the first file: %+v", f)
+	}
+	if f := s.Files[1]; f.Path != "testdata/walk/second" || f.Skipped || f.Failed ||
+		f.Directives != 2 || len(f.Failures) != 0 || f.Duration <= 0 {
+		t.Errorf("unexpected summary for the second file: %+v", f)
+	}
+}
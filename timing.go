@@ -0,0 +1,124 @@
+package catwalk
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Timing records how long a test directive or an input command took
+// to process. See WithTimingSummary() and WithTimingJSON().
+type Timing struct {
+	// Pos is the position of the directive in the test input.
+	Pos string `json:"pos"`
+	// Directive is the name of the directive.
+	Directive string `json:"directive"`
+	// Command is the input command with its arguments, or empty if
+	// this is the timing for the directive as a whole.
+	Command string `json:"command,omitempty"`
+	// Index is the position of the input command in the directive,
+	// starting at 1, or 0 for the directive as a whole. This
+	// distinguishes the commands of the same directive.
+	Index int `json:"index,omitempty"`
+	// Duration is the wall time spent.
+	Duration time.Duration `json:"duration_ns"`
+}
+
+// WithTimingSummary tells the test driver to record how long each
+// directive and each input command takes, and to report the slowest
+// ones in the test log when the driver is closed.
+func WithTimingSummary() Option {
+	return func(d *driver) {
+		d.recordTimings = true
+		d.timingSummary = true
+	}
+}
+
+// WithTimingJSON tells the test driver to record how long each
+// directive and each input command takes, and to write the
+// timings as a JSON array to the given file when the driver
+// is closed.
+func WithTimingJSON(path string) Option {
+	return func(d *driver) {
+		d.recordTimings = true
+		d.timingPath = path
+	}
+}
+
+// numSlowestTimings is the number of directives and commands
+// reported in the timing summary.
+const numSlowestTimings = 5
+
+// recordTiming records the time spent since start in the
+// directive.
+func (d *driver) recordTiming(directive string, start time.Time) {
+	d.recordCmdTiming(directive, 0, "", start)
+}
+
+// recordCmdTiming records the time spent since start in the input
+// command at the given position in the directive.
+func (d *driver) recordCmdTiming(directive string, index int, command string, start time.Time) {
+	if !d.recordTimings {
+		return
+	}
+	d.timings = append(d.timings, Timing{
+		Pos:       d.pos,
+		Directive: directive,
+		Command:   command,
+		Index:     index,
+		Duration:  time.Since(start),
+	})
+}
+
+// reportTimings produces the timing report(s) configured for the
+// driver.
+func (d *driver) reportTimings(t TB) {
+	if d.timingSummary {
+		t.Logf("%s", formatTimingSummary(d.timings))
+	}
+	if d.timingPath != "" {
+		j, err := json.MarshalIndent(d.timings, "", "  ")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(d.timingPath, j, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func formatTimingSummary(timings []Timing) string {
+	var directives, commands []Timing
+	var total time.Duration
+	for _, tm := range timings {
+		if tm.Command == "" {
+			directives = append(directives, tm)
+			total += tm.Duration
+		} else {
+			commands = append(commands, tm)
+		}
+	}
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "timing summary: %d directives, %d input commands, total %s\n",
+		len(directives), len(commands), total)
+	report := func(what string, tms []Timing) {
+		sort.SliceStable(tms, func(i, j int) bool { return tms[i].Duration > tms[j].Duration })
+		if len(tms) > numSlowestTimings {
+			tms = tms[:numSlowestTimings]
+		}
+		fmt.Fprintf(&buf, "slowest %s:\n", what)
+		for _, tm := range tms {
+			fmt.Fprintf(&buf, "  %s: %s", tm.Pos, tm.Directive)
+			if tm.Command != "" {
+				fmt.Fprintf(&buf, " / #%d %s", tm.Index, tm.Command)
+			}
+			fmt.Fprintf(&buf, ": %s\n", tm.Duration)
+		}
+	}
+	report("directives", directives)
+	report("input commands", commands)
+	return buf.String()
+}
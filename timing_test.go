@@ -0,0 +1,67 @@
+package catwalk
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cockroachdb/datadriven"
+)
+
+// TestTimings checks the timing report options.
+func TestTimings(t *testing.T) {
+	dir, err := ioutil.TempDir("", "catwalk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	path := filepath.Join(dir, "timings.json")
+
+	lt := &logTB{TB: t}
+	d := NewDriver(emptyModel{}, WithTimingSummary(), WithTimingJSON(path))
+	d.RunOneTest(lt, &datadriven.TestData{
+		Pos:     "test:1",
+		Cmd:     "set",
+		CmdArgs: []datadriven.CmdArg{{Key: "cmd_timeout", Vals: []string{"10ms"}}},
+	})
+	d.RunOneTest(lt, &datadriven.TestData{
+		Pos:   "test:2",
+		Cmd:   "run",
+		Input: "type a\nkey enter",
+	})
+	d.Close(lt)
+
+	summary := strings.Join(lt.logs, "\n")
+	for _, expected := range []string{
+		"timing summary: 2 directives, 2 input commands",
+		"slowest directives:\n",
+		"  test:1: set: ",
+		"slowest input commands:\n",
+		"  test:2: run / #2 key enter: ",
+	} {
+		if !strings.Contains(summary, expected) {
+			t.Errorf("expected %q in summary, got:\n%s", expected, summary)
+		}
+	}
+
+	j, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var timings []Timing
+	if err := json.Unmarshal(j, &timings); err != nil {
+		t.Fatal(err)
+	}
+	var actual []string
+	for _, tm := range timings {
+		actual = append(actual, fmt.Sprintf("%s/%d/%s", tm.Directive, tm.Index, tm.Command))
+	}
+	const expected = "set/0/ run/1/type a run/2/key enter run/0/"
+	if strings.Join(actual, " ") != expected {
+		t.Errorf("expected %s, got %v", expected, actual)
+	}
+}
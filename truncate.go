@@ -0,0 +1,52 @@
+package catwalk
+
+import (
+	"strings"
+
+	"github.com/muesli/reflow/ansi"
+	"github.com/muesli/reflow/truncate"
+)
+
+// truncationMarker is appended to the lines shortened by
+// WithTruncatedLines.
+const truncationMarker = "…"
+
+// WithTruncatedLines tells the test driver to truncate the lines of
+// observations wider than the given number of display columns,
+// replacing the end of the line by an ellipsis. The width accounts
+// for wide characters and ignores ANSI escape sequences; the
+// newline and end-of-view markers are preserved.
+//
+// This is useful when only the leading content of very wide rows
+// matters, e.g. tables with long URLs, to keep golden files small
+// and stable. This can also be changed with `set truncate_width`.
+func WithTruncatedLines(width int) Option {
+	return func(d *driver) {
+		d.truncateWidth = width
+	}
+}
+
+// truncateLines implements the truncation configured by
+// WithTruncatedLines.
+func (d *driver) truncateLines(s string) string {
+	if d.truncateWidth <= 0 {
+		return s
+	}
+	lines := strings.SplitAfter(s, "\n")
+	for i, line := range lines {
+		body := strings.TrimSuffix(line, "\n")
+		suffix := line[len(body):]
+		for _, marker := range []string{d.newlineMarker, d.eofMarker} {
+			if marker != "" && strings.HasSuffix(body, marker) {
+				body = strings.TrimSuffix(body, marker)
+				suffix = marker + suffix
+				break
+			}
+		}
+		if ansi.PrintableRuneWidth(body) <= d.truncateWidth {
+			continue
+		}
+		lines[i] = truncate.StringWithTail(body, uint(d.truncateWidth), truncationMarker) + suffix
+	}
+	return strings.Join(lines, "")
+}
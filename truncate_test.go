@@ -0,0 +1,41 @@
+package catwalk
+
+import (
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// wideModel has a view with wide lines.
+type wideModel struct{}
+
+func (wideModel) Init() tea.Cmd                         { return nil }
+func (m wideModel) Update(tea.Msg) (tea.Model, tea.Cmd) { return m, nil }
+func (wideModel) View() string {
+	return "short\n0123456789abcdef\n日本語のテキスト\n\x1b[1mbold text here\x1b[0m"
+}
+
+func TestTruncatedLines(t *testing.T) {
+	const test = `
+run
+----
+-- view:
+short␤
+0123456…␤
+日本語…␤
+` + "\x1b[1mbold te…\x1b[0m🛇" + `
+
+set truncate_width=0
+----
+truncate_width: 0
+
+run
+----
+-- view:
+short␤
+0123456789abcdef␤
+日本語のテキスト␤
+` + "\x1b[1mbold text here\x1b[0m🛇" + `
+`
+	RunModelFromString(t, test, wideModel{}, WithTruncatedLines(8))
+}
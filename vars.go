@@ -0,0 +1,49 @@
+package catwalk
+
+import (
+	"regexp"
+	"strings"
+)
+
+// varPrefix is the prefix of the keys of the set and reset
+// directives which define variables.
+const varPrefix = "var."
+
+// WithVariable defines a variable which can be substituted in the
+// input commands of run directives with $name or ${name}. Variables
+// can also be defined in test files with `set var.name=value`, and
+// removed with `reset var.name`.
+func WithVariable(name, value string) Option {
+	return func(d *driver) {
+		d.setVar(name, value)
+	}
+}
+
+func (d *driver) setVar(name, value string) {
+	if d.vars == nil {
+		d.vars = make(map[string]string)
+	}
+	d.vars[name] = value
+}
+
+// varNameRe matches the valid variable names.
+var varNameRe = regexp.MustCompile(`^\w+$`)
+
+// varRe matches the variable references in input commands.
+var varRe = regexp.MustCompile(`\$\{(\w+)\}|\$(\w+)`)
+
+// expandVars substitutes the defined variables in the given input
+// command. References to undefined variables are left unchanged, so
+// that "$" can be used in text typed into the model.
+func (d *driver) expandVars(s string) string {
+	if len(d.vars) == 0 || !strings.Contains(s, "$") {
+		return s
+	}
+	return varRe.ReplaceAllStringFunc(s, func(ref string) string {
+		name := strings.Trim(ref, "${}")
+		if val, ok := d.vars[name]; ok {
+			return val
+		}
+		return ref
+	})
+}
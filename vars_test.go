@@ -0,0 +1,7 @@
+package catwalk
+
+import "testing"
+
+func TestVars(t *testing.T) {
+	RunModel(t, "testdata/vars", intModel(0), WithVariable("prefix", "z"))
+}
@@ -0,0 +1,173 @@
+package catwalk
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// defaultWaitForTimeout is how long wait_for waits by default.
+const defaultWaitForTimeout = time.Second
+
+// waitFor implements the wait_for input command: it keeps
+// processing the queued messages and commands, as well as the
+// messages sent externally or via subscriptions, until the view
+// matches the regular expression or the timeout elapses.
+func (d *driver) waitFor(t TB, trace bool, args ...string) {
+	const syntax = `syntax: wait_for "<regexp>" [timeout=<duration>]`
+	timeout := defaultWaitForTimeout
+	if n := len(args); n > 0 && strings.HasPrefix(args[n-1], "timeout=") {
+		tm, err := time.ParseDuration(strings.TrimPrefix(args[n-1], "timeout="))
+		if err != nil {
+			t.Fatalf("%s: invalid timeout value: %v", d.pos, err)
+		}
+		timeout = tm
+		args = args[:n-1]
+	}
+	if len(args) == 0 {
+		t.Fatalf("%s: %s", d.pos, syntax)
+	}
+	pattern, err := strconv.Unquote(strings.Join(args, " "))
+	if err != nil {
+		t.Fatalf("%s: %s", d.pos, syntax)
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		t.Fatalf("%s: invalid regexp: %v", d.pos, err)
+	}
+	d.waitForView(t, trace, re, timeout)
+}
+
+// waitForView is the implementation of wait_for once its arguments
+// have been parsed. When there is nothing left to process, the
+// scheduled inputs and the ticks deferred by the virtual clock are
+// applied in order, as if the virtual time was advanced; then the
+// messages sent from outside of the driver are waited for.
+func (d *driver) waitForView(t TB, trace bool, re *regexp.Regexp, timeout time.Duration) {
+	pattern := re.String()
+	deadline := time.Now().Add(timeout)
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		view := d.m.View()
+		if re.MatchString(view) {
+			d.trace(trace, "view matches %q", pattern)
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%s: timeout after %s waiting for the view to match %q; last view:\n%s", d.pos, timeout, pattern, view)
+		}
+		if len(d.msgs) > 0 || len(d.cmds) > 0 {
+			d.processTeaMsgs(trace)
+			d.processTeaCmds(trace)
+			if d.loopDetected {
+				return
+			}
+			continue
+		}
+		if d.fireNextEvent(t, trace, endOfTime) {
+			if d.loopDetected {
+				return
+			}
+			continue
+		}
+		// Nothing left to process: wait for a message from
+		// outside of the driver.
+		var sub Subscription
+		if len(d.subscriptions) > 0 {
+			sub = d.subscriptions[0]
+		}
+		var msg tea.Msg
+		var ok bool
+		select {
+		case msg = <-d.externalMsgs:
+			d.trace(trace, "received external msg %T", msg)
+		case msg, ok = <-sub:
+			if !ok {
+				d.trace(trace, "subscription closed")
+				d.subscriptions = d.subscriptions[1:]
+				continue
+			}
+			d.trace(trace, "pumped msg %T", msg)
+		case <-timer.C:
+			t.Fatalf("%s: timeout after %s waiting for the view to match %q; last view:\n%s", d.pos, timeout, pattern, view)
+		}
+		d.addMsg(msg)
+	}
+}
+
+// waitInit implements the wait_init input command: it keeps
+// processing the commands produced by Init, and the messages and
+// commands that follow, until there is nothing left to process, or
+// until the view matches the given regular expression, or the
+// timeout elapses. Each command may take up to the remaining time to
+// complete, instead of cmd_timeout, so that slow multi-step loading
+// chains are not abandoned. The initialization has not settled if a
+// command was abandoned.
+func (d *driver) waitInit(t TB, trace bool, args ...string) {
+	const syntax = `syntax: wait_init ["<regexp>"] [timeout=<duration>]`
+	timeout := defaultWaitForTimeout
+	if n := len(args); n > 0 && strings.HasPrefix(args[n-1], "timeout=") {
+		tm, err := time.ParseDuration(strings.TrimPrefix(args[n-1], "timeout="))
+		if err != nil {
+			t.Fatalf("%s: invalid timeout value: %v", d.pos, err)
+		}
+		timeout = tm
+		args = args[:n-1]
+	}
+	var re *regexp.Regexp
+	if len(args) > 0 {
+		pattern, err := strconv.Unquote(strings.Join(args, " "))
+		if err != nil {
+			t.Fatalf("%s: %s", d.pos, syntax)
+		}
+		re, err = regexp.Compile(pattern)
+		if err != nil {
+			t.Fatalf("%s: invalid regexp: %v", d.pos, err)
+		}
+	}
+
+	d.trace(trace, "using cmd timeout %s", timeout)
+	deadline := time.Now().Add(timeout)
+	defer func(prev time.Duration, prevDeadline time.Time) {
+		d.cmdTimeout, d.cmdDeadline = prev, prevDeadline
+	}(d.cmdTimeout, d.cmdDeadline)
+	d.cmdTimeout, d.cmdDeadline = timeout, deadline
+
+	if re != nil {
+		d.waitForView(t, trace, re, timeout)
+		return
+	}
+	abandoned := d.abandonedCmds
+	for len(d.msgs) > 0 || len(d.cmds) > 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("%s: timeout after %s waiting for the initialization to settle", d.pos, timeout)
+		}
+		d.processTeaMsgs(trace)
+		d.processTeaCmds(trace)
+		if d.loopDetected {
+			return
+		}
+	}
+	if d.abandonedCmds > abandoned {
+		t.Fatalf("%s: timeout after %s waiting for the initialization to settle", d.pos, timeout)
+	}
+	d.trace(trace, "initialization settled")
+}
+
+// startsWithWaitInit returns true if the first input command of the
+// given directive input is wait_init. In that case, the commands
+// produced by Init are left for wait_init to process.
+func startsWithWaitInit(input string) bool {
+	for _, line := range strings.Split(input, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		return line == "wait_init" || strings.HasPrefix(line, "wait_init ")
+	}
+	return false
+}